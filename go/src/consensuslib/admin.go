@@ -0,0 +1,140 @@
+package consensuslib
+
+import (
+	"consensuslib/errors"
+	"consensuslib/paxosnode"
+	"encoding/json"
+	"filelogger/singletonlogger"
+	"fmt"
+	"net/http"
+)
+
+// Status of a client's paxos node
+type Status = paxosnode.Status
+
+// Admin serves administrative RPCs about a client, on the same address as its paxos node. With TLS or a cluster key,
+// they are only served to callers that hold a certificate from the cluster's CA or the key, like the paxos RPCs.
+type Admin struct {
+	client *Client
+}
+
+// LogLevelArgs sets the log level of Component, where "" is every component without its own level.
+// Level is a level name or "reset". An empty Level leaves the levels as they are.
+type LogLevelArgs struct {
+	Component string
+	Level     string
+}
+
+// SetLogLevel RPC changes the log level of a component in this client's process, replying with every level set
+func (a *Admin) SetLogLevel(args LogLevelArgs, levels *map[string]string) (err error) {
+	if args.Level != "" {
+		err = singletonlogger.SetLevel(args.Component, args.Level)
+		if err != nil {
+			return err
+		}
+		clientLog.Infof("log level of %s set to %s", describeComponent(args.Component), args.Level)
+	}
+	*levels = singletonlogger.Levels()
+	return nil
+}
+
+// SetLogLevel of component on the node at target, or on this node when target is empty or this client's address.
+// Returns every level set on that node.
+func (c *Client) SetLogLevel(target string, args LogLevelArgs) (levels map[string]string, err error) {
+	if target == "" || target == c.outboundAddr {
+		err = (&Admin{c}).SetLogLevel(args, &levels)
+		return levels, err
+	}
+	conn, err := c.transport.DialRPC(target)
+	if err != nil {
+		return nil, fmt.Errorf("[LIB/CLIENT]#SetLogLevel: unable to reach %s: %s", target, err)
+	}
+	defer conn.Close()
+	err = conn.Call("Admin.SetLogLevel", args, &levels)
+	if err != nil {
+		return nil, fmt.Errorf("[LIB/CLIENT]#SetLogLevel: %s", err)
+	}
+	return levels, nil
+}
+
+// Status RPC replies with the status of this client's paxos node
+func (a *Admin) Status(placeholder string, status *Status) (err error) {
+	*status = a.client.paxosNode.Status()
+	return nil
+}
+
+// Status of the node at target, or of this node when target is empty or this client's address
+func (c *Client) Status(target string) (status Status, err error) {
+	if target == "" || target == c.outboundAddr {
+		return c.paxosNode.Status(), nil
+	}
+	conn, err := c.transport.DialRPC(target)
+	if err != nil {
+		return status, fmt.Errorf("[LIB/CLIENT]#Status: unable to reach %s: %s", target, err)
+	}
+	defer conn.Close()
+	err = conn.Call("Admin.Status", "placeholder", &status)
+	if err != nil {
+		return status, fmt.Errorf("[LIB/CLIENT]#Status: %s", err)
+	}
+	return status, nil
+}
+
+// ServeHTTP serves this client's node status as JSON at http://addr/status, and its metrics at
+// http://addr/metrics, or over mutual TLS when the client has TLS, until the client leaves. Returns the
+// address they are served on. A cluster key does not cover them, see transport.ListenHTTP.
+func (c *Client) ServeHTTP(addr string) (string, error) {
+	c.leaveLock.Lock()
+	defer c.leaveLock.Unlock()
+	if c.leaving {
+		return "", fmt.Errorf("[LIB/CLIENT]#ServeHTTP: The client left the network")
+	}
+	listener, err := c.transport.ListenHTTP(addr)
+	if err != nil {
+		return "", fmt.Errorf("[LIB/CLIENT]#ServeHTTP: unable to listen on %s: %s", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", c.paxosNode.Metrics)
+	mux.HandleFunc("/status", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(c.paxosNode.Status())
+	})
+	server := &http.Server{Handler: mux}
+	c.httpServers = append(c.httpServers, server)
+	go server.Serve(listener)
+	return listener.Addr().String(), nil
+}
+
+// Members RPC replies with this client's address and its neighbours', for new clients joining through it
+func (a *Admin) Members(placeholder string, members *[]string) (err error) {
+	status := a.client.paxosNode.Status()
+	*members = append([]string{status.Addr}, status.Neighbours...)
+	return nil
+}
+
+// CatchUp RPC asks this client's paxos node to catch up with its neighbours, replying with the number of values learned
+func (a *Admin) CatchUp(placeholder string, learned *int) (err error) {
+	*learned, err = a.client.paxosNode.CatchUp()
+	return err
+}
+
+// RemoveNeighbour RPC asks this client's paxos node to drop a dead neighbour. Replies false if it was
+// not a neighbour, such as when the node already dropped it after a failed round.
+func (a *Admin) RemoveNeighbour(addr string, removed *bool) (err error) {
+	err = a.client.paxosNode.RemoveNeighbour(addr)
+	if _, unknown := err.(errors.UnknownNeighbourError); unknown {
+		*removed = false
+		return nil
+	}
+	*removed = err == nil
+	return err
+}
+
+func describeComponent(component string) string {
+	if component == "" {
+		return "every component"
+	}
+	return component
+}
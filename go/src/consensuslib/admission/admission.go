@@ -0,0 +1,136 @@
+/*
+Package admission keeps stray nodes out of a cluster, short of mutual TLS, by having every connection open with
+requests signed with a key shared by the whole cluster, see the transport package.
+
+A request carries the address the node connects as, the time it was signed, and a random nonce, together with an
+HMAC-SHA256 of them. A request is admitted if its HMAC is right, it was signed less than MaxSkew ago, and its nonce
+was not seen before, so a request captured on the network cannot be replayed. The clocks of the nodes and servers
+must therefore agree to within MaxSkew. The answer to a request is signed in reply to its nonce, so an answer
+captured on the network cannot be replayed to another request either.
+
+A nil *Admission signs nothing and admits every request, so callers can use one whether or not a key is configured.
+*/
+package admission
+
+import (
+	"consensuslib/errors"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxSkew is how old, or how far in the future, a signed request can be
+var MaxSkew = time.Minute
+
+// Request to connect to a node of the cluster, or the answer to one
+type Request struct {
+	Addr      string // the address the node connects as
+	Time      int64  // when the request was signed, in unix nanoseconds
+	Nonce     string // random, so no two requests are signed the same
+	InReplyTo string // the nonce of the request this answers, if it is an answer
+	MAC       string // hex HMAC-SHA256 of the other fields with the cluster key
+}
+
+// Admission signs and verifies requests with a cluster key
+type Admission struct {
+	key  []byte
+	lock sync.Mutex
+	seen map[string]time.Time // nonces admitted less than MaxSkew ago, and when they expire
+}
+
+// New admission with the cluster key, or nil if the key is empty
+func New(key []byte) *Admission {
+	if len(key) == 0 {
+		return nil
+	}
+	return &Admission{key: key, seen: make(map[string]time.Time)}
+}
+
+// LoadKey reads a cluster key from file, ignoring surrounding whitespace such as a trailing newline
+func LoadKey(file string) (key []byte, err error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the cluster key: %s", err)
+	}
+	key = []byte(strings.TrimSpace(string(buf)))
+	if len(key) == 0 {
+		return nil, fmt.Errorf("empty cluster key in %s", file)
+	}
+	return key, nil
+}
+
+// Sign a request to connect as addr
+func (a *Admission) Sign(addr string) (r Request, err error) {
+	return a.SignReply(addr, Request{})
+}
+
+// SignReply signs the answer of the node at addr to the request to
+func (a *Admission) SignReply(addr string, to Request) (r Request, err error) {
+	r = Request{Addr: addr}
+	if a == nil {
+		return r, nil
+	}
+	nonce := make([]byte, 16)
+	if _, err = rand.Read(nonce); err != nil {
+		return r, fmt.Errorf("unable to generate a nonce: %s", err)
+	}
+	r.Time = time.Now().UnixNano()
+	r.Nonce = hex.EncodeToString(nonce)
+	r.InReplyTo = to.Nonce
+	r.MAC = a.mac(r)
+	return r, nil
+}
+
+// Verify r was signed with the cluster key, recently, and is not a replay
+func (a *Admission) Verify(r Request) error {
+	if a == nil {
+		return nil
+	}
+	if !hmac.Equal([]byte(a.mac(r)), []byte(r.MAC)) {
+		return errors.AdmissionDeniedError(r.Addr)
+	}
+	now := time.Now()
+	signed := time.Unix(0, r.Time)
+	if signed.Before(now.Add(-MaxSkew)) || signed.After(now.Add(MaxSkew)) {
+		return errors.AdmissionDeniedError(r.Addr)
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for nonce, expiry := range a.seen {
+		if expiry.Before(now) {
+			delete(a.seen, nonce)
+		}
+	}
+	if _, replayed := a.seen[r.Nonce]; replayed {
+		return errors.AdmissionDeniedError(r.Addr)
+	}
+	// a replay after the nonce expires is too old to pass the time check
+	a.seen[r.Nonce] = signed.Add(MaxSkew)
+	return nil
+}
+
+// VerifyReply verifies r like Verify, and that it answers the request to
+func (a *Admission) VerifyReply(r Request, to Request) error {
+	if a == nil {
+		return nil
+	}
+	if r.InReplyTo != to.Nonce {
+		return errors.AdmissionDeniedError(r.Addr)
+	}
+	return a.Verify(r)
+}
+
+// mac of the request's fields with the cluster key
+func (a *Admission) mac(r Request) string {
+	h := hmac.New(sha256.New, a.key)
+	h.Write([]byte(r.Addr + "\n" + strconv.FormatInt(r.Time, 10) + "\n" + r.Nonce + "\n" + r.InReplyTo))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,93 @@
+package admission
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	cluster := New([]byte("cluster key"))
+	other := New([]byte("another key"))
+	sign := func(a *Admission, addr string) Request {
+		r, err := a.Sign(addr)
+		if err != nil {
+			t.Fatalf("Bad Exit: \"TestVerify\" produced err: %v", err)
+		}
+		return r
+	}
+	replayed := sign(cluster, "127.0.0.1:1")
+	if err := cluster.Verify(replayed); err != nil {
+		t.Fatalf("Bad Exit: \"TestVerify\" produced err: %v", err)
+	}
+	badMAC := sign(cluster, "127.0.0.1:1")
+	badMAC.MAC = badMAC.MAC[:len(badMAC.MAC)-1] + "0"
+	if badMAC.MAC == replayed.MAC {
+		badMAC.MAC = badMAC.MAC[:len(badMAC.MAC)-1] + "1"
+	}
+	forged := sign(cluster, "127.0.0.1:1")
+	forged.Addr = "127.0.0.1:2"
+	// a request signed too long ago, or too far in the future, is refused even with the right MAC
+	stale := Request{Addr: "127.0.0.1:1", Time: time.Now().Add(-2 * MaxSkew).UnixNano(), Nonce: "stale"}
+	stale.MAC = cluster.mac(stale)
+	early := Request{Addr: "127.0.0.1:1", Time: time.Now().Add(2 * MaxSkew).UnixNano(), Nonce: "early"}
+	early.MAC = cluster.mac(early)
+	skewed := Request{Addr: "127.0.0.1:1", Time: time.Now().Add(MaxSkew / 2).UnixNano(), Nonce: "skewed"}
+	skewed.MAC = cluster.mac(skewed)
+
+	var tests = []struct {
+		Name     string
+		Request  Request
+		Admitted bool
+	}{
+		{"Signed", sign(cluster, "127.0.0.1:1"), true},
+		{"Unsigned", Request{Addr: "127.0.0.1:1"}, false},
+		{"OtherKey", sign(other, "127.0.0.1:1"), false},
+		{"Unkeyed", sign(nil, "127.0.0.1:1"), false},
+		{"BadMAC", badMAC, false},
+		{"Forged", forged, false},
+		{"Stale", stale, false},
+		{"Early", early, false},
+		{"SkewedWithinBounds", skewed, true},
+		{"Replayed", replayed, false},
+	}
+	for _, test := range tests {
+		if err := cluster.Verify(test.Request); (err == nil) != test.Admitted {
+			t.Errorf("Bad Exit: %s: expected admitted to be %v, got err: %v", test.Name, test.Admitted, err)
+		}
+	}
+
+	// without a key, every request is admitted
+	var open *Admission
+	if err := open.Verify(Request{Addr: "127.0.0.1:1"}); err != nil {
+		t.Errorf("Bad Exit: expected no key to admit anything, got err: %v", err)
+	}
+}
+
+func TestVerifyReply(t *testing.T) {
+	dialler := New([]byte("cluster key"))
+	listener := New([]byte("cluster key"))
+	request, err := dialler.Sign("127.0.0.1:1")
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestVerifyReply\" produced err: %v", err)
+	}
+	other, err := dialler.Sign("127.0.0.1:1")
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestVerifyReply\" produced err: %v", err)
+	}
+	answer, err := listener.SignReply("127.0.0.1:2", request)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestVerifyReply\" produced err: %v", err)
+	}
+	// an answer to another request cannot be replayed
+	if err = dialler.VerifyReply(answer, other); err == nil {
+		t.Errorf("Bad Exit: expected an answer to another request to be refused")
+	}
+	if err = dialler.VerifyReply(answer, request); err != nil {
+		t.Errorf("Bad Exit: expected the answer to be admitted, got err: %v", err)
+	}
+	tampered := answer
+	tampered.InReplyTo = other.Nonce
+	if err = dialler.VerifyReply(tampered, other); err == nil {
+		t.Errorf("Bad Exit: expected a tampered answer to be refused")
+	}
+}
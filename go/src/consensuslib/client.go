@@ -1,40 +1,104 @@
 package consensuslib
 
 import (
+	"consensuslib/admission"
+	"consensuslib/errors"
+	"consensuslib/membership"
 	"consensuslib/paxosnode"
+	"consensuslib/paxosnode/trace"
+	"consensuslib/safety"
+	"consensuslib/tracing"
+	"consensuslib/transport"
 	"filelogger/singletonlogger"
 	"fmt"
 	"math/rand"
 	"net"
+	"net/http"
 	"net/rpc"
 	"paxostracker"
+	"sort"
+	"sync"
 	"time"
 )
 
+var clientLog = singletonlogger.Component("client")
+
 // MSGHASHLEN Represents the length of message hash
 const MSGHASHLEN = 4
 
+// ClientConfig of a client's timings
+type ClientConfig struct {
+	HeartbeatRate   time.Duration // time between heartbeats to the server
+	ServerTimeout   time.Duration // how long to wait on a server that is not the one the client heartbeats to
+	FailoverTimeout time.Duration // how long to keep looking for a new primary server after losing the client's own
+	Node            paxosnode.Config
+	// when not nil, the client connects to the servers and the other nodes with mutual TLS, and requires it
+	// of everything that connects to it
+	TLS *transport.Config
+	// when not empty, the client signs every connection it makes with this key shared by the cluster, and
+	// only serves connections signed with it, so stray nodes can neither register it nor become its neighbours
+	ClusterKey []byte
+	// when not empty, spans of the client's writes, and of the RPCs its node serves, are exported to a new
+	// file at SpanFile. Each line of the file is an OpenTelemetry export request in JSON.
+	SpanFile string
+	// when not zero, the client audits the logs of every node it can reach for violations of Paxos safety
+	// every AuditInterval once it joined, and logs the violations it finds as errors
+	AuditInterval time.Duration
+}
+
+// DefaultClientConfig heartbeats often enough for the default server timeout, and fails over
+// for three times that timeout
+func DefaultClientConfig() ClientConfig {
+	serverTimeout := DefaultServerConfig().HeartbeatTimeout
+	return ClientConfig{
+		HeartbeatRate:   serverTimeout / 4,
+		ServerTimeout:   serverTimeout / 4,
+		FailoverTimeout: 3 * serverTimeout,
+		Node:            paxosnode.DefaultConfig(),
+	}
+}
+
 // PaxosNodeRPCWrapper is the rpc wrapper around the paxos node
 type PaxosNodeRPCWrapper = paxosnode.PaxosNodeRPCWrapper
 
 // Client in the consensuslib
 type Client struct {
-	localAddr     string
-	outboundAddr  string
-	heartbeatRate time.Duration
+	localAddr    string
+	outboundAddr string
+	config       ClientConfig
 
 	listener        net.Listener
-	serverRPCClient *rpc.Client
+	transport       *transport.Transport
+	rpcServer       *rpc.Server
+	serverAddrs     []string
+	serverLock      sync.Mutex
+	serverRPCClient *rpc.Client // guarded by serverLock
 
 	paxosNode           *paxosnode.PaxosNode
 	paxosNodeRPCWrapper *PaxosNodeRPCWrapper
 	neighbors           []string
+	tracker             *paxostracker.PaxosTracker
+
+	leaveLock   sync.Mutex
+	leaving     bool           // guarded by leaveLock
+	writes      sync.WaitGroup // in-flight writes, drained before leaving
+	done        chan struct{}  // closed once the client left
+	httpServers []*http.Server // serving /status and /metrics, guarded by leaveLock
 }
 
 // NewClient creates a new Client, ready to connect
 func NewClient(localAddr string, outboundAddr string, heartbeatRate time.Duration) (client *Client, err error) {
+	config := DefaultClientConfig()
+	config.HeartbeatRate = heartbeatRate
+	return NewClientWithConfig(localAddr, outboundAddr, config)
+}
+
+// NewClientWithConfig creates a client with the timings in config
+func NewClientWithConfig(localAddr string, outboundAddr string, config ClientConfig) (client *Client, err error) {
 	client = &Client{
-		heartbeatRate: heartbeatRate,
+		config:    config,
+		rpcServer: rpc.NewServer(),
+		done:      make(chan struct{}),
 	}
 
 	addr, err := net.ResolveTCPAddr("tcp", localAddr)
@@ -42,18 +106,40 @@ func NewClient(localAddr string, outboundAddr string, heartbeatRate time.Duratio
 		return nil, fmt.Errorf("[LIB/CLIENT]#NewClient: unable to resolve client addr: %s", err)
 	}
 
-	client.listener, err = net.ListenTCP("tcp", addr)
+	client.transport, err = transport.New(config.TLS, admission.New(config.ClusterKey))
+	if err != nil {
+		return nil, fmt.Errorf("[LIB/CLIENT]#NewClient: Unable to set up TLS: %s", err)
+	}
+	client.listener, err = client.transport.Listen(addr.String())
 	if err != nil {
 		return nil, fmt.Errorf("[LIB/CLIENT]#NewClient: Unable to listen to IP address '%s': %s", addr, err)
 	}
 	client.localAddr = client.listener.Addr().String()
 	client.outboundAddr = outboundAddr
-	singletonlogger.Debug(fmt.Sprintf("[LIB/CLIENT]#NewClient: Listening on IP address %v", client.localAddr))
-	singletonlogger.Debug(fmt.Sprintf("[LIB/CLIENT]#NewClient: Outbound IP address is %v", client.outboundAddr))
+	if client.outboundAddr == "" {
+		// no outbound address given, so neighbours reach us on the address we listen on
+		client.outboundAddr = client.localAddr
+	}
+	// servers and neighbours only let the client act for the address they reach it on
+	client.transport = client.transport.As(client.outboundAddr)
+	clientLog.Debugf("NewClient: Listening on IP address %v", client.localAddr)
+	clientLog.Debugf("NewClient: Outbound IP address is %v", client.outboundAddr)
+	client.tracker = paxostracker.NewPaxosTracker(client.outboundAddr)
 
 	// create the paxosnode
-	client.paxosNode, err = paxosnode.NewPaxosNode(client.outboundAddr)
+	// the node's neighbours are dialled the same way as the servers
+	config.Node.Transport = client.transport
+	if config.SpanFile != "" {
+		// the node traces from its first RPC, so the tracer is set before it serves any
+		config.Node.Tracer, err = tracing.NewTracer(client.outboundAddr, config.SpanFile)
+		if err != nil {
+			return nil, fmt.Errorf("[LIB/CLIENT]#NewClient: Unable to export spans: %s", err)
+		}
+		clientLog.Debugf("NewClient: Exporting spans to %s", config.SpanFile)
+	}
+	client.paxosNode, err = paxosnode.NewPaxosNodeWithConfig(client.outboundAddr, client.tracker, config.Node)
 	if err != nil {
+		config.Node.Tracer.Close()
 		return nil, fmt.Errorf("[LIB/CLIENT]#NewClient: Unable to create a paxos node: %s", err)
 	}
 
@@ -62,53 +148,230 @@ func NewClient(localAddr string, outboundAddr string, heartbeatRate time.Duratio
 	if err != nil {
 		return nil, fmt.Errorf("[LIB/CLIENT]#NewClient: Unable to create RPC wrapper: %s", err)
 	}
-	rpc.Register(client.paxosNodeRPCWrapper)
-	go rpc.Accept(client.listener)
+	// each client serves its own wrapper, so several clients can share a process
+	client.rpcServer.Register(client.paxosNodeRPCWrapper)
+	client.rpcServer.Register(&Admin{client})
+	go transport.Serve(client.rpcServer, client.listener, client.authorize)
 
-	paxostracker.NewPaxosTracker()
 	return client, nil
 }
 
-// Connect the client to the server at serverAddr
-func (c *Client) Connect(serverAddr string) (err error) {
-	c.serverRPCClient, err = rpc.Dial("tcp", serverAddr)
-	if err != nil {
-		return fmt.Errorf("[LIB/CLIENT]#Connect: Unable to connect to server: %s", err)
+// authorize a call acting for a node only from that node, see transport.Authorizer. Paxos messages are
+// only taken from the node's neighbours and members, and calls changing its state from outside the protocol
+// from its members and from tools, which declare no address. Calls that only read, such as Admin.Members
+// asked by a node joining through this one, are open to any caller the transport admitted.
+func (c *Client) authorize(peer *transport.Peer, method string, args interface{}) error {
+	var addr string
+	switch method {
+	case "PaxosNodeRPCWrapper.ConnectRemoteNeighbour", "PaxosNodeRPCWrapper.NeighbourLeaving":
+		addr = args.(string)
+	case "SWIM.Ping":
+		addr = args.(membership.PingArgs).From
+	case "SWIM.PingReq":
+		addr = args.(membership.PingReqArgs).From
+	case "PaxosNodeRPCWrapper.ProcessPrepareRequest", "PaxosNodeRPCWrapper.ProcessAcceptRequest",
+		"PaxosNodeRPCWrapper.NotifyAboutAccepted", "PaxosNodeRPCWrapper.CleanYourNeighbours":
+		return c.authorizeMember(peer, method, false)
+	case "Admin.SetLogLevel", "Admin.CatchUp", "Admin.RemoveNeighbour",
+		"PaxosNodeRPCWrapper.SetBreakpoint", "PaxosNodeRPCWrapper.ContinueRound", "PaxosNodeRPCWrapper.StepRound":
+		return c.authorizeMember(peer, method, true)
+	default:
+		return nil
+	}
+	if !peer.Vouches(addr) {
+		clientLog.Infof("Refused %s for %s from %s", method, addr, peer.Addr)
+		return errors.UnverifiedCallerError(addr)
+	}
+	return nil
+}
+
+// authorizeMember a call only from a neighbour or member of the node, or also from a tool if tools are allowed.
+// With plain TCP, callers cannot be told apart, and the nil peer is authorized.
+func (c *Client) authorizeMember(peer *transport.Peer, method string, tools bool) error {
+	if peer == nil || tools && peer.Addr == "" || peer.Addr != "" && c.paxosNode.IsMember(peer.Addr) {
+		return nil
 	}
+	clientLog.Infof("Refused %s from %s, which is not a member", method, peer.Addr)
+	return errors.UnknownMemberError(peer.Addr)
+}
+
+// Connect the client to the primary of the servers at serverAddrs, failing over between them
+// if the primary dies
+func (c *Client) Connect(serverAddrs ...string) (err error) {
+	c.serverAddrs = serverAddrs
 
 	// Register outboundAddr with the server so the server can 1) receive heartbeats, and 2) inform neighbours about us
 	// The server will populate our neighbours field with our neighbours
-	singletonlogger.Debug(fmt.Sprintf("[LIB/CLIENT]#Connect: Registering to server at: %s\n", serverAddr))
-	err = c.serverRPCClient.Call("Server.Register", c.outboundAddr, &c.neighbors)
+	for _, serverAddr := range serverAddrs {
+		clientLog.Debugf("Connect: Registering to server at: %s", serverAddr)
+		conn, dialErr := c.transport.DialRPC(serverAddr)
+		if dialErr != nil {
+			err = fmt.Errorf("[LIB/CLIENT]#Connect: Unable to connect to server: %s", dialErr)
+			continue
+		}
+		args := RegisterArgs{Addr: c.outboundAddr, ID: c.paxosNode.ID}
+		if callErr := conn.Call("Server.Register", args, &c.neighbors); callErr != nil {
+			conn.Close()
+			err = fmt.Errorf("[LIB/CLIENT]#Connect: Unable to register with server: %s", callErr)
+			continue
+		}
+		c.serverRPCClient, err = conn, nil
+		break
+	}
 	if err != nil {
-		return fmt.Errorf("[LIB/CLIENT]#Connect: Unable to register with server: %s", err)
+		return err
 	}
 	go c.SendHeartbeats()
+	err = c.joinNeighbours("Connect")
+	if err != nil {
+		return err
+	}
+	go c.followMembership()
+	c.startAudits()
+	return nil
+}
 
-	// For each neighbour received from the server, 1) set up a connection, and 2) Learn what log values they have.
+// Join the network without a server, through the members at peers. Each peer is a seed: the client
+// becomes neighbours with every member the reachable peers know of. Peers that are not up yet are
+// skipped, so every node of a small fixed cluster can be given the same static list, and the first
+// one to start runs alone until the others join it.
+func (c *Client) Join(peers ...string) (err error) {
+	known := make(map[string]bool)
+	for _, peer := range peers {
+		if peer == c.outboundAddr {
+			continue
+		}
+		var members []string
+		if err := c.transport.Call(peer, 0, "Admin.Members", "placeholder", &members); err != nil {
+			clientLog.Debugf("Join: Skipping peer %s: %s", peer, err)
+			continue
+		}
+		for _, member := range members {
+			known[member] = true
+		}
+	}
+	delete(known, c.outboundAddr)
+	c.neighbors = make([]string, 0, len(known))
+	for member := range known {
+		c.neighbors = append(c.neighbors, member)
+	}
+	sort.Strings(c.neighbors)
+	if len(c.neighbors) == 0 {
+		clientLog.Infof("Join: No peer of %v answered, starting a new network", peers)
+	}
+	if err = c.joinNeighbours("Join"); err != nil {
+		return err
+	}
+	c.startAudits()
+	return nil
+}
+
+// joinNeighbours known to the client, on behalf of caller
+func (c *Client) joinNeighbours(caller string) (err error) {
+	// For each neighbour, 1) set up a connection, and 2) Learn what log values they have.
 	// Then, choose the longest log received from the neighbours. Lastly, set up the round number the network is
 	// currently at.
 	if len(c.neighbors) > 0 {
-		singletonlogger.Debug(fmt.Sprintf("[LIB/CLIENT]#Connect: Neighbors: %v\n", c.neighbors))
+		clientLog.Debugf("%s: Neighbors: %v", caller, c.neighbors)
 		err = c.paxosNode.BecomeNeighbours(c.neighbors)
 		if err != nil {
-			return fmt.Errorf("[LIB/CLIENT]#Connect: Unable to connect to neighbors: %s", err)
+			return fmt.Errorf("[LIB/CLIENT]#%s: Unable to connect to neighbors: %s", caller, err)
 		}
-		singletonlogger.Debug("[LIB/CLIENT]#Connect: Learning the latest value from neighbours")
+		clientLog.Debugf("%s: Learning the latest value from neighbours", caller)
 		err = c.paxosNode.LearnLatestValueFromNeighbours()
-		log := c.paxosNode.Learner.Log
+		log, _ := c.paxosNode.GetLog()
 		if len(log) != 0 {
 			rn := (log[len(log)-1].RoundNum) + 1
 			c.paxosNode.SetRoundNum(rn)
+			c.paxosNode.RecordState()
 		}
 
 		if err != nil {
-			return fmt.Errorf("[LIB/CLIENT]#Connect: Unable to learn latest value while reading: %s", err)
+			return fmt.Errorf("[LIB/CLIENT]#%s: Unable to learn latest value while reading: %s", caller, err)
 		}
 	}
 	return nil
 }
 
+// Resume from the log the client's node saved in its data directory before it was restarted, on any address.
+// The acceptor state saved there is restored whether or not the client resumes. Call before Connect or Join,
+// which then rejoin the network under the same identity.
+func (c *Client) Resume() (err error) {
+	err = c.paxosNode.RestoreFromBackup()
+	if err != nil {
+		return fmt.Errorf("[LIB/CLIENT]#Resume: Unable to restore from backup: %s", err)
+	}
+	log, _ := c.paxosNode.GetLog()
+	clientLog.Infof("Resume: Restored a log of %v values", len(log))
+	return nil
+}
+
+// Leave the network gracefully: writes in flight are given time to finish, then the server and the neighbours
+// are told the client is leaving, so they stop waiting on it at once. There is no leadership to hand over, as
+// every node proposes its own writes. The client cannot be used once it left.
+func (c *Client) Leave() (err error) {
+	c.leaveLock.Lock()
+	if c.leaving {
+		c.leaveLock.Unlock()
+		return fmt.Errorf("[LIB/CLIENT]#Leave: Already left")
+	}
+	c.leaving = true
+	c.leaveLock.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		c.writes.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(c.config.Node.Timeout):
+		clientLog.Infof("Leave: Leaving with writes still in flight")
+	}
+
+	close(c.done)
+	for _, serverAddr := range c.serverAddrs {
+		var left bool
+		if err = c.transport.Call(serverAddr, c.config.ServerTimeout, "Server.Leave", c.outboundAddr, &left); err == nil {
+			break
+		}
+		clientLog.Debugf("Leave: %s", err)
+	}
+	if err != nil {
+		clientLog.Infof("Leave: No server was told of leaving, it will time out: %s", err)
+	}
+	err = c.paxosNode.Leave()
+	if err != nil {
+		return fmt.Errorf("[LIB/CLIENT]#Leave: Unable to leave the neighbours: %s", err)
+	}
+	if server := c.server(); server != nil {
+		server.Close()
+	}
+	c.listener.Close()
+	c.leaveLock.Lock()
+	for _, server := range c.httpServers {
+		server.Close()
+	}
+	c.leaveLock.Unlock()
+	if err = c.paxosNode.Tracer.Close(); err != nil {
+		clientLog.Errorf("Leave: Unable to close the span file: %s", err)
+	}
+	clientLog.Infof("Leave: Left the network")
+	return nil
+}
+
+// RecordTrace records every input of the client's paxos node into a trace file at path,
+// so its execution can be replayed offline. Call before Connect to record the node from its start.
+func (c *Client) RecordTrace(path string) (err error) {
+	t, err := trace.Create(path)
+	if err != nil {
+		return fmt.Errorf("[LIB/CLIENT]#RecordTrace: Unable to record: %s", err)
+	}
+	c.paxosNode.StartTrace(t)
+	clientLog.Debugf("RecordTrace: Recording to %s", path)
+	return nil
+}
+
 // Read the node's version of the log
 // It should be eventually consistent to the Paxos Network's agreed-upon version of the log.
 func (c *Client) Read() (value string, err error) {
@@ -116,7 +379,7 @@ func (c *Client) Read() (value string, err error) {
 	if err != nil {
 		return "", fmt.Errorf("[LIB/CLIENT]#Read: Error while getting the log: %s", err)
 	}
-	singletonlogger.Debug(fmt.Sprintf("[LIB/CLIENT]#Read: Log = '%v'\n", log))
+	clientLog.Debugf("Read: Log = '%v'", log)
 	for _, m := range log {
 		value += m.Value + "\n"
 	}
@@ -125,29 +388,183 @@ func (c *Client) Read() (value string, err error) {
 
 // Write to the shared log
 func (c *Client) Write(value string) (err error) {
-	paxostracker.Prepare(c.listener.Addr().String())
+	c.leaveLock.Lock()
+	if c.leaving {
+		c.leaveLock.Unlock()
+		return fmt.Errorf("[LIB/CLIENT]#Write: The client left the network")
+	}
+	c.writes.Add(1)
+	c.leaveLock.Unlock()
+	defer c.writes.Done()
+
+	singletonlogger.LogLocalEvent(c.paxosNode.ID, fmt.Sprintf("write %q", value))
+	c.tracker.Prepare(c.paxosNode.ID, value, c.paxosNode.Round())
 	messageHash := generateMessageHash(MSGHASHLEN)
 	_, err = c.paxosNode.WriteToPaxosNode(value, messageHash, paxosnode.TTL)
 	return err
 }
 
+// EnableMembership detects failed neighbours with a SWIM failure detector, gossiping with the other clients
+// that enabled it. Call before Connect or Join.
+func (c *Client) EnableMembership(config membership.Config) (err error) {
+	m := c.paxosNode.EnableMembership(config)
+	err = c.rpcServer.Register(m.RPC())
+	if err != nil {
+		return fmt.Errorf("[LIB/CLIENT]#EnableMembership: Unable to serve the membership: %s", err)
+	}
+	return nil
+}
+
+// EnableVectorClock logs every paxos message this client's node sends and receives with a vector clock of its own,
+// under the node's ID, in the GoVector format ShiViz can visualise. Clocks are only piggybacked on messages while enabled.
+func (c *Client) EnableVectorClock() (err error) {
+	return singletonlogger.EnableVectorClock(c.paxosNode.ID)
+}
+
+// Audit the logs of every node this client can reach for violations of Paxos safety
+func (c *Client) Audit() (violations []safety.Violation, err error) {
+	violations, err = c.paxosNode.AuditSafety()
+	if err != nil {
+		return nil, fmt.Errorf("[LIB/CLIENT]#Audit: Error while auditing: %s", err)
+	}
+	return violations, nil
+}
+
+// startAudits every AuditInterval until the client leaves, if the config asks for them
+func (c *Client) startAudits() {
+	if c.config.AuditInterval > 0 {
+		go c.auditPeriodically(c.config.AuditInterval)
+	}
+}
+
+// auditPeriodically every interval until the client leaves, logging the violations found
+func (c *Client) auditPeriodically(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		violations, err := c.Audit()
+		if err != nil {
+			clientLog.Infof("Unable to audit: %s", err)
+			continue
+		}
+		for _, v := range violations {
+			clientLog.Errorf("Safety violation: %s", v)
+		}
+	}
+}
+
+// Tracker is the paxostracker following this client's rounds
+func (c *Client) Tracker() *paxostracker.PaxosTracker {
+	return c.tracker
+}
+
+// SetBreakpoint arms a paxostracker breakpoint on the node it names, or on every node when it names none
+func (c *Client) SetBreakpoint(bp paxostracker.Breakpoint) (err error) {
+	err = c.paxosNode.SetBreakpoint(bp)
+	if err != nil {
+		return fmt.Errorf("[LIB/CLIENT]#SetBreakpoint: Unable to set breakpoint: %s", err)
+	}
+	return nil
+}
+
+// Continue the round paused on the target node, or on every node when target is empty
+func (c *Client) Continue(target string) (err error) {
+	err = c.paxosNode.ContinueOn(target)
+	if err != nil {
+		return fmt.Errorf("[LIB/CLIENT]#Continue: Unable to continue: %s", err)
+	}
+	return nil
+}
+
+// Step the round paused on the target node, or on every paused node when target is empty
+func (c *Client) Step(target string) (err error) {
+	err = c.paxosNode.StepOn(target)
+	if err != nil {
+		return fmt.Errorf("[LIB/CLIENT]#Step: Unable to step: %s", err)
+	}
+	return nil
+}
+
+// Addr is the outbound address neighbours reach this client's paxos node on
+func (c *Client) Addr() string {
+	return c.outboundAddr
+}
+
+// ID of the client's node, which stays the same across restarts on the same data directory
+func (c *Client) ID() string {
+	return c.paxosNode.ID
+}
+
 // IsAlive checks if the server is alive
 func (c *Client) IsAlive() (alive bool, err error) {
+	if c.server() == nil {
+		return false, fmt.Errorf("[LIB/CLIENT]#IsAlive: joined without a server")
+	}
 	// alive is default false
-	err = c.serverRPCClient.Call("Server.CheckAlive", c.outboundAddr, &alive)
+	err = c.server().Call("Server.CheckAlive", c.outboundAddr, &alive)
 	return alive, err
 }
 
-// SendHeartbeats to the server
+// SendHeartbeats to the server, failing over to another server when it stops answering
 func (c *Client) SendHeartbeats() (err error) {
-	for _ = range time.Tick(c.heartbeatRate) {
+	ticker := time.NewTicker(c.config.HeartbeatRate)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return nil
+		case <-ticker.C:
+		}
 		var ignored bool
-		err = c.serverRPCClient.Call("Server.HeartBeat", c.outboundAddr, &ignored)
+		err = c.server().Call("Server.HeartBeat", c.outboundAddr, &ignored)
 		if err != nil {
-			return fmt.Errorf("[LIB/CLIENT]#SendHeartheats: Error while sending heartbeat: %s", err)
+			clientLog.Infof("Lost the server: %s", err)
+			if err = c.failover(); err != nil {
+				return fmt.Errorf("[LIB/CLIENT]#SendHeartheats: Error while sending heartbeat: %s", err)
+			}
 		}
 	}
-	return nil
+}
+
+// failover to whichever server is the primary now. The primary learned this client from the one that died,
+// so a client it does not know has been removed, and is not registered again.
+func (c *Client) failover() (err error) {
+	deadline := time.Now().Add(c.config.FailoverTimeout)
+	for time.Now().Before(deadline) {
+		for _, serverAddr := range c.serverAddrs {
+			conn, dialErr := c.transport.DialRPC(serverAddr)
+			if dialErr != nil {
+				err = dialErr
+				continue
+			}
+			var ignored bool
+			err = conn.Call("Server.HeartBeat", c.outboundAddr, &ignored)
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			clientLog.Infof("Failed over to server %s", serverAddr)
+			c.serverLock.Lock()
+			c.serverRPCClient.Close()
+			c.serverRPCClient = conn
+			c.serverLock.Unlock()
+			return nil
+		}
+		time.Sleep(c.config.HeartbeatRate)
+	}
+	return fmt.Errorf("no server answered within %v: %s", c.config.FailoverTimeout, err)
+}
+
+// server the client is connected to
+func (c *Client) server() *rpc.Client {
+	c.serverLock.Lock()
+	defer c.serverLock.Unlock()
+	return c.serverRPCClient
 }
 
 func generateMessageHash(length int) string {
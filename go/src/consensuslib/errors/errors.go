@@ -46,3 +46,39 @@ type TimeoutError string
 func (e TimeoutError) Error() string {
 	return fmt.Sprintf("The function [%s] called timed out.", string(e))
 }
+
+type UnknownNeighbourError string
+
+func (e UnknownNeighbourError) Error() string {
+	return fmt.Sprintf("[%s] is not a neighbour of this PN", string(e))
+}
+
+type NotPrimaryError string
+
+func (e NotPrimaryError) Error() string {
+	return fmt.Sprintf("consensuslib server: not the primary server, try [%s]", string(e))
+}
+
+type AdmissionDeniedError string
+
+func (e AdmissionDeniedError) Error() string {
+	return fmt.Sprintf("consensuslib: [%s] is not signed with the cluster key, or its request is stale", string(e))
+}
+
+type UnknownMemberError string
+
+func (e UnknownMemberError) Error() string {
+	return fmt.Sprintf("[%s] is not a member of the network", string(e))
+}
+
+type UnverifiedCallerError string
+
+func (e UnverifiedCallerError) Error() string {
+	return fmt.Sprintf("consensuslib: the caller is not verified to be [%s]", string(e))
+}
+
+type WriteAbandonedError string
+
+func (e WriteAbandonedError) Error() string {
+	return fmt.Sprintf("consensuslib: gave up writing [%s] after its TTL of rounds went to values already accepted", string(e))
+}
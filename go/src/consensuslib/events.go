@@ -0,0 +1,180 @@
+package consensuslib
+
+import (
+	"consensuslib/errors"
+	"fmt"
+	"time"
+)
+
+/**
+ * Events push changes of membership from the server to every client, so neighbours are added and removed
+ * as soon as the server knows of them, rather than only when a newcomer connects.
+ *
+ * net/rpc cannot stream, so clients long-poll: Server.Subscribe blocks until there are events after the
+ * last one the client saw, or until the server's heartbeat timeout. A client that subscribes for the first
+ * time, fails over to another server, or falls further behind than the server keeps events for, reconciles
+ * its neighbours with the full list of members before following events again.
+ */
+
+// EventType is a change of membership
+type EventType string
+
+const (
+	// JOIN is sent when a client registers
+	JOIN EventType = "Join"
+	// LEAVE is sent when a client is removed, or times out
+	LEAVE EventType = "Leave"
+)
+
+// Event of the membership, numbered in the order the server saw them
+type Event struct {
+	Seq  uint64
+	Type EventType
+	Addr string
+}
+
+// SubscribeArgs ask for the events after After, or for a reset when not Following
+type SubscribeArgs struct {
+	Addr      string
+	After     uint64
+	Following bool
+}
+
+// Events in reply to a subscription. Reset is set when the subscriber must reconcile with the members,
+// as the events it missed are gone, and Next is the After of its next subscription.
+type Events struct {
+	Events []Event
+	Reset  bool
+	Next   uint64
+}
+
+// maxEvents kept by a server for subscribers that fell behind
+const maxEvents = 1024
+
+// publish an event to subscribers. Called with allUsers locked.
+func (s *Server) publish(eventType EventType, addr string) {
+	s.lastSeq++
+	s.events = append(s.events, Event{s.lastSeq, eventType, addr})
+	if len(s.events) > maxEvents {
+		s.events = s.events[len(s.events)-maxEvents:]
+	}
+	if s.published != nil {
+		close(s.published)
+	}
+	s.published = make(chan struct{})
+}
+
+// Subscribe to the events after args.After, waiting for one if there are none yet
+func (s *Server) Subscribe(args SubscribeArgs, events *Events) error {
+	// a subscription waits for an event for as long as a user may go without a heartbeat
+	deadline := time.After(s.config.HeartbeatTimeout)
+	for {
+		s.allUsers.Lock()
+		if !s.primary {
+			s.allUsers.Unlock()
+			return errors.NotPrimaryError(s.primaryAddr)
+		}
+		if !args.Following || args.After > s.lastSeq || len(s.events) != 0 && args.After < s.events[0].Seq-1 {
+			*events = Events{Reset: true, Next: s.lastSeq}
+			s.allUsers.Unlock()
+			return nil
+		}
+		if args.After < s.lastSeq {
+			first := len(s.events) - int(s.lastSeq-args.After)
+			*events = Events{Events: append([]Event{}, s.events[first:]...), Next: s.lastSeq}
+			s.allUsers.Unlock()
+			return nil
+		}
+		if s.published == nil {
+			s.published = make(chan struct{})
+		}
+		published := s.published
+		s.allUsers.Unlock()
+
+		select {
+		case <-published:
+		case <-s.closed:
+			return fmt.Errorf("[ConsensusLib/serv] server closed")
+		case <-deadline:
+			*events = Events{Next: args.After}
+			return nil
+		}
+	}
+}
+
+// followMembership of the server, adding and removing neighbours as clients join and leave
+func (c *Client) followMembership() {
+	var after uint64
+	following := false
+	lost := time.Time{}
+	for {
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+		var events Events
+		err := c.server().Call("Server.Subscribe", SubscribeArgs{c.outboundAddr, after, following}, &events)
+		if err != nil {
+			if lost.IsZero() {
+				lost = time.Now()
+			} else if time.Since(lost) > c.config.FailoverTimeout {
+				clientLog.Infof("followMembership: Stopped following the server: %s", err)
+				return
+			}
+			// a new primary numbers its events afresh
+			following = false
+			time.Sleep(c.config.HeartbeatRate)
+			continue
+		}
+		lost = time.Time{}
+		if events.Reset {
+			if err = c.reconcileNeighbours(); err != nil {
+				clientLog.Debugf("followMembership: %s", err)
+				time.Sleep(c.config.HeartbeatRate)
+				continue
+			}
+		}
+		for _, event := range events.Events {
+			c.applyEvent(event)
+		}
+		after, following = events.Next, true
+	}
+}
+
+// reconcileNeighbours with the members of the server
+func (c *Client) reconcileNeighbours() (err error) {
+	var members []Member
+	err = c.server().Call("Server.Members", "placeholder", &members)
+	if err != nil {
+		return fmt.Errorf("[LIB/CLIENT]#reconcileNeighbours: Unable to read the members: %s", err)
+	}
+	registered := make(map[string]bool, len(members))
+	for _, m := range members {
+		registered[m.Address] = true
+		c.applyEvent(Event{Type: JOIN, Addr: m.Address})
+	}
+	for _, addr := range c.paxosNode.Status().Neighbours {
+		if !registered[addr] {
+			c.applyEvent(Event{Type: LEAVE, Addr: addr})
+		}
+	}
+	return nil
+}
+
+// applyEvent to the neighbours of the client's paxos node
+func (c *Client) applyEvent(event Event) {
+	if event.Addr == c.outboundAddr {
+		return
+	}
+	switch event.Type {
+	case JOIN:
+		if err := c.paxosNode.AddNeighbour(event.Addr); err != nil {
+			clientLog.Debugf("applyEvent: Unable to add neighbour %s: %s", event.Addr, err)
+		}
+	case LEAVE:
+		if err := c.paxosNode.RemoveNeighbour(event.Addr); err == nil {
+			clientLog.Infof("applyEvent: %s left", event.Addr)
+		}
+	}
+}
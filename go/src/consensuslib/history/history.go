@@ -0,0 +1,179 @@
+package history
+
+import (
+	"consensuslib"
+	"linearizability"
+	"sync"
+	"time"
+)
+
+/**
+ * History records the invoke and complete events of calls made through consensuslib Clients,
+ * so that the history of a run can be checked for linearizability afterwards.
+ */
+
+// EventType is either an invocation or a completion
+type EventType int
+
+const (
+	// INVOKE is recorded just before the call is made
+	INVOKE EventType = iota
+	// COMPLETE is recorded as soon as the call returns
+	COMPLETE
+)
+
+// Event in a recorded history
+type Event struct {
+	Type     EventType
+	OpID     int // pairs an INVOKE with its COMPLETE
+	ClientID int
+	Kind     linearizability.OpKind
+	Value    string // value written, or value read on completion
+	Err      string // error returned on completion, if any
+	Time     int64  // nanoseconds since the recorder was created
+}
+
+// Recorder collects events from every wrapped client
+type Recorder struct {
+	sync.Mutex
+	start   time.Time
+	events  []Event
+	nextOp  int
+	clients int
+}
+
+// RecordedClient wraps a consensuslib Client and records every Read and Write
+type RecordedClient struct {
+	ID       int
+	client   *consensuslib.Client
+	recorder *Recorder
+}
+
+// NewRecorder creates an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{
+		start:  time.Now(),
+		events: make([]Event, 0),
+	}
+}
+
+// Wrap a client so its calls are recorded
+func (r *Recorder) Wrap(client *consensuslib.Client) *RecordedClient {
+	r.Lock()
+	defer r.Unlock()
+	rc := &RecordedClient{
+		ID:       r.clients,
+		client:   client,
+		recorder: r,
+	}
+	r.clients++
+	return rc
+}
+
+// Write to the shared log, recording the call
+func (rc *RecordedClient) Write(value string) (err error) {
+	id := rc.recorder.invoke(rc.ID, linearizability.WRITE, value)
+	err = rc.client.Write(value)
+	rc.recorder.complete(id, rc.ID, linearizability.WRITE, "", err)
+	return err
+}
+
+// Read the node's log, recording the call
+func (rc *RecordedClient) Read() (value string, err error) {
+	id := rc.recorder.invoke(rc.ID, linearizability.READ, "")
+	value, err = rc.client.Read()
+	rc.recorder.complete(id, rc.ID, linearizability.READ, value, err)
+	return value, err
+}
+
+// Events returns a copy of the events recorded so far, in the order they happened
+func (r *Recorder) Events() []Event {
+	r.Lock()
+	defer r.Unlock()
+	events := make([]Event, len(r.events))
+	copy(events, r.events)
+	return events
+}
+
+// Operations pairs up the recorded events into operations for the checker.
+// A call that has not completed, or that completed with an error, may or may not have taken effect,
+// so it is left pending. A failed read tells us nothing and is dropped.
+func (r *Recorder) Operations() []linearizability.Operation {
+	events := r.Events()
+	ops := make([]linearizability.Operation, 0)
+	index := make(map[int]int)
+	for _, e := range events {
+		switch e.Type {
+		case INVOKE:
+			index[e.OpID] = len(ops)
+			ops = append(ops, linearizability.Operation{
+				ClientID: e.ClientID,
+				Kind:     e.Kind,
+				Input:    e.Value,
+				Call:     e.Time,
+				Return:   linearizability.Pending,
+			})
+		case COMPLETE:
+			if e.Err != "" {
+				continue
+			}
+			op := &ops[index[e.OpID]]
+			op.Return = e.Time
+			if e.Kind == linearizability.READ {
+				op.Output = e.Value
+			}
+		}
+	}
+	// drop reads which never returned a value
+	checked := make([]linearizability.Operation, 0, len(ops))
+	for _, op := range ops {
+		if op.Kind == linearizability.READ && op.Return == linearizability.Pending {
+			continue
+		}
+		checked = append(checked, op)
+	}
+	return checked
+}
+
+// Check the recorded history against the sequential log model
+func (r *Recorder) Check() linearizability.Result {
+	return linearizability.Check(linearizability.LogModel{}, r.Operations())
+}
+
+func (r *Recorder) invoke(clientID int, kind linearizability.OpKind, value string) int {
+	r.Lock()
+	defer r.Unlock()
+	id := r.nextOp
+	r.nextOp++
+	r.events = append(r.events, Event{
+		Type:     INVOKE,
+		OpID:     id,
+		ClientID: clientID,
+		Kind:     kind,
+		Value:    value,
+		Time:     r.now(),
+	})
+	return id
+}
+
+func (r *Recorder) complete(id, clientID int, kind linearizability.OpKind, value string, err error) {
+	r.Lock()
+	defer r.Unlock()
+	e := Event{
+		Type:     COMPLETE,
+		OpID:     id,
+		ClientID: clientID,
+		Kind:     kind,
+		Value:    value,
+		Time:     r.now(),
+	}
+	if err != nil {
+		e.Err = err.Error()
+	}
+	r.events = append(r.events, e)
+}
+
+// now must be called with the lock held, so event times are in the same order as the events slice
+func (r *Recorder) now() int64 {
+	return int64(time.Since(r.start))
+}
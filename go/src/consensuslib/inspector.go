@@ -0,0 +1,122 @@
+package consensuslib
+
+import (
+	"consensuslib/safety"
+	"consensuslib/transport"
+	"fmt"
+)
+
+// Inspector looks into a running network from outside it, through its servers and the admin RPCs of its nodes
+type Inspector struct {
+	serverAddrs []string
+	transport   *transport.Transport
+}
+
+// NewInspector of the network registered with the servers at serverAddrs
+func NewInspector(serverAddrs ...string) (inspector *Inspector, err error) {
+	return NewInspectorWithTLS(nil, serverAddrs...)
+}
+
+// NewInspectorWithTLS of a network that requires mutual TLS configured by config, or plain TCP if it is nil
+func NewInspectorWithTLS(config *transport.Config, serverAddrs ...string) (inspector *Inspector, err error) {
+	t, err := transport.New(config, nil)
+	if err != nil {
+		return nil, fmt.Errorf("[LIB/INSPECTOR]#NewInspector: Unable to set up TLS: %s", err)
+	}
+	return NewInspectorWithTransport(t, serverAddrs...)
+}
+
+// NewInspectorWithTransport of a network reached through t, such as one signing its connections with the cluster key
+func NewInspectorWithTransport(t *transport.Transport, serverAddrs ...string) (inspector *Inspector, err error) {
+	inspector = &Inspector{serverAddrs, t}
+	alive := false
+	if err = inspector.callServer("Server.CheckAlive", "inspector", &alive); err != nil {
+		return nil, fmt.Errorf("[LIB/INSPECTOR]#NewInspector: Unable to connect to server: %s", err)
+	}
+	return inspector, nil
+}
+
+// Members registered with the servers, sorted by address
+func (i *Inspector) Members() (members []Member, err error) {
+	err = i.callServer("Server.Members", "placeholder", &members)
+	if err != nil {
+		return nil, fmt.Errorf("[LIB/INSPECTOR]#Members: %s", err)
+	}
+	return members, nil
+}
+
+// Addrs of the given nodes, or of every member when none are given
+func (i *Inspector) Addrs(nodes []string) (addrs []string, err error) {
+	if len(nodes) != 0 {
+		return nodes, nil
+	}
+	members, err := i.Members()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		addrs = append(addrs, m.Address)
+	}
+	return addrs, nil
+}
+
+// Snapshot of the logs and proposals of the nodes at addrs
+func (i *Inspector) Snapshot(addrs []string) (snapshot *safety.Snapshot, err error) {
+	return safety.GatherFrom(i.transport, addrs)
+}
+
+// Status of the node at addr
+func (i *Inspector) Status(addr string) (status Status, err error) {
+	err = i.callNode(addr, "Admin.Status", "placeholder", &status)
+	return status, err
+}
+
+// CatchUp the node at addr with its neighbours, returning the number of values it learned
+func (i *Inspector) CatchUp(addr string) (learned int, err error) {
+	err = i.callNode(addr, "Admin.CatchUp", "placeholder", &learned)
+	return learned, err
+}
+
+// Remove a dead member from the server, and from the neighbours of every other member.
+// Returns the members which could not be told to drop it.
+func (i *Inspector) Remove(addr string) (unreachable []string, err error) {
+	var removed bool
+	err = i.callServer("Server.Remove", addr, &removed)
+	if err != nil {
+		return nil, fmt.Errorf("[LIB/INSPECTOR]#Remove: %s", err)
+	}
+	members, err := i.Members()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		if err := i.callNode(m.Address, "Admin.RemoveNeighbour", addr, &removed); err != nil {
+			unreachable = append(unreachable, m.Address)
+		}
+	}
+	return unreachable, nil
+}
+
+// callServer calls each server in turn until one answers, so calls only the primary can answer reach it
+func (i *Inspector) callServer(method string, args interface{}, reply interface{}) (err error) {
+	for _, addr := range i.serverAddrs {
+		if err = i.callNode(addr, method, args, reply); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// callNode dials the node at addr for a single call
+func (i *Inspector) callNode(addr string, method string, args interface{}, reply interface{}) error {
+	conn, err := i.transport.DialRPC(addr)
+	if err != nil {
+		return fmt.Errorf("[LIB/INSPECTOR]: unable to reach %s: %s", addr, err)
+	}
+	defer conn.Close()
+	err = conn.Call(method, args, reply)
+	if err != nil {
+		return fmt.Errorf("[LIB/INSPECTOR]: %s on %s: %s", method, addr, err)
+	}
+	return nil
+}
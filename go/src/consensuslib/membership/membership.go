@@ -0,0 +1,419 @@
+/*
+Package membership keeps a view of which nodes of the network are alive, with a SWIM-style gossip
+failure detector (Das, Gupta and Motivala, 2002).
+
+Every ProbeInterval, a node pings one member, going round the members in a random order:
+  - if the member acks within ProbeTimeout, it is alive
+  - otherwise IndirectProbes other members are asked to ping it on the node's behalf,
+    and if none of them gets an ack by the end of the interval, the member is suspected
+  - a member suspected for SuspicionTimeout without refuting it is declared dead
+
+Changes of state are gossiped on the pings and acks themselves. Each member has an incarnation number
+that only it increments, to refute being suspected, so a newer incarnation of an alive member overrides
+an older suspicion, and a suspicion overrides an alive member of the same incarnation.
+
+A member leaving the network gossips that it left, so it is evicted at once rather than after being
+suspected, and it can rejoin later with a newer incarnation.
+
+The view only changes through the protocol, so a single failed request is never enough to evict a member.
+Pings are only answered for members of the view, so a node has to be added by a member, once it was
+admitted to the network, before it can take part.
+*/
+package membership
+
+import (
+	"consensuslib/transport"
+	"filelogger/singletonlogger"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+var swimLog = singletonlogger.Component("membership")
+
+// State of a member
+type State string
+
+const (
+	// ALIVE members answer probes
+	ALIVE State = "Alive"
+	// SUSPECT members failed a probe, and are declared dead unless they refute it in time
+	SUSPECT State = "Suspect"
+	// DEAD members stayed suspected for the suspicion timeout
+	DEAD State = "Dead"
+	// LEFT members said they were leaving the network
+	LEFT State = "Left"
+)
+
+// Member of the network, as seen by one node
+type Member struct {
+	Addr        string
+	State       State
+	Incarnation uint64
+}
+
+// Config of the failure detector
+type Config struct {
+	ProbeInterval    time.Duration        // time between probes, the protocol period
+	ProbeTimeout     time.Duration        // time to wait for a direct ack, less than ProbeInterval
+	IndirectProbes   int                  // members asked to probe a member that did not ack
+	SuspicionTimeout time.Duration        // time a member stays suspected before it is declared dead
+	Transport        *transport.Transport // carries the probes, plain TCP when nil
+}
+
+// DefaultConfig suspects a member after a second without an ack, and declares it dead five seconds later
+func DefaultConfig() Config {
+	return Config{
+		ProbeInterval:    time.Second,
+		ProbeTimeout:     300 * time.Millisecond,
+		IndirectProbes:   3,
+		SuspicionTimeout: 5 * time.Second,
+	}
+}
+
+// maxPiggybacked is the number of updates gossiped on each ping or ack
+const maxPiggybacked = 8
+
+// update being gossiped, with the number of times it has been sent
+type update struct {
+	member Member
+	sent   int
+}
+
+// Membership is one node's view of the network
+type Membership struct {
+	sync.Mutex
+	self        string
+	incarnation uint64
+	members     map[string]*Member
+	suspected   map[string]time.Time // when each suspect member was first suspected
+	gossip      []*update
+	probeOrder  []string
+	config      Config
+	onChange    func(Member)
+	stop        chan struct{}
+	left        bool
+}
+
+// New view for the node at self. onChange is called with every member whose state changes,
+// apart from the ones added directly.
+func New(self string, config Config, onChange func(Member)) *Membership {
+	if onChange == nil {
+		onChange = func(Member) {}
+	}
+	return &Membership{
+		self:      self,
+		members:   make(map[string]*Member),
+		suspected: make(map[string]time.Time),
+		config:    config,
+		onChange:  onChange,
+		stop:      make(chan struct{}),
+	}
+}
+
+// Start probing members
+func (m *Membership) Start() {
+	go func() {
+		ticker := time.NewTicker(m.config.ProbeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.probe()
+				m.expireSuspicions()
+			}
+		}
+	}()
+}
+
+// Stop probing members
+func (m *Membership) Stop() {
+	close(m.stop)
+}
+
+// Add members known to be alive, such as the neighbours a node joined, and gossip them to the others
+func (m *Membership) Add(addrs ...string) {
+	m.Lock()
+	defer m.Unlock()
+	for _, addr := range addrs {
+		if addr == m.self {
+			continue
+		}
+		if member, ok := m.members[addr]; ok && !member.gone() {
+			continue
+		}
+		member := &Member{addr, ALIVE, 0}
+		if known, ok := m.members[addr]; ok {
+			// a member rejoining after being declared dead or leaving is a new incarnation
+			member.Incarnation = known.Incarnation + 1
+		}
+		m.members[addr] = member
+		delete(m.suspected, addr)
+		m.queue(*member)
+	}
+}
+
+// Leave the network, telling the alive members directly rather than waiting for it to be gossiped, and stop probing
+func (m *Membership) Leave() {
+	m.Lock()
+	m.left = true
+	m.incarnation++
+	left := Member{m.self, LEFT, m.incarnation}
+	m.queue(left)
+	alive := make([]string, 0, len(m.members))
+	for addr, member := range m.members {
+		if member.State != DEAD && member.State != LEFT {
+			alive = append(alive, addr)
+		}
+	}
+	m.Unlock()
+	swimLog.Infof("leaving, telling %d members", len(alive))
+	for _, addr := range alive {
+		var ack Ack
+		m.call(addr, "SWIM.Ping", PingArgs{m.self, []Member{left}}, &ack, m.config.ProbeTimeout)
+	}
+	m.Stop()
+}
+
+// gone members are not probed any more
+func (member *Member) gone() bool {
+	return member.State == DEAD || member.State == LEFT
+}
+
+// Members in the view, apart from this node, sorted by address
+func (m *Membership) Members() []Member {
+	m.Lock()
+	defer m.Unlock()
+	members := make([]Member, 0, len(m.members))
+	for _, member := range m.members {
+		members = append(members, *member)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Addr < members[j].Addr })
+	return members
+}
+
+// IsMember says whether addr is in the view and has not died or left
+func (m *Membership) IsMember(addr string) bool {
+	m.Lock()
+	defer m.Unlock()
+	member, known := m.members[addr]
+	return known && !member.gone()
+}
+
+// Incarnation of this node
+func (m *Membership) Incarnation() uint64 {
+	m.Lock()
+	defer m.Unlock()
+	return m.incarnation
+}
+
+// probe the next member, directly then indirectly, and suspect it if neither gets an ack
+func (m *Membership) probe() {
+	target, ok := m.nextTarget()
+	if !ok {
+		return
+	}
+	if m.ping(target, m.config.ProbeTimeout) {
+		return
+	}
+	swimLog.Debugf("no ack from %s, probing it indirectly", target)
+	helpers := m.randomMembers(m.config.IndirectProbes, target)
+	acks := make(chan bool, len(helpers))
+	for _, helper := range helpers {
+		go func(helper string) {
+			acks <- m.pingReq(helper, target, m.config.ProbeInterval-m.config.ProbeTimeout)
+		}(helper)
+	}
+	for range helpers {
+		if <-acks {
+			return
+		}
+	}
+	m.suspect(target)
+}
+
+// nextTarget goes round the members that are not dead or gone, in a random order that is reshuffled every round
+func (m *Membership) nextTarget() (target string, ok bool) {
+	m.Lock()
+	defer m.Unlock()
+	for len(m.probeOrder) > 0 {
+		target, m.probeOrder = m.probeOrder[0], m.probeOrder[1:]
+		if member, ok := m.members[target]; ok && !member.gone() {
+			return target, true
+		}
+	}
+	for addr, member := range m.members {
+		if !member.gone() {
+			m.probeOrder = append(m.probeOrder, addr)
+		}
+	}
+	if len(m.probeOrder) == 0 {
+		return "", false
+	}
+	rand.Shuffle(len(m.probeOrder), func(i, j int) {
+		m.probeOrder[i], m.probeOrder[j] = m.probeOrder[j], m.probeOrder[i]
+	})
+	target, m.probeOrder = m.probeOrder[0], m.probeOrder[1:]
+	return target, true
+}
+
+// randomMembers picks up to n alive members other than except
+func (m *Membership) randomMembers(n int, except string) []string {
+	m.Lock()
+	defer m.Unlock()
+	candidates := make([]string, 0, len(m.members))
+	for addr, member := range m.members {
+		if addr != except && member.State == ALIVE {
+			candidates = append(candidates, addr)
+		}
+	}
+	rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	return candidates
+}
+
+// suspect the member at addr, unless it is already suspected or dead
+func (m *Membership) suspect(addr string) {
+	m.Lock()
+	member, ok := m.members[addr]
+	if !ok || member.State != ALIVE {
+		m.Unlock()
+		return
+	}
+	swimLog.Infof("suspecting %s", addr)
+	m.change(member, SUSPECT, member.Incarnation)
+	suspected := *member
+	m.Unlock()
+	m.onChange(suspected)
+}
+
+// expireSuspicions declares the members suspected for longer than the suspicion timeout dead
+func (m *Membership) expireSuspicions() {
+	m.Lock()
+	dead := make([]Member, 0)
+	for addr, since := range m.suspected {
+		if time.Since(since) < m.config.SuspicionTimeout {
+			continue
+		}
+		member := m.members[addr]
+		swimLog.Infof("declaring %s dead after %v suspected", addr, time.Since(since).Round(time.Millisecond))
+		m.change(member, DEAD, member.Incarnation)
+		dead = append(dead, *member)
+	}
+	m.Unlock()
+	for _, member := range dead {
+		m.onChange(member)
+	}
+}
+
+// merge gossiped updates into the view
+func (m *Membership) merge(updates []Member) {
+	changed := make([]Member, 0)
+	m.Lock()
+	for _, u := range updates {
+		if u.Addr == m.self {
+			m.refute(u)
+			continue
+		}
+		member, ok := m.members[u.Addr]
+		if !ok {
+			member = &Member{Addr: u.Addr}
+			m.members[u.Addr] = member
+		} else if !overrides(u, *member) {
+			continue
+		}
+		m.change(member, u.State, u.Incarnation)
+		changed = append(changed, *member)
+	}
+	m.Unlock()
+	for _, member := range changed {
+		m.onChange(member)
+	}
+}
+
+// refute an update saying this node is suspected or dead, by gossiping a newer incarnation of it alive
+func (m *Membership) refute(u Member) {
+	if u.State == ALIVE || u.Incarnation < m.incarnation || m.left {
+		return
+	}
+	m.incarnation = u.Incarnation + 1
+	swimLog.Infof("refuting being %s with incarnation %d", u.State, m.incarnation)
+	m.queue(Member{m.self, ALIVE, m.incarnation})
+}
+
+// overrides says if the update u takes precedence over what is known of the member
+func overrides(u Member, known Member) bool {
+	switch u.State {
+	case ALIVE:
+		return u.Incarnation > known.Incarnation
+	case SUSPECT:
+		return known.State == ALIVE && u.Incarnation >= known.Incarnation ||
+			known.State == SUSPECT && u.Incarnation > known.Incarnation
+	case DEAD:
+		return !known.gone()
+	case LEFT:
+		return !known.gone() || known.State == DEAD && u.Incarnation > known.Incarnation
+	}
+	return false
+}
+
+// change the state of a member, and gossip it. Called with the lock held.
+func (m *Membership) change(member *Member, state State, incarnation uint64) {
+	member.State = state
+	member.Incarnation = incarnation
+	if state == SUSPECT {
+		m.suspected[member.Addr] = time.Now()
+	} else {
+		delete(m.suspected, member.Addr)
+	}
+	m.queue(*member)
+}
+
+// queue an update to gossip, replacing any older one about the same member. Called with the lock held.
+func (m *Membership) queue(member Member) {
+	for i, u := range m.gossip {
+		if u.member.Addr == member.Addr {
+			m.gossip = append(m.gossip[:i], m.gossip[i+1:]...)
+			break
+		}
+	}
+	m.gossip = append(m.gossip, &update{member: member})
+}
+
+// piggyback the updates sent the fewest times, dropping those gossiped often enough to have reached everyone
+func (m *Membership) piggyback() []Member {
+	m.Lock()
+	defer m.Unlock()
+	limit := retransmissions(len(m.members) + 1)
+	sort.SliceStable(m.gossip, func(i, j int) bool { return m.gossip[i].sent < m.gossip[j].sent })
+	updates := make([]Member, 0, maxPiggybacked)
+	for _, u := range m.gossip {
+		if len(updates) == maxPiggybacked {
+			break
+		}
+		updates = append(updates, u.member)
+		u.sent++
+	}
+	kept := m.gossip[:0]
+	for _, u := range m.gossip {
+		if u.sent < limit {
+			kept = append(kept, u)
+		}
+	}
+	m.gossip = kept
+	return updates
+}
+
+// retransmissions of each update for it to reach a network of n nodes with high probability
+func retransmissions(n int) int {
+	limit := 3
+	for ; n > 1; n /= 2 {
+		limit += 3
+	}
+	return limit
+}
@@ -0,0 +1,97 @@
+package membership
+
+import (
+	"consensuslib/errors"
+	"fmt"
+	"time"
+)
+
+// PingArgs carry the sender's address and the updates it gossips
+type PingArgs struct {
+	From    string
+	Updates []Member
+}
+
+// PingReqArgs ask a member to ping Target on behalf of From
+type PingReqArgs struct {
+	From    string
+	Target  string
+	Timeout time.Duration
+	Updates []Member
+}
+
+// Ack of a ping, carrying the updates the receiver gossips back
+type Ack struct {
+	Updates []Member
+}
+
+// SWIM serves the failure detector's RPCs, on the same address as the node's paxos RPCs
+type SWIM struct {
+	membership *Membership
+}
+
+// RPC to serve for the membership
+func (m *Membership) RPC() *SWIM {
+	return &SWIM{m}
+}
+
+// Ping RPC from a member probing this node
+func (s *SWIM) Ping(args PingArgs, ack *Ack) (err error) {
+	if !s.membership.knows(args.From) {
+		return errors.UnknownMemberError(args.From)
+	}
+	s.membership.merge(args.Updates)
+	ack.Updates = s.membership.piggyback()
+	return nil
+}
+
+// PingReq RPC from a member that got no ack from Target, asking this node to probe it.
+// Fails when Target does not ack in time either.
+func (s *SWIM) PingReq(args PingReqArgs, ack *Ack) (err error) {
+	if !s.membership.knows(args.From) {
+		return errors.UnknownMemberError(args.From)
+	}
+	s.membership.merge(args.Updates)
+	if !s.membership.ping(args.Target, args.Timeout) {
+		return fmt.Errorf("[LIB/MEMBERSHIP]#PingReq: no ack from %s", args.Target)
+	}
+	ack.Updates = s.membership.piggyback()
+	return nil
+}
+
+// knows whether addr is in the view. A node is only in the view once it was added directly, or gossiped
+// by a member, so a node that was never admitted to the network cannot make itself a member by pinging.
+func (m *Membership) knows(addr string) bool {
+	m.Lock()
+	defer m.Unlock()
+	_, known := m.members[addr]
+	return known
+}
+
+// ping the member at addr, merging the updates on its ack. Returns whether it acked within timeout.
+func (m *Membership) ping(addr string, timeout time.Duration) bool {
+	var ack Ack
+	err := m.call(addr, "SWIM.Ping", PingArgs{m.self, m.piggyback()}, &ack, timeout)
+	if err != nil {
+		return false
+	}
+	m.merge(ack.Updates)
+	return true
+}
+
+// pingReq asks the member at helper to ping target. Returns whether target acked through it.
+func (m *Membership) pingReq(helper string, target string, timeout time.Duration) bool {
+	var ack Ack
+	args := PingReqArgs{m.self, target, timeout / 2, m.piggyback()}
+	err := m.call(helper, "SWIM.PingReq", args, &ack, timeout)
+	if err != nil {
+		return false
+	}
+	m.merge(ack.Updates)
+	return true
+}
+
+// call a method on the node at addr, giving up after timeout
+func (m *Membership) call(addr string, method string, args interface{}, reply interface{}, timeout time.Duration) error {
+	return m.config.Transport.Call(addr, timeout, method, args, reply)
+}
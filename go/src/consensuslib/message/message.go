@@ -1,6 +1,10 @@
 package message
 
-import "time"
+import (
+	"consensuslib/tracing"
+	"filelogger/vclock"
+	"time"
+)
 
 const (
 	PREPARE MsgType = iota
@@ -14,13 +18,16 @@ type MsgType int
 
 // generates a new message
 type Message struct {
-	ID             uint64  // unique ID for the paxos NW
-	MsgHash        string  // unique hash for the message
-	Type           MsgType // msgType should only be 'prepare' or 'accept'. 'prepare' messages should have empty value field
-	Value          string  // value that needs to be written into log
-	FromProposerID string  // Proposer's ID to distinguish when same ID message arrived
-	RoundNum       int     // The number of the round the message is for
-	Bounces        int     // TTL for the message
+	ID             uint64               // unique ID for the paxos NW
+	MsgHash        string               // unique hash for the message
+	Type           MsgType              // msgType should only be 'prepare' or 'accept'. 'prepare' messages should have empty value field
+	Value          string               // value that needs to be written into log
+	FromProposerID string               // Proposer's ID to distinguish when same ID message arrived
+	RoundNum       int                  // The number of the round the message is for
+	Bounces        int                  // TTL for the message
+	Clock          vclock.VClock        `json:",omitempty"` // sender's vector clock, piggybacked when vector clock logging is on
+	Span           *tracing.SpanContext `json:",omitempty"` // span of the request carrying the message, when tracing is on
+	Accepted       *Message             `json:",omitempty"` // in a promise, the accept request already accepted in the round
 }
 
 // generates a new message
@@ -33,10 +40,22 @@ func NewMessage(id uint64, msgHash string, msgType MsgType, val string, pid stri
 		pid,
 		roundNum,
 		ttl,
+		nil,
+		nil,
+		nil,
 	}
 	return m
 }
 
+// Outranks checks whether m was sent with a higher proposal number than m1. Proposers number their requests
+// independently, so equal IDs are ordered by proposer ID.
+func (m *Message) Outranks(m1 *Message) bool {
+	if m.ID != m1.ID {
+		return m.ID > m1.ID
+	}
+	return m.FromProposerID > m1.FromProposerID
+}
+
 // checks whether or not messages are equal based on the unique hash
 func (m *Message) Equals(m1 *Message) bool {
 	if m.MsgHash == m1.MsgHash {
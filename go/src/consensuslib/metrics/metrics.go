@@ -0,0 +1,231 @@
+/*
+Package metrics keeps counters, gauges and histograms about a node or server,
+and serves them in the Prometheus text exposition format.
+
+Each node and server has its own Registry, so several can share a process.
+*/
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets for latencies, in seconds
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metric is anything a Registry can write
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry of metrics, which serves them over HTTP
+type Registry struct {
+	sync.Mutex
+	metrics map[string]metric
+	server  *http.Server // the registry is served by, guarded by the lock
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+func (r *Registry) register(m metric) {
+	r.Lock()
+	defer r.Unlock()
+	if _, exists := r.metrics[m.name()]; exists {
+		panic("metrics: " + m.name() + " registered twice")
+	}
+	r.metrics[m.name()] = m
+}
+
+// WriteTo writes every metric in the Prometheus text format, sorted by name
+func (r *Registry) WriteTo(w io.Writer) (n int64, err error) {
+	r.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	for _, name := range names {
+		r.metrics[name].write(&buf)
+	}
+	r.Unlock()
+	return buf.WriteTo(w)
+}
+
+// ServeHTTP serves the metrics to a scraper
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	r.WriteTo(w)
+}
+
+// Serve the registry at /metrics on listener, until it is closed, returning the address it listens on
+func (r *Registry) Serve(listener net.Listener) (listenAddr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r)
+	r.Lock()
+	r.server = &http.Server{Handler: mux}
+	go r.server.Serve(listener)
+	r.Unlock()
+	return listener.Addr().String()
+}
+
+// Close the server the registry is served by, and its connections, if it is served
+func (r *Registry) Close() error {
+	r.Lock()
+	defer r.Unlock()
+	if r.server == nil {
+		return nil
+	}
+	return r.server.Close()
+}
+
+type desc struct {
+	metricName string
+	help       string
+}
+
+func (d desc) name() string {
+	return d.metricName
+}
+
+func (d desc) header(w io.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.metricName, d.help, d.metricName, kind)
+}
+
+// Counter only goes up
+type Counter struct {
+	desc
+	sync.Mutex
+	value float64
+}
+
+// NewCounter registers a counter
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{desc: desc{name, help}}
+	r.register(c)
+	return c
+}
+
+// Inc adds one
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add delta, which must not be negative
+func (c *Counter) Add(delta float64) {
+	c.Lock()
+	defer c.Unlock()
+	c.value += delta
+}
+
+// Value counted so far
+func (c *Counter) Value() float64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.value
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.header(w, "counter")
+	fmt.Fprintf(w, "%s %s\n", c.metricName, format(c.Value()))
+}
+
+// Gauge is a value that goes up and down, read when scraped
+type Gauge struct {
+	desc
+	value func() float64
+}
+
+// NewGauge registers a gauge read from value at every scrape
+func (r *Registry) NewGauge(name, help string, value func() float64) *Gauge {
+	g := &Gauge{desc: desc{name, help}, value: value}
+	r.register(g)
+	return g
+}
+
+// Value now
+func (g *Gauge) Value() float64 {
+	return g.value()
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.header(w, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.metricName, format(g.Value()))
+}
+
+// Histogram counts observations into cumulative buckets
+type Histogram struct {
+	desc
+	sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+// NewHistogram registers a histogram with the given upper bounds, in increasing order
+func (r *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := &Histogram{desc: desc{name, help}, buckets: buckets, counts: make([]uint64, len(buckets))}
+	r.register(h)
+	return h
+}
+
+// Observe a value
+func (h *Histogram) Observe(v float64) {
+	h.Lock()
+	defer h.Unlock()
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// ObserveSince observes the seconds since start
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// Count of observations
+func (h *Histogram) Count() uint64 {
+	h.Lock()
+	defer h.Unlock()
+	return h.count
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.Lock()
+	defer h.Unlock()
+	h.header(w, "histogram")
+	for i, bound := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.metricName, format(bound), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.metricName, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", h.metricName, format(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", h.metricName, h.count)
+}
+
+func format(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -4,26 +4,37 @@ import (
 	"consensuslib/message"
 	"encoding/json"
 	"filelogger/singletonlogger"
-	"fmt"
 	"io/ioutil"
 	"os"
+	"paxostracker"
+	"sync"
 )
 
+var acceptorLog = singletonlogger.Component("acceptor")
+
 type Message = message.Message
 
 type AcceptorRole struct {
 	ID           string
 	LastPromised Message
 	LastAccepted Message
+	tracker      *paxostracker.PaxosTracker
+	backupDir    string
+	lock         *sync.Mutex // guards LastPromised and LastAccepted, as requests come in concurrently
 }
 
-func NewAcceptor(id string) AcceptorRole {
+// The tracker is told whenever this acceptor promises or accepts a request.
+// Nothing is backed up until the backup directory is set.
+func NewAcceptor(id string, tracker *paxostracker.PaxosTracker) AcceptorRole {
 	acc := AcceptorRole{
 		id,
 		Message{},
 		Message{},
+		tracker,
+		"",
+		&sync.Mutex{},
 	}
-	singletonlogger.Debug(fmt.Sprintf("[Acceptor] %v", acc.ID))
+	acceptorLog.Debugf("%v", acc.ID)
 	return acc
 }
 
@@ -44,124 +55,181 @@ type AcceptorInterface interface {
 
 	// Reads the last accepted message from the backup file saved on the host machine
 	RestoreFromBackup()
+
+	// Sets the directory backup files are saved in and restored from
+	SetBackupDir(dir string)
+
+	// The last promised and accepted messages, read together
+	State() (lastPromised, lastAccepted Message)
+
+	// Replaces the last promised and accepted messages, such as with the state a trace was recorded from
+	SetState(lastPromised, lastAccepted Message)
 }
 
 func (acceptor *AcceptorRole) ProcessPrepare(msg Message, roundNum int) Message {
-	singletonlogger.Debug(fmt.Sprintf("[Acceptor] process prepare for round %v", roundNum))
+	acceptor.lock.Lock()
+	defer acceptor.lock.Unlock()
+	acceptorLog.Debugf("process prepare for round %v", roundNum)
 	// no any value had been proposed or n'>n
 	// then n' == n and ID' == ID (basically same proposer distributed proposal twice)
 	if &acceptor.LastPromised == nil ||
-		(msg.ID > acceptor.LastPromised.ID && roundNum >= acceptor.LastPromised.RoundNum) {
+		(msg.Outranks(&acceptor.LastPromised) && roundNum >= acceptor.LastPromised.RoundNum) {
 		acceptor.LastPromised = msg
-	} else if msg.ID > acceptor.LastPromised.ID &&
+	} else if msg.Outranks(&acceptor.LastPromised) &&
 		//acceptor.LastPromised.FromProposerID == msg.FromProposerID &&
 		acceptor.LastPromised.RoundNum == roundNum {
 		acceptor.LastPromised = msg
 	}
-	singletonlogger.Debug(fmt.Sprintf("[Acceptor] promised id: %d, val: %s, round: %d \n", acceptor.LastPromised.ID, acceptor.LastPromised.Value, roundNum))
+	acceptorLog.With(singletonlogger.Fields{"node": acceptor.ID, "round": roundNum, "ballot": acceptor.LastPromised.ID}).Debugf("promised val: %s", acceptor.LastPromised.Value)
+	if acceptor.LastPromised.Equals(&msg) {
+		acceptor.tracker.Promise(msg.FromProposerID, msg.ID, roundNum)
+	}
 	acceptor.saveIntoFile(acceptor.LastPromised)
-	return acceptor.LastPromised
+	promise := acceptor.LastPromised
+	if promise.Equals(&msg) && acceptor.acceptedIn(msg.RoundNum) && !acceptor.LastAccepted.Equals(&msg) {
+		// the proposer must propose this value instead of its own, as it may already be chosen
+		accepted := acceptor.LastAccepted
+		accepted.Clock, accepted.Span, accepted.Accepted = nil, nil, nil
+		promise.Accepted = &accepted
+	}
+	return promise
+}
+
+// acceptedIn checks whether the acceptor accepted a request in the round
+func (acceptor *AcceptorRole) acceptedIn(roundNum int) bool {
+	return acceptor.LastAccepted.MsgHash != "" && acceptor.LastAccepted.RoundNum == roundNum
 }
 
 func (acceptor *AcceptorRole) ProcessAccept(msg Message, roundNum int) Message {
-	singletonlogger.Debug("[Acceptor] process accept")
+	acceptor.lock.Lock()
+	defer acceptor.lock.Unlock()
+	acceptorLog.Debug("process accept")
+	// a request below the proposal promised in this round is refused, or two values could be chosen
+	promised := acceptor.LastPromised.RoundNum >= roundNum && acceptor.LastPromised.Outranks(&msg)
 	if &acceptor.LastAccepted == nil {
 		if msg.ID == acceptor.LastPromised.ID &&
-			//msg.FromProposerID == acceptor.LastPromised.FromProposerID {
+			msg.FromProposerID == acceptor.LastPromised.FromProposerID &&
 			msg.MsgHash == acceptor.LastPromised.MsgHash {
 			acceptor.LastAccepted = msg
-		} else if msg.ID > acceptor.LastPromised.ID {
+		} else if msg.Outranks(&acceptor.LastPromised) {
 			//acceptor.LastPromised = msg
 			acceptor.LastAccepted = msg
 		}
 	} else {
 		if msg.ID == acceptor.LastPromised.ID &&
-			//acceptor.LastPromised.FromProposerID == msg.FromProposerID &&
+			acceptor.LastPromised.FromProposerID == msg.FromProposerID &&
 			//acceptor.LastPromised.RoundNum == roundNum {
 			acceptor.LastPromised.MsgHash == msg.MsgHash {
 			acceptor.LastAccepted = msg
-		} else if (msg.ID > acceptor.LastPromised.ID && acceptor.LastPromised.RoundNum >= roundNum) ||
-			(msg.ID > acceptor.LastAccepted.ID && acceptor.LastAccepted.RoundNum >= roundNum) {
+		} else if (msg.Outranks(&acceptor.LastPromised) && acceptor.LastPromised.RoundNum >= roundNum) ||
+			(msg.Outranks(&acceptor.LastAccepted) && acceptor.LastAccepted.RoundNum >= roundNum && !promised) {
 			acceptor.LastAccepted = msg
 		}
 	}
-	singletonlogger.Debug(fmt.Sprintf("[Acceptor] accepted id: %d, val: %s, round: %d \n", acceptor.LastAccepted.ID, acceptor.LastAccepted.Value, roundNum))
-	//TODO: 2!!!! put in goroutine?
-	go acceptor.saveIntoFile(acceptor.LastAccepted)
+	acceptorLog.With(singletonlogger.Fields{"node": acceptor.ID, "round": roundNum, "ballot": acceptor.LastAccepted.ID}).Debugf("accepted val: %s", acceptor.LastAccepted.Value)
+	if acceptor.LastAccepted.Equals(&msg) {
+		acceptor.tracker.Accept(msg.FromProposerID, msg.ID, roundNum)
+	}
+	// saved before replying, so a restarted acceptor never contradicts what it told the proposer
+	acceptor.saveIntoFile(acceptor.LastAccepted)
 	return acceptor.LastAccepted
 
 }
 
 func (acceptor *AcceptorRole) RestoreFromBackup() {
-	singletonlogger.Debug("[Acceptor] restoring from backup")
-	path := "temp1/" + acceptor.ID + "prepare.json"
+	if acceptor.backupDir == "" {
+		return
+	}
+	acceptorLog.Debug("restoring from backup")
+	acceptor.lock.Lock()
+	defer acceptor.lock.Unlock()
+	path := acceptor.backupDir + acceptor.ID + "prepare.json"
 	f, err := os.Open(path)
 	if err != nil {
-		singletonlogger.Debug(fmt.Sprintf("[Acceptor] no such file exist, no messages were promised %v", err))
+		acceptorLog.Debugf("no such file exist, no messages were promised %v", err)
 		return
 	}
 	buf, err := ioutil.ReadAll(f)
 	err = json.Unmarshal(buf, &acceptor.LastPromised)
 	if err != nil {
-		singletonlogger.Debug(fmt.Sprintf("[Acceptor] error on unmarshalling promise %v", err))
+		acceptorLog.Debugf("error on unmarshalling promise %v", err)
 	}
 	f.Close()
-	path = "temp1/" + acceptor.ID + "accept.json"
+	path = acceptor.backupDir + acceptor.ID + "accept.json"
 	f, err = os.Open(path)
 	if err != nil {
-		singletonlogger.Debug(fmt.Sprintf("[Acceptor] no such file exist, no messages were accepted %v", err))
+		acceptorLog.Debugf("no such file exist, no messages were accepted %v", err)
 		return
 	}
 	buf, err = ioutil.ReadAll(f)
 	err = json.Unmarshal(buf, &acceptor.LastAccepted)
 	if err != nil {
-		singletonlogger.Debug(fmt.Sprintf("[Acceptor] error on unmarshalling accept %v", err))
+		acceptorLog.Debugf("error on unmarshalling accept %v", err)
 	}
 }
 
+func (acceptor *AcceptorRole) State() (lastPromised, lastAccepted Message) {
+	acceptor.lock.Lock()
+	defer acceptor.lock.Unlock()
+	return acceptor.LastPromised, acceptor.LastAccepted
+}
+
+func (acceptor *AcceptorRole) SetState(lastPromised, lastAccepted Message) {
+	acceptor.lock.Lock()
+	defer acceptor.lock.Unlock()
+	acceptor.LastPromised, acceptor.LastAccepted = lastPromised, lastAccepted
+}
+
+func (acceptor *AcceptorRole) SetBackupDir(dir string) {
+	acceptor.backupDir = dir
+}
+
 // creates a log for acceptor in case of disconnection
 func (a *AcceptorRole) saveIntoFile(msg Message) (err error) {
+	if a.backupDir == "" {
+		return nil
+	}
 
-	singletonlogger.Debug("[Acceptor] saving message into file")
+	acceptorLog.Debug("saving message into file")
 	var path string
 	msgJson, err := json.Marshal(msg)
 	if err != nil {
-		singletonlogger.Debug("[Acceptor] errored on marshalling")
+		acceptorLog.Debug("errored on marshalling")
 		return err
 	}
 	var f *os.File
 	switch msg.Type {
 	case message.PREPARE:
-		path = "temp1/" + a.ID + "prepare.json"
-		singletonlogger.Debug("[Acceptor] saved PREPARE to file")
+		path = a.backupDir + a.ID + "prepare.json"
+		acceptorLog.Debug("saved PREPARE to file")
 	case message.ACCEPT:
-		path = "temp1/" + a.ID + "accept.json"
-		singletonlogger.Debug("[Acceptor] saved ACCEPT to file")
+		path = a.backupDir + a.ID + "accept.json"
+		acceptorLog.Debug("saved ACCEPT to file")
 	}
 	if err != nil {
-		singletonlogger.Debug(fmt.Sprintf("[Acceptor] errored on reading path %v", err))
+		acceptorLog.Debugf("errored on reading path %v", err)
 	}
 	if _, erro := os.Stat(path); os.IsNotExist(erro) {
-		os.MkdirAll("temp1/", os.ModePerm)
+		os.MkdirAll(a.backupDir, os.ModePerm)
 		f, err = os.Create(path)
 		if err != nil {
-			singletonlogger.Debug(fmt.Sprintf("[Acceptor] errored on creating file %v", err))
+			acceptorLog.Debugf("errored on creating file %v", err)
 		}
 
 	} else {
 		f, err = os.OpenFile(path, os.O_RDWR, 0644)
 		if err != nil {
-			singletonlogger.Debug(fmt.Sprintf("[Acceptor] errored on opening file %v", err))
+			acceptorLog.Debugf("errored on opening file %v", err)
 		}
 		err = os.Truncate(path, 0)
 		if err != nil {
-			singletonlogger.Debug(fmt.Sprintf("[Acceptor] errored on truncating file %v", err))
+			acceptorLog.Debugf("errored on truncating file %v", err)
 		}
 	}
 	//defer f.Close()
 	_, err = f.Write(msgJson)
 	if err != nil {
-		singletonlogger.Debug(fmt.Sprintf("[Acceptor] errored on writing into file %v", err))
+		acceptorLog.Debugf("errored on writing into file %v", err)
 	}
 	f.Close()
 	return err
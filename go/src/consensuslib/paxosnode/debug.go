@@ -0,0 +1,67 @@
+package paxosnode
+
+import (
+	"consensuslib/errors"
+	"fmt"
+	"paxostracker"
+	trackererrors "paxostracker/errors"
+)
+
+/**
+ * Debug commands let a paxostracker breakpoint, continue or step be issued from any PN and carried out
+ * on one other PN, or on every PN in the network. A target of "" means every PN.
+ */
+
+// notAtBreakpoint is how the error from a PN that cannot step arrives over RPC
+var notAtBreakpoint = trackererrors.NotAtBreakpoint("").Error()
+
+// SetBreakpoint arms bp on the PN it names, or on every PN when it names none
+func (pn *PaxosNode) SetBreakpoint(bp paxostracker.Breakpoint) (err error) {
+	nodeLog.Debugf("setting breakpoint before %v", bp)
+	return pn.debugCall(bp.Node, "PaxosNodeRPCWrapper.SetBreakpoint", bp, func() error {
+		return pn.Tracker.AddBreakpoint(bp)
+	})
+}
+
+// ContinueOn continues the round on the target PN, or on every PN
+func (pn *PaxosNode) ContinueOn(target string) (err error) {
+	return pn.debugCall(target, "PaxosNodeRPCWrapper.ContinueRound", "placeholder", pn.Tracker.Continue)
+}
+
+// StepOn steps the round on the target PN, or on every PN that is at a breakpoint
+func (pn *PaxosNode) StepOn(target string) (err error) {
+	return pn.debugCall(target, "PaxosNodeRPCWrapper.StepRound", "placeholder", pn.Tracker.Step)
+}
+
+// debugCall runs local if this PN is targeted, and makes the RPC to every other targeted PN.
+// When every PN is targeted, only failed RPCs are errors, since PNs not at a breakpoint cannot step.
+func (pn *PaxosNode) debugCall(target string, method string, arg interface{}, local func() error) (err error) {
+	if target == pn.Addr {
+		return local()
+	}
+	if target != "" {
+		conn, ok := pn.neighbours()[target]
+		if !ok {
+			return errors.UnknownNeighbourError(target)
+		}
+		var ignored bool
+		return conn.Call(method, arg, &ignored)
+	}
+
+	failed := make([]string, 0)
+	if e := local(); e != nil && e.Error() != notAtBreakpoint {
+		nodeLog.Debugf("debug call %s failed locally: %s", method, e)
+		failed = append(failed, pn.Addr)
+	}
+	for k, v := range pn.neighbours() {
+		var ignored bool
+		if e := v.Call(method, arg, &ignored); e != nil && e.Error() != notAtBreakpoint {
+			nodeLog.Debugf("debug call %s to %s failed: %s", method, k, e)
+			failed = append(failed, k)
+		}
+	}
+	if len(failed) != 0 {
+		return fmt.Errorf("debug call %s failed on %v", method, failed)
+	}
+	return nil
+}
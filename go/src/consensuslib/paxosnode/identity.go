@@ -0,0 +1,52 @@
+package paxosnode
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// idFile the PN's ID is kept in, in its data directory
+const idFile = "node.id"
+
+// NewID generates a random ID for a PN, unique with overwhelming probability
+func NewID() (id string, err error) {
+	b := make([]byte, 8)
+	if _, err = rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate a node ID: %s", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// LoadOrCreateID reads the ID kept in dataDir, or generates one and keeps it there if there is none yet,
+// so a PN restarted on the same data directory has the same identity whichever address it listens on
+func LoadOrCreateID(dataDir string) (id string, err error) {
+	path := filepath.Join(dataDir, idFile)
+	buf, err := ioutil.ReadFile(path)
+	if err == nil {
+		id = strings.TrimSpace(string(buf))
+		if id == "" {
+			return "", fmt.Errorf("empty node ID in %s", path)
+		}
+		return id, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", fmt.Errorf("unable to read the node ID: %s", err)
+	}
+	id, err = NewID()
+	if err != nil {
+		return "", err
+	}
+	if err = os.MkdirAll(dataDir, os.ModePerm); err != nil {
+		return "", fmt.Errorf("unable to create the data directory: %s", err)
+	}
+	if err = ioutil.WriteFile(path, []byte(id+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("unable to keep the node ID: %s", err)
+	}
+	nodeLog.Infof("generated node ID %s in %s", id, dataDir)
+	return id, nil
+}
@@ -1,13 +1,19 @@
 package learner
 
 import (
+	"bufio"
 	"consensuslib/errors"
 	"consensuslib/message"
+	"encoding/json"
 	"filelogger/singletonlogger"
 	"fmt"
+	"os"
 	"paxostracker"
+	"strconv"
 )
 
+var learnerLog = singletonlogger.Component("learner")
+
 type Message = message.Message
 
 type MessageAccepted struct {
@@ -19,6 +25,9 @@ type LearnerRole struct {
 	Accepted     *SyncLog
 	Log          []Message
 	CurrentRound int // Should start at 0
+	tracker      *paxostracker.PaxosTracker
+	id           string
+	backupDir    string
 }
 
 type LearnerInterface interface {
@@ -41,17 +50,81 @@ type LearnerInterface interface {
 	LearnValue(m *Message) (currentRoundIndex int, err error)
 }
 
-func NewLearner() LearnerRole {
+// The log is backed up as one JSON message per line in backupDir, under the same id as the acceptor's backups,
+// once the backup directory is set
+func NewLearner(id string, tracker *paxostracker.PaxosTracker) LearnerRole {
 	syncLog := NewSyncLog()
-	learner := LearnerRole{Accepted: syncLog, Log: make([]Message, 0), CurrentRound: 0}
+	learner := LearnerRole{Accepted: syncLog, Log: make([]Message, 0), CurrentRound: 0, tracker: tracker, id: id}
 	return learner
 }
 
 func (l *LearnerRole) InitializeLog(log []Message) (err error) {
-	singletonlogger.Debug(fmt.Sprintf("[learner] Initializing log with size %v", len(log)))
+	learnerLog.Debugf("Initializing log with size %v", len(log))
+	for _, m := range log {
+		l.tracker.CaughtUp(m.RoundNum, m.Value)
+	}
 	l.Log = log
 	l.CurrentRound = len(log)
-	singletonlogger.Debug(fmt.Sprintf("[learner] Initializing next round %v", l.CurrentRound))
+	learnerLog.Debugf("Initializing next round %v", l.CurrentRound)
+	return l.saveIntoFile(log, os.O_TRUNC)
+}
+
+func (l *LearnerRole) SetBackupDir(dir string) {
+	l.backupDir = dir
+}
+
+// Reads the log this learner backed up before it was restarted
+func (l *LearnerRole) RestoreFromBackup() (err error) {
+	if l.backupDir == "" {
+		return nil
+	}
+	learnerLog.Debug("restoring from backup")
+	f, err := os.Open(l.backupDir + l.id + "log.json")
+	if os.IsNotExist(err) {
+		learnerLog.Debugf("no such file exist, no values were learned %v", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	log := make([]Message, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var m Message
+		if err = json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			// the last line is torn when the node died while writing it
+			learnerLog.Debugf("error on unmarshalling learned value %v", err)
+			break
+		}
+		log = append(log, m)
+	}
+	l.Log = log
+	l.CurrentRound = len(log)
+	learnerLog.Debugf("restored %v values", len(log))
+	return nil
+}
+
+// appends messages to the backup of the log, or replaces it when flag is os.O_TRUNC
+func (l *LearnerRole) saveIntoFile(log []Message, flag int) (err error) {
+	if l.backupDir == "" {
+		return nil
+	}
+	os.MkdirAll(l.backupDir, os.ModePerm)
+	f, err := os.OpenFile(l.backupDir+l.id+"log.json", os.O_CREATE|os.O_WRONLY|flag, 0644)
+	if err != nil {
+		learnerLog.Debugf("errored on opening file %v", err)
+		return err
+	}
+	defer f.Close()
+	encoder := json.NewEncoder(f)
+	for _, m := range log {
+		if err = encoder.Encode(m); err != nil {
+			learnerLog.Debugf("errored on writing into file %v", err)
+			return err
+		}
+	}
 	return nil
 }
 
@@ -60,31 +133,36 @@ func (l *LearnerRole) GetCurrentLog() ([]Message, error) {
 }
 
 func (l *LearnerRole) NumAlreadyAccepted(m *Message) int {
-	if accepted, ok := l.Accepted.Load(m.ID); ok {
-		accepted.Times++
-		return accepted.Times
-	} else {
-		l.Accepted.Store(m.ID, &MessageAccepted{m, 1})
-		return 1
-	}
+	return l.Accepted.Add(m)
 }
 
 func (l *LearnerRole) LearnValue(m *Message) (currentRoundIndex int, err error) {
-	paxostracker.Learn(uint64(currentRoundIndex))
-	singletonlogger.Debug(fmt.Sprintf("[learner] Writing value'%v'to round %v", m.Value, l.CurrentRound))
+	l.tracker.Learn(m.ID, m.Value, m.RoundNum)
+	valueLog := learnerLog.With(singletonlogger.Fields{"round": m.RoundNum, "ballot": m.ID, "proposer": m.FromProposerID})
+	valueLog.Debugf("Writing value'%v'to round %v", m.Value, l.CurrentRound)
 	if len(l.Log) > l.CurrentRound {
 		// Since Learner manages this state, this should theoretically never happen...
-		return l.CurrentRound, errors.ValueForRoundInLogExistsError(l.CurrentRound)
+		return l.CurrentRound, errors.ValueForRoundInLogExistsError(strconv.Itoa(l.CurrentRound))
 	} else {
 		if l.inLog(m) {
+			// learned before, such as when caught up, which still ends the round
+			l.tracker.Idle(m.Value)
 			return m.RoundNum + 1, nil
 		}
-		l.Log = append(l.Log, *m)
-		singletonlogger.Debug(fmt.Sprintf("[learner] Wrote value %v to log at index %v", l.Log[l.CurrentRound], l.CurrentRound))
-		paxostracker.Idle(l.Log[l.CurrentRound].Value)
+		learned := *m
+		learned.Clock = nil
+		learned.Span = nil
+		l.Log = append(l.Log, learned)
+		if err = l.saveIntoFile([]Message{learned}, os.O_APPEND); err != nil {
+			// the value is still learned, and can be caught up on from the neighbours after a restart
+			valueLog.Errorf("unable to back up value %v: %s", m.Value, err)
+		}
+		singletonlogger.LogLocalEvent(l.id, fmt.Sprintf("learn %q at index %v", m.Value, l.CurrentRound))
+		valueLog.Debugf("Wrote value %v to log at index %v", l.Log[l.CurrentRound], l.CurrentRound)
+		l.tracker.Idle(l.Log[l.CurrentRound].Value)
 		l.CurrentRound++
 		newInd := m.RoundNum + 1
-		return newInd, nil
+		return newInd, err
 	}
 }
 
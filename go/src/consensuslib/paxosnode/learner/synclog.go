@@ -6,31 +6,57 @@ import (
 	"sync"
 )
 
+// Ballot identifies an accept request. Proposers number their requests independently,
+// so the same ID from two proposers are two different requests.
+type Ballot struct {
+	ID       uint64
+	Proposer string
+}
+
+// BallotOf the accept request m
+func BallotOf(m *Message) Ballot {
+	return Ballot{m.ID, m.FromProposerID}
+}
+
 type SyncLog struct {
 	sync.RWMutex
-	internal map[uint64]*MessageAccepted
+	internal map[Ballot]*MessageAccepted
 }
 
 func NewSyncLog() *SyncLog {
 	return &SyncLog{
-		internal: make(map[uint64]*MessageAccepted, 0),
+		internal: make(map[Ballot]*MessageAccepted, 0),
+	}
+}
+
+// Add one acceptance of m, and return how many times it was accepted
+func (rm *SyncLog) Add(m *Message) int {
+	rm.Lock()
+	defer rm.Unlock()
+	key := BallotOf(m)
+	accepted, ok := rm.internal[key]
+	if !ok {
+		accepted = &MessageAccepted{m, 0}
+		rm.internal[key] = accepted
 	}
+	accepted.Times++
+	return accepted.Times
 }
 
-func (rm *SyncLog) Load(key uint64) (value *MessageAccepted, ok bool) {
+func (rm *SyncLog) Load(key Ballot) (value *MessageAccepted, ok bool) {
 	rm.RLock()
 	result, ok := rm.internal[key]
 	rm.RUnlock()
 	return result, ok
 }
 
-func (rm *SyncLog) Delete(key uint64) {
+func (rm *SyncLog) Delete(key Ballot) {
 	rm.Lock()
 	delete(rm.internal, key)
 	rm.Unlock()
 }
 
-func (rm *SyncLog) Store(key uint64, value *MessageAccepted) {
+func (rm *SyncLog) Store(key Ballot, value *MessageAccepted) {
 	rm.Lock()
 	rm.internal[key] = value
 	rm.Unlock()
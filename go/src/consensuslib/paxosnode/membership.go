@@ -0,0 +1,40 @@
+package paxosnode
+
+import (
+	"consensuslib/membership"
+)
+
+// EnableMembership detects failed neighbours with a SWIM failure detector, instead of removing a neighbour
+// as soon as a request to it fails. Neighbours are only removed once the detector declares them dead,
+// and members it learns of through gossip become neighbours.
+func (pn *PaxosNode) EnableMembership(config membership.Config) *membership.Membership {
+	if config.Transport == nil {
+		// gossip the same way as paxos
+		config.Transport = pn.config.Transport
+	}
+	pn.Membership = membership.New(pn.Addr, config, pn.membershipChanged)
+	pn.lock.RLock()
+	neighbours := append([]string{}, pn.NbrAddrs...)
+	pn.lock.RUnlock()
+	pn.Membership.Add(neighbours...)
+	pn.Membership.Start()
+	return pn.Membership
+}
+
+// membershipChanged applies a change of the membership view to the PN's neighbours
+func (pn *PaxosNode) membershipChanged(member membership.Member) {
+	switch member.State {
+	case membership.ALIVE:
+		if err := pn.AddNeighbour(member.Addr); err != nil {
+			nodeLog.Debugf("unable to connect to new member %v: %v", member.Addr, err)
+		}
+	case membership.DEAD:
+		if err := pn.RemoveNeighbour(member.Addr); err == nil {
+			nodeLog.Infof("dead member %v is no longer a neighbour", member.Addr)
+		}
+	case membership.LEFT:
+		if err := pn.RemoveNeighbour(member.Addr); err == nil {
+			nodeLog.Infof("member %v left, it is no longer a neighbour", member.Addr)
+		}
+	}
+}
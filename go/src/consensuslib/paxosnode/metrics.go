@@ -0,0 +1,60 @@
+package paxosnode
+
+import (
+	"consensuslib/metrics"
+	"sync"
+	"time"
+)
+
+// nodeMetrics instruments a PN's rounds, neighbours and learner
+type nodeMetrics struct {
+	prepareLatency    *metrics.Histogram
+	acceptLatency     *metrics.Histogram
+	retries           *metrics.Counter
+	neighbourFailures *metrics.Counter
+	learnLag          *metrics.Histogram
+
+	// when the learner first heard each value was accepted, by message hash.
+	// Deleted once the value is learned, so it is only observed once.
+	firstAccepted sync.Map
+}
+
+// newNodeMetrics registers the PN's metrics, reading its gauges from pn under its locks when scraped
+func newNodeMetrics(pn *PaxosNode) *nodeMetrics {
+	r := pn.Metrics
+	r.NewGauge("paxos_round", "Round this node is in", func() float64 {
+		return float64(pn.Round())
+	})
+	r.NewGauge("paxos_log_length", "Number of values in this node's learned log", func() float64 {
+		log, _ := pn.GetLog()
+		return float64(len(log))
+	})
+	r.NewGauge("paxos_neighbours", "Number of neighbours this node is connected to", func() float64 {
+		return float64(pn.neighbourCount())
+	})
+	r.NewGauge("paxos_failed_neighbours", "Number of neighbours that failed during the current round", func() float64 {
+		return float64(pn.failedCount())
+	})
+	return &nodeMetrics{
+		prepareLatency:    r.NewHistogram("paxos_prepare_duration_seconds", "Time to disseminate a prepare request and collect promises", metrics.DefaultBuckets),
+		acceptLatency:     r.NewHistogram("paxos_accept_duration_seconds", "Time to disseminate an accept request and collect acceptances", metrics.DefaultBuckets),
+		retries:           r.NewCounter("paxos_retries_total", "Rounds retried for lack of a majority"),
+		neighbourFailures: r.NewCounter("paxos_neighbour_failures_total", "Requests to neighbours that failed or timed out"),
+		learnLag:          r.NewHistogram("paxos_learn_lag_seconds", "Time from this node's learner hearing a value was accepted to learning it", metrics.DefaultBuckets),
+	}
+}
+
+// heardAccepted notes when the learner first heard m was accepted
+func (m *nodeMetrics) heardAccepted(msgHash string) {
+	m.firstAccepted.LoadOrStore(msgHash, time.Now())
+}
+
+// learned observes the learn lag of a value, the first time it is learned
+func (m *nodeMetrics) learned(msgHash string) {
+	heard, ok := m.firstAccepted.Load(msgHash)
+	if !ok {
+		return
+	}
+	m.firstAccepted.Delete(msgHash)
+	m.learnLag.ObserveSince(heard.(time.Time))
+}
@@ -2,20 +2,30 @@ package paxosnode
 
 import (
 	"consensuslib/errors"
+	"consensuslib/membership"
 	"consensuslib/message"
+	"consensuslib/metrics"
 	"consensuslib/paxosnode/acceptor"
 	"consensuslib/paxosnode/learner"
 	"consensuslib/paxosnode/proposer"
+	"consensuslib/paxosnode/trace"
+	"consensuslib/safety"
+	"consensuslib/tracing"
+	"consensuslib/transport"
 	"filelogger/singletonlogger"
 	"fmt"
 	"math/rand"
+	"net"
 	"net/rpc"
+	"path/filepath"
 	"paxostracker"
-	"regexp"
+	"sort"
 	"sync"
 	"time"
 )
 
+var nodeLog = singletonlogger.Component("paxosnode")
+
 /**
  * PaxosNode implements the interface that the rest of the consensuslib talks to.
  * It in turns make calls to internal the Learner, Acceptor, and Proposer roles.
@@ -32,20 +42,42 @@ type AcceptorRole = acceptor.AcceptorRole
 // LearnerRole Type Alias
 type LearnerRole = learner.LearnerRole
 
-var portRegex = regexp.MustCompile(":([0-9])+")
-
-// TIMER for timeouts
+// TIMER for timeouts, by default
 const TIMER = 5 * time.Second
 
-// RANDOFFSET where pick a number from 0 to RANDOFFSET to sleep between next proposals
+// RANDOFFSET where pick a number from 0 to RANDOFFSET seconds to sleep between next proposals, by default
 const RANDOFFSET = 3
 
+// Config of a PN's identity and timings
+type Config struct {
+	// DataDir the PN keeps its ID and the backups of its acceptor and learner in, temp1/PORT by default
+	DataDir string
+	// ID of the PN, such as one recorded in a trace. When empty, it is read from DataDir.
+	ID string
+	// Transport carrying the RPCs to neighbours, plain TCP when nil
+	Transport    *transport.Transport
+	Timeout      time.Duration // how long to wait on a neighbour's answer before counting it as failed
+	RetryBackoff time.Duration // a proposal that keeps failing sleeps a random time up to RetryBackoff before retrying
+	// Tracer exporting spans of the PN's writes and RPCs, when not nil. It is set before the PN serves
+	// any RPC, and whoever created it closes it.
+	Tracer *tracing.Tracer
+}
+
+// DefaultConfig waits TIMER on neighbours, and backs off up to RANDOFFSET seconds
+func DefaultConfig() Config {
+	return Config{
+		Timeout:      TIMER,
+		RetryBackoff: RANDOFFSET * time.Second,
+	}
+}
+
 // TTL for message
 const TTL = 3
 
 // PaxosNode struct
 type PaxosNode struct {
-	Addr             string // IP:port, identifier
+	ID               string // identifies the PN, and stays the same across restarts on the same data directory
+	Addr             string // IP:port the PN is reached on, which may change across restarts
 	Proposer         ProposerRole
 	Acceptor         AcceptorRole
 	Learner          LearnerRole
@@ -53,26 +85,109 @@ type PaxosNode struct {
 	Neighbours       map[string]*rpc.Client
 	FailedNeighbours []string
 	RoundNum         int
+	Tracker          *paxostracker.PaxosTracker
+	Trace            *trace.Trace           // records the PN's inputs when not nil
+	Metrics          *metrics.Registry      // the PN's metrics, for serving to a scraper
+	Tracer           *tracing.Tracer        // exports spans of the PN's writes and RPCs when not nil
+	Membership       *membership.Membership // decides which neighbours failed when not nil, see membership.go
+
+	// lock guards NbrAddrs, Neighbours and FailedNeighbours, which RPCs, the membership and the server's
+	// events all change. It is never held across an RPC.
+	lock sync.RWMutex
+	// state guards RoundNum and the learner's log, which rounds, accepted notifications and catching up
+	// all change. It is never held across an RPC either.
+	state  sync.Mutex
+	config Config
+	stats  *nodeMetrics
+	replay *replayer // answers the PN's requests from a trace while it is re-driven, see replay.go
+}
+
+// NewPaxosNode creates a Paxos Node that is linked to the client. The PN's Addr field is set as the pnAddr passed in.
+// The tracker is shared with the proposer, acceptor and learner so they report the node's rounds.
+func NewPaxosNode(pnAddr string, tracker *paxostracker.PaxosTracker) (pn *PaxosNode, err error) {
+	return NewPaxosNodeWithConfig(pnAddr, tracker, DefaultConfig())
 }
 
-// NewPaxosNode creates a Paxos Node that is linked to the client. The PN's Addr field is set as the pnAddr passed in
-func NewPaxosNode(pnAddr string) (pn *PaxosNode, err error) {
-	proposer := proposer.NewProposer(pnAddr)
-	acceptorID := portRegex.FindString(pnAddr)
-	acceptor := acceptor.NewAcceptor(acceptorID)
-	learner := learner.NewLearner()
+// NewPaxosNodeWithConfig creates a Paxos Node with the identity and timings in config
+func NewPaxosNodeWithConfig(pnAddr string, tracker *paxostracker.PaxosTracker, config Config) (pn *PaxosNode, err error) {
+	if config.DataDir == "" {
+		config.DataDir = DefaultDataDir(pnAddr)
+	}
+	id := config.ID
+	if id == "" {
+		id, err = LoadOrCreateID(config.DataDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	tracker.SetID(id)
+	proposer := proposer.NewProposer(id, tracker)
+	acceptor := acceptor.NewAcceptor(id, tracker)
+	learner := learner.NewLearner(id, tracker)
+	proposer.SetBackupDir(filepath.Join(config.DataDir, "backups") + "/")
+	acceptor.SetBackupDir(filepath.Join(config.DataDir, "backups") + "/")
+	learner.SetBackupDir(filepath.Join(config.DataDir, "backups") + "/")
+	// an acceptor must never forget what it promised or accepted, or a restarted node could break
+	// an earlier promise, so its state is always restored. Only the learned log waits for Resume.
+	acceptor.RestoreFromBackup()
+	// the network may have learned values proposed before the restart, which an audit must still find
+	if err = proposer.RestoreFromBackup(); err != nil {
+		return nil, err
+	}
 	pn = &PaxosNode{
+		ID:       id,
 		Addr:     pnAddr,
 		Proposer: proposer,
 		Acceptor: acceptor,
 		Learner:  learner,
+		Tracker:  tracker,
+		Metrics:  metrics.NewRegistry(),
+		Tracer:   config.Tracer,
+		config:   config,
 	}
-	acceptor.RestoreFromBackup()
-	singletonlogger.Debug(fmt.Sprintf("[paxosnode] after backup restoration promised value is %v", acceptor.LastPromised))
-	singletonlogger.Debug(fmt.Sprintf("[paxosnode] after backup restoration accepted value is %v", acceptor.LastAccepted))
+	pn.stats = newNodeMetrics(pn)
 	return pn, err
 }
 
+// DefaultDataDir of a PN reached on addr, temp1/PORT, so a PN restarted on the same port keeps its ID and state
+func DefaultDataDir(addr string) string {
+	if _, port, err := net.SplitHostPort(addr); err == nil {
+		return filepath.Join("temp1", port)
+	}
+	return filepath.Join("temp1", addr)
+}
+
+// DataDir the PN keeps its ID and backups in
+func (pn *PaxosNode) DataDir() string {
+	return pn.config.DataDir
+}
+
+// RestoreFromBackup the log the PN's learner saved before the PN was restarted, so it resumes from where it
+// left off rather than from an empty log. The acceptor's state was restored when the PN was created.
+func (pn *PaxosNode) RestoreFromBackup() (err error) {
+	lastPromised, lastAccepted := pn.Acceptor.State()
+	nodeLog.Debugf("after backup restoration promised value is %v", lastPromised)
+	nodeLog.Debugf("after backup restoration accepted value is %v", lastAccepted)
+	err = pn.Learner.RestoreFromBackup()
+	if err != nil {
+		return err
+	}
+	if log, _ := pn.GetLog(); len(log) != 0 {
+		pn.Proposer.UpdateMessageID(log[len(log)-1].ID)
+		pn.SetRoundNum(log[len(log)-1].RoundNum + 1)
+	}
+	pn.RecordState()
+	return nil
+}
+
+// SetBackupDir the PN's proposer, acceptor and learner save their state in and restore it from,
+// or none to keep no backups
+func (pn *PaxosNode) SetBackupDir(dir string) {
+	pn.Proposer.SetBackupDir(dir)
+	pn.Acceptor.SetBackupDir(dir)
+	pn.Learner.SetBackupDir(dir)
+}
+
 // LearnLatestValueFromNeighbours is for the inital setup
 func (pn *PaxosNode) LearnLatestValueFromNeighbours() (err error) {
 	err = pn.SetInitialLog()
@@ -81,56 +196,151 @@ func (pn *PaxosNode) LearnLatestValueFromNeighbours() (err error) {
 
 // UnmountPaxosNode closes all RPC connections with neighbours nicely
 func (pn *PaxosNode) UnmountPaxosNode() (err error) {
+	pn.lock.Lock()
 	for _, conn := range pn.Neighbours {
 		conn.Close()
 	}
 	pn.NbrAddrs = nil
+	pn.lock.Unlock()
+	if pn.Membership != nil {
+		pn.Membership.Stop()
+	}
 
 	return nil
 }
 
+// Leave the network, telling every neighbour so they stop counting this PN towards a majority
+// right away instead of waiting for it to fail
+func (pn *PaxosNode) Leave() (err error) {
+	if pn.Membership != nil {
+		pn.Membership.Leave()
+	}
+	neighbours := pn.neighbours()
+	for addr, conn := range neighbours {
+		var removed bool
+		call := conn.Go("PaxosNodeRPCWrapper.NeighbourLeaving", pn.Addr, &removed, nil)
+		select {
+		case <-call.Done:
+			if call.Error != nil {
+				nodeLog.Debugf("unable to tell %v of leaving: %v", addr, call.Error)
+			}
+		case <-time.After(pn.config.Timeout):
+			nodeLog.Debugf("timed out telling %v of leaving", addr)
+		}
+	}
+	nodeLog.Infof("left the network of %v neighbours", len(neighbours))
+	pn.Membership = nil
+	return pn.UnmountPaxosNode()
+}
+
 // WriteToPaxosNode Handles the entire process of proposing a value and trying to achieve consensus
 func (pn *PaxosNode) WriteToPaxosNode(value, msgHash string, ttl int) (success bool, err error) {
-	singletonlogger.Debug(fmt.Sprintf("[paxosnode] Writing to paxos %v TTL: %v", value, ttl))
-	prepReq := pn.Proposer.CreatePrepareRequest(pn.RoundNum, msgHash, ttl)
-	singletonlogger.Debug(fmt.Sprintf("[paxosnode] Prepare request is id: %d , val: %s, type: %d, round: %d \n", prepReq.ID, prepReq.Value, prepReq.Type, prepReq.RoundNum))
-	numAccepted, err := pn.DisseminateRequest(prepReq)
-	singletonlogger.Debug(fmt.Sprintf("[paxosnode] Pledged to accept %v", numAccepted))
+	span := pn.Tracer.Start("paxos.write", nil, tracing.INTERNAL)
+	defer span.End()
+	span.SetAttribute("paxos.node", pn.Addr)
+	span.SetAttribute("paxos.value", value)
+	span.SetAttribute("paxos.msg_hash", msgHash)
+	pn.Trace.RecordWrite(trace.Write{Value: value, MsgHash: msgHash, TTL: ttl})
+	success, err = pn.writeToPaxosNode(span, value, msgHash, ttl)
+	span.SetAttribute("paxos.success", success)
+	span.SetError(err)
+	return success, err
+}
+
+// writeToPaxosNode writes value, tracing each attempt as a child of span. A round in which another value
+// was already accepted is finished with that value first, and the write gives up after ttl such rounds.
+func (pn *PaxosNode) writeToPaxosNode(span *tracing.Span, value, msgHash string, ttl int) (success bool, err error) {
+	bounces := ttl
+	for adopted := 0; adopted < ttl; {
+		chosen, adopting, err := pn.proposeValue(span, value, msgHash, &bounces)
+		if err != nil {
+			return false, err
+		}
+		if chosen {
+			return true, nil
+		}
+		if adopting {
+			adopted++
+		}
+	}
+	return false, errors.WriteAbandonedError(value)
+}
+
+// proposeValue makes one attempt at writing value in the current round, with bounces left before backing off.
+// chosen is whether value was written, and adopted whether the round was instead spent on a value already accepted in it.
+func (pn *PaxosNode) proposeValue(span *tracing.Span, value, msgHash string, bounces *int) (chosen bool, adopted bool, err error) {
+	reqLog := nodeLog.With(singletonlogger.Fields{"node": pn.Addr, "round": pn.Round()})
+	reqLog.Debugf("Writing to paxos %v TTL: %v", value, *bounces)
+	prepReq := pn.Proposer.CreatePrepareRequest(pn.Round(), msgHash, *bounces)
+	reqLog.Debugf("Prepare request is id: %d , val: %s, type: %d, round: %d", prepReq.ID, prepReq.Value, prepReq.Type, prepReq.RoundNum)
+	phase := pn.startPhase("paxos.prepare", span, prepReq)
+	prepReq.Span = phase.Context()
+	start := time.Now()
+	numAccepted, accepted, err := pn.disseminateRequest(prepReq)
+	pn.stats.prepareLatency.ObserveSince(start)
+	pn.endPhase(phase, numAccepted, err)
+	reqLog.Debugf("Pledged to accept %v", numAccepted)
 	if err != nil {
-		singletonlogger.Error(err.Error())
-		return false, err
+		reqLog.Error(err.Error())
+		return false, false, err
 	}
 
 	// If majority is not reached, sleep for a while and try again
-	b, e := pn.ShouldRetry(numAccepted, value, &prepReq)
-	singletonlogger.Debug(fmt.Sprintf("[paxosnode] returned from should retry positively %v \n", b))
-	if b {
-		return b, e
+	if pn.backOff(numAccepted, &prepReq) {
+		*bounces = prepReq.Bounces
+		return false, false, nil
 	}
 
-	accReq := pn.Proposer.CreateAcceptRequest(value, msgHash, pn.RoundNum, prepReq.Bounces)
-	singletonlogger.Debug(fmt.Sprintf("[paxosnode] Accept request is id: %d , val: %s, type: %d \n", accReq.ID, accReq.Value, accReq.Type))
-	paxostracker.Propose(accReq.ID)
-	numAccepted, err = pn.DisseminateRequest(accReq)
+	proposal, proposalHash := value, msgHash
+	if accepted != nil {
+		// a value accepted in this round may already be chosen, so it is proposed instead,
+		// and value is written in a later round
+		reqLog.Debugf("Proposing %v accepted by an acceptor instead of %v", accepted.Value, value)
+		proposal, proposalHash = accepted.Value, accepted.MsgHash
+	}
+	accReq := pn.Proposer.CreateAcceptRequest(proposal, proposalHash, pn.Round(), prepReq.Bounces)
+	reqLog.Debugf("Accept request is id: %d , val: %s, type: %d", accReq.ID, accReq.Value, accReq.Type)
+	phase = pn.startPhase("paxos.accept", span, accReq)
+	accReq.Span = phase.Context()
+	start = time.Now()
+	numAccepted, _, err = pn.disseminateRequest(accReq)
+	pn.stats.acceptLatency.ObserveSince(start)
+	pn.endPhase(phase, numAccepted, err)
 	if err != nil {
-		return false, err
+		return false, false, err
 	}
-	singletonlogger.Debug(fmt.Sprintf("[paxosnode] Accepted %v", numAccepted))
+	reqLog.Debugf("Accepted %v", numAccepted)
 	// If majority is not reached, sleep for a while and try again
-	b, e = pn.ShouldRetry(numAccepted, value, &accReq)
-	if b {
-		return b, e
+	retried := pn.backOff(numAccepted, &accReq)
+	*bounces = accReq.Bounces
+	if retried {
+		return false, false, nil
 	}
+	return accepted == nil, accepted != nil, nil
+}
+
+// startPhase of a write, disseminating m
+func (pn *PaxosNode) startPhase(name string, write *tracing.Span, m Message) *tracing.Span {
+	phase := pn.Tracer.Start(name, write.Context(), tracing.INTERNAL)
+	phase.SetAttribute("paxos.round", pn.Round())
+	phase.SetAttribute("paxos.ballot", m.ID)
+	phase.SetAttribute("paxos.neighbours", pn.neighbourCount())
+	return phase
+}
 
-	return true, nil
+func (pn *PaxosNode) endPhase(phase *tracing.Span, numAccepted int, err error) {
+	phase.SetAttribute("paxos.accepted", numAccepted)
+	phase.SetAttribute("paxos.majority", pn.IsMajority(numAccepted))
+	phase.SetError(err)
+	phase.End()
 }
 
 // BecomeNeighbours sets up bidirectional RPC with all neighbours
 func (pn *PaxosNode) BecomeNeighbours(ips []string) (err error) {
 	for _, ip := range ips {
-		neighbourConn, err := rpc.Dial("tcp", ip)
+		neighbourConn, err := pn.config.Transport.DialRPC(ip)
 		if err != nil {
-			singletonlogger.Debug("[paxosnode]: Error in BecomeNeighbours")
+			nodeLog.Debug("Error in BecomeNeighbours")
 			return errors.NeighbourConnectionError(ip)
 		}
 		connected := false
@@ -138,12 +348,8 @@ func (pn *PaxosNode) BecomeNeighbours(ips []string) (err error) {
 		// Add ip to connectedNbrs and add the connection to Neighbours map
 		// after bidirectional RPC connection establishment is successful
 		if connected {
-			singletonlogger.Debug("[paxosnode]: connected to the nbr")
-			pn.NbrAddrs = append(pn.NbrAddrs, ip)
-			if pn.Neighbours == nil {
-				pn.Neighbours = make(map[string]*rpc.Client, 0)
-			}
-			pn.Neighbours[ip] = neighbourConn
+			nodeLog.Debug("connected to the nbr")
+			pn.addNeighbour(ip, neighbourConn, true)
 		}
 	}
 	return nil
@@ -152,16 +358,67 @@ func (pn *PaxosNode) BecomeNeighbours(ips []string) (err error) {
 // SetInitialLog when a new node joins the network by contacting all of its neighbours for their logs.
 // The new node will then set its initial log to be the longest log received from neighbours
 func (pn *PaxosNode) SetInitialLog() (err error) {
-	singletonlogger.Debug("[paxosnode] Setting the initial log for this new node")
-	maxLen := 0
-	longestLog := make([]Message, 0)
-	for k, v := range pn.Neighbours {
+	nodeLog.Debug("Setting the initial log for this new node")
+	own, _ := pn.GetLog()
+	longestLog := pn.longestNeighbourLog(own)
+	pn.state.Lock()
+	pn.Learner.InitializeLog(longestLog)
+	pn.state.Unlock()
+	pn.RecordState()
+
+	// Set a new messageId to a newly joined node to accommodate the same PSN across PaxosNW
+	logLen := len(longestLog)
+	if logLen != 0 {
+		newMsgID := longestLog[len(longestLog)-1].ID
+		pn.Proposer.UpdateMessageID(newMsgID)
+	}
+
+	return nil
+}
+
+// CatchUp a PN that fell behind, by taking the longest log of its neighbours if it is longer than its own.
+// Returns the number of values learned.
+func (pn *PaxosNode) CatchUp() (learned int, err error) {
+	own, _ := pn.GetLog()
+	before := len(own)
+	longestLog := pn.longestNeighbourLog(own)
+	pn.state.Lock()
+	if len(longestLog) <= len(pn.Learner.Log) {
+		// values may have been learned while the neighbours were read
+		pn.state.Unlock()
+		nodeLog.Debugf("Already caught up with %v values", before)
+		return 0, nil
+	}
+	err = pn.Learner.InitializeLog(longestLog)
+	last := longestLog[len(longestLog)-1]
+	pn.Proposer.UpdateMessageID(last.ID)
+	if last.RoundNum+1 > pn.RoundNum {
+		pn.RoundNum = last.RoundNum + 1
+	}
+	pn.state.Unlock()
+	if err != nil {
+		nodeLog.Errorf("unable to back up the caught up log: %s", err)
+	}
+	pn.RecordState()
+	nodeLog.Infof("Caught up from %v to %v values", before, len(longestLog))
+	return len(longestLog) - before, nil
+}
+
+// longestNeighbourLog reads the log of every neighbour and returns the longest, or own if none is longer.
+// Neighbours that fail to answer are removed.
+func (pn *PaxosNode) longestNeighbourLog(own []Message) []Message {
+	// a PN restored from its backup keeps its own log, unless a neighbour's is longer
+	maxLen := len(own)
+	longestLog := own
+	for k, v := range pn.neighbours() {
 		// Create a temporary log to get filled by neighbour learners
 		temp := make([]Message, 0)
-		singletonlogger.Debug(fmt.Sprintf("[paxosnode] Making ReadFromLearner call to node %v\n", v))
+		nodeLog.Debugf("Making ReadFromLearner call to node %v", v)
 		e := v.Call("PaxosNodeRPCWrapper.ReadFromLearner", "placeholder", &temp)
 		if e != nil {
-			pn.RemoveFailedNeighbour(k)
+			if pn.Membership == nil {
+				pn.RemoveFailedNeighbour(k)
+			}
 			continue
 		}
 		if len(temp) > maxLen {
@@ -169,172 +426,220 @@ func (pn *PaxosNode) SetInitialLog() (err error) {
 			longestLog = temp
 		}
 	}
-	pn.Learner.InitializeLog(longestLog)
-
-	// Set a new messageId to a newly joined node to accommodate the same PSN across PaxosNW
-	logLen := len(longestLog)
-	if logLen != 0 {
-		newMsgID := longestLog[len(longestLog)-1].ID
-		pn.Proposer.UpdateMessageID(newMsgID)
-	}
-
-	return nil
+	return longestLog
 }
 
 // SetRoundNum helper method
 func (pn *PaxosNode) SetRoundNum(roundNum int) {
+	pn.state.Lock()
+	defer pn.state.Unlock()
 	pn.RoundNum = roundNum
 }
 
-// GetLog of the pn's learner
+// Round the PN is currently writing to
+func (pn *PaxosNode) Round() int {
+	pn.state.Lock()
+	defer pn.state.Unlock()
+	return pn.RoundNum
+}
+
+// nextRound moves the PN on to the next round, returning it
+func (pn *PaxosNode) nextRound() int {
+	pn.state.Lock()
+	defer pn.state.Unlock()
+	pn.RoundNum++
+	return pn.RoundNum
+}
+
+// GetLog of the pn's learner, copied so it can be read while values are learned
 func (pn *PaxosNode) GetLog() (log []Message, err error) {
+	pn.state.Lock()
+	defer pn.state.Unlock()
 	log, err = pn.Learner.GetCurrentLog()
-	return log, err
+	return append(make([]Message, 0, len(log)), log...), err
+}
+
+// AuditSafety gathers the log of every neighbour and checks the network for safety violations.
+// Neighbours that cannot be reached are left out of the audit.
+func (pn *PaxosNode) AuditSafety() (violations []safety.Violation, err error) {
+	snapshot := safety.NewSnapshot()
+	snapshot.Logs[pn.Addr], err = pn.GetLog()
+	if err != nil {
+		return nil, err
+	}
+	snapshot.Proposals[pn.Addr] = pn.Proposer.GetProposals()
+	unreachable := safety.Gather(snapshot, pn.neighbours())
+	if len(unreachable) != 0 {
+		nodeLog.Debugf("audit could not reach %v", unreachable)
+	}
+	violations = safety.Check(snapshot)
+	for _, v := range violations {
+		nodeLog.Errorf("%v", v)
+	}
+	return violations, nil
 }
 
 // AcceptNeighbourConnection sets up the bi-directional RPC. A new PN joins the network and will
 // establish an RPC connection with each of the other PNs
 func (pn *PaxosNode) AcceptNeighbourConnection(addr string, result *bool) (err error) {
-	neighbourConn, err := rpc.Dial("tcp", addr)
+	neighbourConn, err := pn.config.Transport.DialRPC(addr)
 	if err != nil {
-		singletonlogger.Debug("[paxosnode] Error in AcceptNeighbourConnection")
+		nodeLog.Debug("Error in AcceptNeighbourConnection")
 		return errors.NeighbourConnectionError(addr)
 	}
-	pn.NbrAddrs = append(pn.NbrAddrs, addr)
-	if pn.Neighbours == nil {
-		pn.Neighbours = make(map[string]*rpc.Client, 0)
-	}
-	pn.Neighbours[addr] = neighbourConn
-
-	neighbors := ""
-	for _, n := range pn.Neighbours {
-		neighbors += fmt.Sprintf("%v ", n)
-	}
-	singletonlogger.Debug(fmt.Sprintf("[paxosnode] after neigh connection we have length '%v' and neighbours %v", len(pn.Neighbours), neighbors))
+	// the neighbour may have been added when the server announced it, or be a PN that restarted and
+	// rejoined under the same address, in which case the old connection is broken
+	pn.addNeighbour(addr, neighbourConn, true)
+	nodeLog.Debugf("after neigh connection we have %v neighbours", pn.neighbourCount())
 	*result = true
 	return nil
 }
 
 // DisseminateRequest sends a message to all neighbours. This includes prepare and accept requests.
 func (pn *PaxosNode) DisseminateRequest(prepReq Message) (numAccepted int, err error) {
-	singletonlogger.Debug(fmt.Sprintf("[paxosnode] Disseminate request %v", prepReq.Type))
+	numAccepted, _, err = pn.disseminateRequest(prepReq)
+	return numAccepted, err
+}
+
+// disseminateRequest sends a message to all neighbours. For a prepare request, it also returns the highest
+// accept request the acceptors that promised had already accepted in the round, which must be proposed instead.
+func (pn *PaxosNode) disseminateRequest(prepReq Message) (numAccepted int, adopted *Message, err error) {
+	reqLog := nodeLog.With(singletonlogger.Fields{"node": pn.Addr, "round": pn.Round(), "ballot": prepReq.ID})
+	reqLog.Debugf("Disseminate request %v", prepReq.Type)
 	numAccepted = 0
 	switch prepReq.Type {
 	case message.PREPARE:
-		singletonlogger.Debug("[paxosnode] PREPARE")
+		reqLog.Debug("PREPARE")
 
 		// Set up timer and channel for responses
-		timer := time.NewTimer(TIMER)
+		timer := time.NewTimer(pn.config.Timeout)
 		defer timer.Stop()
 		go func() {
 			<-timer.C
 		}()
 
-		nghbrNum := len(pn.Neighbours)
-		c := make(chan Message, nghbrNum)
-		errQueue := make(chan error, nghbrNum)
+		neighbours := pn.neighbours()
+		nghbrNum := len(neighbours)
 		var wg sync.WaitGroup
+		var counting sync.Mutex
 		wg.Add(nghbrNum)
 
 		// first send it to ourselves
-		resp := pn.Acceptor.ProcessPrepare(prepReq, pn.RoundNum)
+		resp, err := pn.processLocal("ProcessPrepare", prepReq)
+		if err != nil {
+			return 0, nil, err
+		}
+		adopt := func(promise *Message) {
+			if promise.Accepted != nil && (adopted == nil || promise.Accepted.Outranks(adopted)) {
+				adopted = promise.Accepted
+			}
+		}
 		if resp.Equals(&prepReq) {
 			numAccepted++
-			singletonlogger.Debug(fmt.Sprintf("[paxosnode] I pledged and the # is %v", numAccepted))
+			adopt(&resp)
+			reqLog.Debugf("I pledged and the # is %v", numAccepted)
 		}
 
-		for k, v := range pn.Neighbours {
+		for k, v := range neighbours {
 
-			singletonlogger.Debug(fmt.Sprintf("[paxosnode] disseminating to neighbour %v", k))
+			reqLog.Debugf("disseminating to neighbour %v", k)
 
 			go func(v *rpc.Client, k string) {
 				defer wg.Done()
 				var respReq Message
-				singletonlogger.Debug(fmt.Sprintf("[paxosnode] disseminating to neighbour inside %v and RPC %v", k, v))
-				errQueue <- v.Call("PaxosNodeRPCWrapper.ProcessPrepareRequest", prepReq, &respReq)
-				c <- respReq
-				select {
-				case err := <-errQueue:
-					singletonlogger.Debug("[paxosnode] channel worked on PREPARE")
-					if err != nil {
-						pn.FailedNeighbours = append(pn.FailedNeighbours, k)
-						singletonlogger.Debug(fmt.Sprintf("[paxosnode] on PREPARE RPC failed %v", k))
-					} else {
-						req := <-c
-						if prepReq.Equals(&req) {
-							numAccepted++
-							singletonlogger.Debug(fmt.Sprintf("[paxosnode] on PREPARE RPC succeded %v numPledged: %v, ID: %v", req.FromProposerID, numAccepted, req.ID))
-						}
-					}
-				case <-time.After(TIMER):
-					pn.FailedNeighbours = append(pn.FailedNeighbours, k)
+				reqLog.Debugf("disseminating to neighbour inside %v", k)
+				req := prepReq
+				req.Clock = singletonlogger.PrepareSend(pn.ID, fmt.Sprintf("send PREPARE %v to %s", prepReq.ID, k))
+				call := pn.Tracer.Start("PaxosNodeRPCWrapper.ProcessPrepareRequest", prepReq.Span, tracing.CLIENT)
+				call.SetAttribute("net.peer.name", k)
+				req.Span = call.Context()
+				err := pn.ask(k, v, "ProcessPrepareRequest", req, &respReq)
+				call.SetError(err)
+				call.End()
+				if err != nil {
+					pn.neighbourFailed(k)
+					reqLog.Debugf("on PREPARE RPC failed %v", k)
+					return
+				}
+				singletonlogger.UnpackReceive(pn.ID, fmt.Sprintf("receive PREPARE reply %v from %s", respReq.ID, k), respReq.Clock)
+				if prepReq.Equals(&respReq) {
+					counting.Lock()
+					numAccepted++
+					adopt(&respReq)
+					reqLog.Debugf("on PREPARE RPC succeded %v numPledged: %v, ID: %v", respReq.FromProposerID, numAccepted, respReq.ID)
+					counting.Unlock()
 				}
 			}(v, k)
 
 		}
 		wg.Wait()
-		if len(pn.FailedNeighbours) >= len(pn.Neighbours)/2 && len(pn.FailedNeighbours) != 0 {
-			singletonlogger.Debug(fmt.Sprintf("[paxosnode] checking failed nbrs %v", len(pn.FailedNeighbours)))
-			return numAccepted, nil
+		if failed := pn.failedCount(); failed >= nghbrNum/2 && failed != 0 {
+			reqLog.Debugf("checking failed nbrs %v", failed)
+			return numAccepted, adopted, nil
 		}
 
-		return numAccepted, nil
+		return numAccepted, adopted, nil
 
 	case message.ACCEPT:
-		singletonlogger.Debug("[paxosnode] ACCEPT")
-		nghbrNum := len(pn.Neighbours)
-		c := make(chan Message, nghbrNum)
-		errQueue := make(chan error, nghbrNum)
+		reqLog.Debug("ACCEPT")
+		neighbours := pn.neighbours()
+		nghbrNum := len(neighbours)
 		var wg sync.WaitGroup
+		var counting sync.Mutex
 		wg.Add(nghbrNum)
 
 		// last send it to ourselves
-		resp := pn.Acceptor.ProcessAccept(prepReq, pn.RoundNum)
+		resp, err := pn.processLocal("ProcessAccept", prepReq)
+		if err != nil {
+			return 0, nil, err
+		}
 		if resp.Equals(&prepReq) {
 			numAccepted++
-			singletonlogger.Debug(fmt.Sprintf("[paxosnode] I accepted and the # is %v", numAccepted))
+			reqLog.Debugf("I accepted and the # is %v", numAccepted)
 			pn.SayAccepted(&prepReq)
 		}
 
-		for k, v := range pn.Neighbours {
+		for k, v := range neighbours {
 
 			go func(k string, v *rpc.Client) {
 				defer wg.Done()
 				var respReq Message
-				singletonlogger.Debug(fmt.Sprintf("[paxosnode] disseminating ACCEPT to neighbour %v", k))
-				errQueue <- v.Call("PaxosNodeRPCWrapper.ProcessAcceptRequest", prepReq, &respReq)
-				c <- respReq
-				select {
-				case err := <-errQueue:
-					singletonlogger.Debug("[paxosnode] channel worked on ACCEPT")
-					if err != nil {
-						pn.FailedNeighbours = append(pn.FailedNeighbours, k)
-						singletonlogger.Debug(fmt.Sprintf("[paxosnode] on ACCEPT RPC failed %v", k))
-					} else {
-						req := <-c
-						if prepReq.Equals(&req) {
-							numAccepted++
-							singletonlogger.Debug(fmt.Sprintf("[paxosnode] on ACCEPT RPC succeded %v numAccepted: %vID: %v", req.FromProposerID, numAccepted, req.ID))
-						}
-					}
-				case <-time.After(TIMER):
-					pn.FailedNeighbours = append(pn.FailedNeighbours, k)
+				reqLog.Debugf("disseminating ACCEPT to neighbour %v", k)
+				req := prepReq
+				req.Clock = singletonlogger.PrepareSend(pn.ID, fmt.Sprintf("send ACCEPT %v %q to %s", prepReq.ID, prepReq.Value, k))
+				call := pn.Tracer.Start("PaxosNodeRPCWrapper.ProcessAcceptRequest", prepReq.Span, tracing.CLIENT)
+				call.SetAttribute("net.peer.name", k)
+				req.Span = call.Context()
+				err := pn.ask(k, v, "ProcessAcceptRequest", req, &respReq)
+				call.SetError(err)
+				call.End()
+				if err != nil {
+					pn.neighbourFailed(k)
+					reqLog.Debugf("on ACCEPT RPC failed %v", k)
+					return
+				}
+				singletonlogger.UnpackReceive(pn.ID, fmt.Sprintf("receive ACCEPT reply %v from %s", respReq.ID, k), respReq.Clock)
+				if prepReq.Equals(&respReq) {
+					counting.Lock()
+					numAccepted++
+					reqLog.Debugf("on ACCEPT RPC succeded %v numAccepted: %vID: %v", respReq.FromProposerID, numAccepted, respReq.ID)
+					counting.Unlock()
 				}
 			}(k, v)
 		}
 
 		wg.Wait()
 
-		if len(pn.FailedNeighbours) >= len(pn.Neighbours)/2 && len(pn.FailedNeighbours) != 0 {
-			singletonlogger.Debug(fmt.Sprintf("[paxosnode] checking failed nbrs %v", len(pn.FailedNeighbours)))
-			pn.RoundNum++
-			return numAccepted, nil
+		if failed := pn.failedCount(); failed >= nghbrNum/2 && failed != 0 {
+			reqLog.Debugf("checking failed nbrs %v", failed)
+			pn.nextRound()
+			return numAccepted, adopted, nil
 		}
 
-		return numAccepted, nil
+		return numAccepted, adopted, nil
 
 	default:
-		return -1, errors.InvalidMessageTypeError(prepReq)
+		return -1, nil, errors.InvalidMessageTypeError(prepReq)
 	}
 }
 
@@ -344,21 +649,77 @@ func (pn *PaxosNode) SayAccepted(m *Message) {
 	pn.CountForNumAlreadyAccepted(m)
 	// then to all other nodes' learners
 
-	for k, v := range pn.Neighbours {
+	for k, v := range pn.neighbours() {
 		go func(k string, v *rpc.Client) {
 			var counted bool
-			e := v.Call("PaxosNodeRPCWrapper.NotifyAboutAccepted", m, &counted)
+			notify := *m
+			notify.Clock = singletonlogger.PrepareSend(pn.ID, fmt.Sprintf("send ACCEPTED %v %q to %s", m.ID, m.Value, k))
+			call := pn.Tracer.Start("PaxosNodeRPCWrapper.NotifyAboutAccepted", m.Span, tracing.CLIENT)
+			call.SetAttribute("net.peer.name", k)
+			notify.Span = call.Context()
+			e := pn.ask(k, v, "NotifyAboutAccepted", &notify, &counted)
+			call.SetError(e)
+			call.End()
 			if e != nil {
-				pn.FailedNeighbours = append(pn.FailedNeighbours, k)
+				pn.neighbourFailed(k)
 			}
 		}(k, v)
 
 	}
 }
 
+// processLocal makes a request of the PN's own acceptor in the current round, which is recorded in the trace.
+// When replaying, the request waits until the replay reaches it.
+func (pn *PaxosNode) processLocal(method string, m Message) (resp Message, err error) {
+	if pn.replay != nil {
+		h, err := pn.replay.await(trace.LOCAL, method, "", m)
+		if err != nil {
+			return resp, err
+		}
+		defer func() {
+			h.answer <- resp
+		}()
+	}
+	if method == "ProcessPrepare" {
+		resp = pn.Acceptor.ProcessPrepare(m, pn.Round())
+	} else {
+		resp = pn.Acceptor.ProcessAccept(m, pn.Round())
+	}
+	pn.Trace.RecordLocal(method, pn.Round(), m, resp)
+	return resp, nil
+}
+
+// ask the neighbour at k to handle method, waiting on its reply for at most the timeout. The reply,
+// or the timeout firing, is recorded in the trace, or taken from the trace when replaying.
+func (pn *PaxosNode) ask(k string, v *rpc.Client, method string, arg interface{}, reply interface{}) error {
+	if pn.replay != nil {
+		return pn.replay.call(k, method, arg, reply)
+	}
+	call := v.Go("PaxosNodeRPCWrapper."+method, arg, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		pn.Trace.RecordCall(method, k, arg, reply, call.Error)
+		return call.Error
+	case <-time.After(pn.config.Timeout):
+		pn.Trace.RecordTimer(method, k, arg)
+		return errors.TimeoutError(k)
+	}
+}
+
+// randIntn makes a random choice in [0, n) on behalf of method, which is recorded in the trace,
+// or taken from the trace when replaying
+func (pn *PaxosNode) randIntn(method string, n int) int {
+	if pn.replay != nil {
+		return pn.replay.random(method)
+	}
+	value := rand.Intn(n)
+	pn.Trace.RecordRandom(method, value)
+	return value
+}
+
 // IsMajority helper method
 func (pn *PaxosNode) IsMajority(n int) bool {
-	if n > (len(pn.Neighbours)+1)/2 {
+	if n > (pn.neighbourCount()+1)/2 {
 		return true
 	}
 	return false
@@ -367,54 +728,245 @@ func (pn *PaxosNode) IsMajority(n int) bool {
 // CountForNumAlreadyAccepted takes role of Learner, adds Accepted message to the map of accepted messages,
 // and notifies learner when the # for this particular message is a majority to write into the log
 func (pn *PaxosNode) CountForNumAlreadyAccepted(m *Message) {
-	singletonlogger.Debug(fmt.Sprintf("[paxosnode] in CountForNumAlreadyAccepted, round # %v", pn.RoundNum))
+	nodeLog.Debugf("in CountForNumAlreadyAccepted, round # %v", pn.Round())
 	numSeen := pn.Learner.NumAlreadyAccepted(m)
-	singletonlogger.Debug(fmt.Sprintf("[paxosnode] in CountForNumAlreadyAccepted, how many accepted %v", numSeen))
+	if numSeen == 1 {
+		// later notifications of the same acceptance must not start timing a value learned already
+		pn.stats.heardAccepted(m.MsgHash)
+	}
+	nodeLog.Debugf("in CountForNumAlreadyAccepted, how many accepted %v", numSeen)
 	if pn.IsMajority(numSeen) {
-		pn.RoundNum, _ = pn.Learner.LearnValue(m)
-		singletonlogger.Debug(fmt.Sprintf("[paxosnode] in CountForNumAlreadyAccepted, value learned, next round # %v", pn.RoundNum))
+		span := pn.Tracer.Start("paxos.learn", m.Span, tracing.INTERNAL)
+		span.SetAttribute("paxos.ballot", m.ID)
+		span.SetAttribute("paxos.accepted", numSeen)
+		pn.state.Lock()
+		round, err := pn.Learner.LearnValue(m)
+		pn.RoundNum = round
+		pn.state.Unlock()
+		if err != nil {
+			nodeLog.Errorf("learning %v: %s", m.ID, err)
+		}
+		span.SetAttribute("paxos.round", round)
+		span.End()
+		pn.stats.learned(m.MsgHash)
+		nodeLog.Debugf("in CountForNumAlreadyAccepted, value learned, next round # %v", round)
 	}
 
 }
 
-// ShouldRetry checks if the round should be retried due to a lack of majority
+// ShouldRetry checks if the round should be retried due to a lack of majority, and retries the write if so
 func (pn *PaxosNode) ShouldRetry(numAccepted int, value string, m *Message) (b bool, err error) {
-	if !pn.IsMajority(numAccepted) {
-		singletonlogger.Debug("[paxosnode] We're retrying")
-		m.Bounces--
-		if m.Bounces == 0 {
-			randOffset := time.Duration(rand.Intn(RANDOFFSET))
-			singletonlogger.Debug(fmt.Sprintf("[paxosnode] sleeping for %v", randOffset))
-			time.Sleep(randOffset * time.Second)
-			m.Bounces = TTL
-		}
-		// Before retrying, we must clear the failed neighbours
-		pn.ClearFailedNeighbours()
-		pn.NotifyOfMajorityFailure()
-		numAccepted = 0
-		b, err = pn.WriteToPaxosNode(value, m.MsgHash, m.Bounces)
+	if pn.backOff(numAccepted, m) {
+		b, err = pn.writeToPaxosNode(nil, value, m.MsgHash, m.Bounces)
 	}
 	return b, err
 }
 
+// backOff before a retry if numAccepted is not a majority, sleeping once m has no bounces left
+func (pn *PaxosNode) backOff(numAccepted int, m *Message) bool {
+	if pn.IsMajority(numAccepted) {
+		return false
+	}
+	nodeLog.Debug("We're retrying")
+	pn.stats.retries.Inc()
+	m.Bounces--
+	if m.Bounces == 0 {
+		randOffset := time.Duration(0)
+		if backoff := int(pn.config.RetryBackoff / time.Millisecond); backoff > 0 {
+			randOffset = time.Duration(pn.randIntn("ShouldRetry", backoff)) * time.Millisecond
+		}
+		nodeLog.Debugf("sleeping for %v", randOffset)
+		if pn.replay == nil {
+			// a replayed write retries once the replay reaches its requests instead
+			time.Sleep(randOffset)
+		}
+		m.Bounces = TTL
+	}
+	// Before retrying, we must clear the failed neighbours
+	pn.ClearFailedNeighbours()
+	pn.NotifyOfMajorityFailure()
+	return true
+}
+
+// neighbourFailed records that a request to the neighbour at k failed in this round
+func (pn *PaxosNode) neighbourFailed(k string) {
+	pn.lock.Lock()
+	pn.FailedNeighbours = append(pn.FailedNeighbours, k)
+	pn.lock.Unlock()
+	pn.stats.neighbourFailures.Inc()
+}
+
+// failedCount of the requests to neighbours that failed in this round
+func (pn *PaxosNode) failedCount() int {
+	pn.lock.RLock()
+	defer pn.lock.RUnlock()
+	return len(pn.FailedNeighbours)
+}
+
 // ClearFailedNeighbours removes failed neighbors from a pn's collection
 func (pn *PaxosNode) ClearFailedNeighbours() {
+	pn.lock.Lock()
+	// with a membership, failed neighbours are only removed once it declares them dead
 	for _, ip := range pn.FailedNeighbours {
-		pn.RemoveFailedNeighbour(ip)
+		if pn.Membership == nil {
+			pn.removeNeighbour(ip)
+		}
 	}
 	pn.FailedNeighbours = nil
-	pn.RoundNum++
-	singletonlogger.Debug(fmt.Sprintf("[paxosnode] cleaned nbrs, new round is # %v", pn.RoundNum))
+	pn.lock.Unlock()
+	round := pn.nextRound()
+	nodeLog.Debugf("cleaned nbrs, new round is # %v", round)
 }
 
 // RemoveFailedNeighbour removes a single neighbour
 func (pn *PaxosNode) RemoveFailedNeighbour(ip string) {
+	pn.lock.Lock()
+	defer pn.lock.Unlock()
+	pn.removeNeighbour(ip)
+}
+
+// AddNeighbour that joined the network, unless it already is one. Unlike BecomeNeighbours,
+// the neighbour is not asked to connect back, as it learns of this PN the same way.
+func (pn *PaxosNode) AddNeighbour(ip string) (err error) {
+	if pn.IsNeighbour(ip) || ip == pn.Addr {
+		return nil
+	}
+	neighbourConn, err := pn.config.Transport.DialRPC(ip)
+	if err != nil {
+		return errors.NeighbourConnectionError(ip)
+	}
+	if !pn.addNeighbour(ip, neighbourConn, false) {
+		// added by someone else while dialling
+		neighbourConn.Close()
+		return nil
+	}
+	nodeLog.Infof("added neighbour %v", ip)
+	return nil
+}
+
+// RemoveNeighbour that is known to be dead, and close the connection to it
+func (pn *PaxosNode) RemoveNeighbour(ip string) (err error) {
+	pn.lock.Lock()
+	conn, ok := pn.Neighbours[ip]
+	if ok {
+		pn.removeNeighbour(ip)
+		pn.forgetFailure(ip)
+	}
+	pn.lock.Unlock()
+	if !ok {
+		return errors.UnknownNeighbourError(ip)
+	}
+	conn.Close()
+	nodeLog.Infof("removed neighbour %v", ip)
+	return nil
+}
+
+// IsNeighbour tells whether the PN at ip is a neighbour
+func (pn *PaxosNode) IsNeighbour(ip string) bool {
+	pn.lock.RLock()
+	defer pn.lock.RUnlock()
+	_, ok := pn.Neighbours[ip]
+	return ok
+}
+
+// IsMember says whether ip is a neighbour of the PN, or a live member of its membership
+func (pn *PaxosNode) IsMember(ip string) bool {
+	if pn.IsNeighbour(ip) {
+		return true
+	}
+	m := pn.Membership
+	return m != nil && m.IsMember(ip)
+}
+
+// neighbours of the PN, copied so they can be called without holding the lock
+func (pn *PaxosNode) neighbours() map[string]*rpc.Client {
+	pn.lock.RLock()
+	defer pn.lock.RUnlock()
+	neighbours := make(map[string]*rpc.Client, len(pn.Neighbours))
+	for k, v := range pn.Neighbours {
+		neighbours[k] = v
+	}
+	return neighbours
+}
+
+// neighbourCount of the PN
+func (pn *PaxosNode) neighbourCount() int {
+	pn.lock.RLock()
+	defer pn.lock.RUnlock()
+	return len(pn.Neighbours)
+}
+
+// addNeighbour with the connection to it. A neighbour that is already known has its connection replaced
+// when replace is set, and is otherwise left alone. Returns whether conn is now the neighbour's connection.
+func (pn *PaxosNode) addNeighbour(ip string, conn *rpc.Client, replace bool) bool {
+	pn.lock.Lock()
+	old, ok := pn.Neighbours[ip]
+	switch {
+	case ok && !replace:
+		pn.lock.Unlock()
+		return false
+	case ok:
+		old.Close()
+	default:
+		pn.NbrAddrs = append(pn.NbrAddrs, ip)
+	}
+	if pn.Neighbours == nil {
+		pn.Neighbours = make(map[string]*rpc.Client, 0)
+	}
+	pn.Neighbours[ip] = conn
+	pn.recordNeighbours()
+	pn.lock.Unlock()
+	if pn.Membership != nil {
+		pn.Membership.Add(ip)
+	}
+	return true
+}
+
+// removeNeighbour from the PN's neighbours, holding the lock
+func (pn *PaxosNode) removeNeighbour(ip string) {
 	delete(pn.Neighbours, ip)
-	pn.RemoveNbrAddr(ip)
+	pn.removeNbrAddr(ip)
+	pn.recordNeighbours()
+}
+
+// recordNeighbours in the trace after they changed, holding the lock
+func (pn *PaxosNode) recordNeighbours() {
+	if pn.Trace != nil {
+		pn.Trace.RecordNeighbours(pn.neighbourAddrs())
+	}
+}
+
+// neighbourAddrs of the PN in order, holding the lock
+func (pn *PaxosNode) neighbourAddrs() []string {
+	addrs := make([]string, 0, len(pn.Neighbours))
+	for ip := range pn.Neighbours {
+		addrs = append(addrs, ip)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// forgetFailure of the neighbour at ip in this round, holding the lock, so the neighbour it no longer
+// has is not counted towards a majority failure
+func (pn *PaxosNode) forgetFailure(ip string) {
+	failed := pn.FailedNeighbours[:0]
+	for _, v := range pn.FailedNeighbours {
+		if v != ip {
+			failed = append(failed, v)
+		}
+	}
+	pn.FailedNeighbours = failed
 }
 
 // RemoveNbrAddr removes a Neighbour's addreess
 func (pn *PaxosNode) RemoveNbrAddr(ip string) {
+	pn.lock.Lock()
+	defer pn.lock.Unlock()
+	pn.removeNbrAddr(ip)
+}
+
+// removeNbrAddr holding the lock
+func (pn *PaxosNode) removeNbrAddr(ip string) {
 	for i, v := range pn.NbrAddrs {
 		if v == ip {
 			pn.NbrAddrs = append(pn.NbrAddrs[:i], pn.NbrAddrs[i+1:]...)
@@ -425,60 +977,44 @@ func (pn *PaxosNode) RemoveNbrAddr(ip string) {
 
 // NotifyOfMajorityFailure helper
 func (pn *PaxosNode) NotifyOfMajorityFailure() {
-	nghbrNum := len(pn.Neighbours)
+	if pn.Membership != nil {
+		// the membership gossips failures instead
+		return
+	}
+	neighbours := pn.neighbours()
+	nghbrNum := len(neighbours)
 	var wg sync.WaitGroup
 	wg.Add(nghbrNum)
-	var b bool
-	c := make(chan bool, nghbrNum)
-	errQueue := make(chan error, nghbrNum)
 
-	for k, v := range pn.Neighbours {
+	for k, v := range neighbours {
 		go func(k string, v *rpc.Client) {
 			defer wg.Done()
-			errQueue <- v.Call("PaxosNodeRPCWrapper.CleanYourNeighbours", k, &b)
-			c <- b
-
-			select {
-			case err := <-errQueue:
-				singletonlogger.Debug("[paxosnode] channel worked on MAJOR FAILURE")
-				if err != nil {
-					pn.FailedNeighbours = append(pn.FailedNeighbours, k)
-					singletonlogger.Debug(fmt.Sprintf("[paxosnode] on MAJOR FAILURE RPC failed %v", k))
-				}
-			case <-time.After(TIMER):
-				pn.FailedNeighbours = append(pn.FailedNeighbours, k)
+			var b bool
+			if err := pn.ask(k, v, "CleanYourNeighbours", k, &b); err != nil {
+				pn.neighbourFailed(k)
+				nodeLog.Debugf("on MAJOR FAILURE RPC failed %v", k)
 			}
 		}(k, v)
 	}
 	wg.Wait()
-	singletonlogger.Debug(fmt.Sprintf("[paxosnode] notified nbrs, new round is # %v", pn.RoundNum))
+	nodeLog.Debugf("notified nbrs, new round is # %v", pn.Round())
 }
 
 // CleanNbrsOnRequest to remove neighbours when requested
 func (pn *PaxosNode) CleanNbrsOnRequest(neighbour string) (b bool) {
-	nghbrNum := len(pn.Neighbours) - 1
+	neighbours := pn.neighbours()
+	delete(neighbours, neighbour)
+	nghbrNum := len(neighbours)
 	var wg sync.WaitGroup
 	wg.Add(nghbrNum)
-	c := make(chan bool, nghbrNum)
-	errQueue := make(chan error, nghbrNum)
 
-	for k, v := range pn.Neighbours {
-		if k == neighbour {
-			continue
-		}
+	for k, v := range neighbours {
 		go func(k string, v *rpc.Client) {
 			defer wg.Done()
-			errQueue <- v.Call("PaxosNodeRPCWrapper.RUAlive", k, &b)
-			c <- b
-			select {
-			case err := <-errQueue:
-				singletonlogger.Debug("[paxosnode] channel worked on CLEANING")
-				if err != nil {
-					pn.FailedNeighbours = append(pn.FailedNeighbours, k)
-					singletonlogger.Debug(fmt.Sprintf("[paxosnode] on CLEANING failed %v", k))
-				}
-			case <-time.After(TIMER):
-				pn.FailedNeighbours = append(pn.FailedNeighbours, k)
+			var b bool
+			if err := pn.ask(k, v, "RUAlive", k, &b); err != nil {
+				pn.neighbourFailed(k)
+				nodeLog.Debugf("on CLEANING failed %v", k)
 			}
 		}(k, v)
 	}
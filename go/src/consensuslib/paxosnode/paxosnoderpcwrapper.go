@@ -5,10 +5,14 @@ package paxosnode
 
 import (
 	"consensuslib/message"
+	"consensuslib/tracing"
 	"filelogger/singletonlogger"
 	"fmt"
+	"paxostracker"
 )
 
+var wrapperLog = singletonlogger.Component("paxosnodewrapper")
+
 type Message = message.Message
 
 type PaxosNodeRPCWrapper struct {
@@ -24,27 +28,54 @@ func NewPaxosNodeRPCWrapper(paxosNode *PaxosNode) (wrapper *PaxosNodeRPCWrapper,
 
 // RPC to a PN's acceptor to process a new Prepare Request
 func (p *PaxosNodeRPCWrapper) ProcessPrepareRequest(m Message, r *Message) (err error) {
-	singletonlogger.Debug("[paxosnodewrapper] increasing message ID")
+	defer p.paxosNode.Trace.RecordRPC("ProcessPrepareRequest", p.paxosNode.Round(), m, r)
+	singletonlogger.UnpackReceive(p.paxosNode.ID, fmt.Sprintf("receive PREPARE %v from %s", m.ID, m.FromProposerID), m.Clock)
+	span := p.paxosNode.Tracer.Start("PaxosNodeRPCWrapper.ProcessPrepareRequest", m.Span, tracing.SERVER)
+	defer span.End()
+	span.SetAttribute("paxos.ballot", m.ID)
+	span.SetAttribute("paxos.proposer", m.FromProposerID)
+	wrapperLog.Debug("increasing message ID")
 	p.paxosNode.Proposer.IncrementMessageID()
-	*r = p.paxosNode.Acceptor.ProcessPrepare(m, p.paxosNode.RoundNum)
+	*r = p.paxosNode.Acceptor.ProcessPrepare(m, p.paxosNode.Round())
+	r.Span = nil
+	span.SetAttribute("paxos.promised", r.ID)
+	r.Clock = singletonlogger.PrepareSend(p.paxosNode.ID, fmt.Sprintf("reply PREPARE %v to %s with promise for %v", m.ID, m.FromProposerID, r.ID))
 	return nil
 }
 
 // RPC to a PN's acceptor to process a new Accept Request
 // If the request accepted, it gets disseminated to all the Learners in the Paxos NW
 func (p *PaxosNodeRPCWrapper) ProcessAcceptRequest(m Message, r *Message) (err error) {
-	singletonlogger.Debug("[paxosnodewrapper] RPC processing accept request")
-	*r = p.paxosNode.Acceptor.ProcessAccept(m, p.paxosNode.RoundNum)
+	defer p.paxosNode.Trace.RecordRPC("ProcessAcceptRequest", p.paxosNode.Round(), m, r)
+	singletonlogger.UnpackReceive(p.paxosNode.ID, fmt.Sprintf("receive ACCEPT %v %q from %s", m.ID, m.Value, m.FromProposerID), m.Clock)
+	span := p.paxosNode.Tracer.Start("PaxosNodeRPCWrapper.ProcessAcceptRequest", m.Span, tracing.SERVER)
+	defer span.End()
+	span.SetAttribute("paxos.ballot", m.ID)
+	span.SetAttribute("paxos.proposer", m.FromProposerID)
+	wrapperLog.Debug("RPC processing accept request")
+	*r = p.paxosNode.Acceptor.ProcessAccept(m, p.paxosNode.Round())
+	r.Span = nil
+	span.SetAttribute("paxos.accepted", r.ID)
+	r.Clock = singletonlogger.PrepareSend(p.paxosNode.ID, fmt.Sprintf("reply ACCEPT %v to %s with accepted %v", m.ID, m.FromProposerID, r.ID))
 	if m.Equals(r) {
-		singletonlogger.Debug("[paxosnodewrapper] saying accepted")
-		go p.paxosNode.SayAccepted(r)
+		wrapperLog.Debug("saying accepted")
+		// the notifications to learners are traced as children of this request
+		accepted := *r
+		accepted.Span = span.Context()
+		if p.paxosNode.replay != nil {
+			// keep the learner's order deterministic while replaying
+			p.paxosNode.SayAccepted(&accepted)
+		} else {
+			go p.paxosNode.SayAccepted(&accepted)
+		}
 	}
 	return nil
 }
 
 // RPC which is called by another node that tries to connect to the current one
 func (p *PaxosNodeRPCWrapper) ConnectRemoteNeighbour(addr string, r *bool) (err error) {
-	singletonlogger.Debug("[paxoswrapper] connecting my remote neighbour")
+	defer p.paxosNode.Trace.RecordRPC("ConnectRemoteNeighbour", p.paxosNode.Round(), addr, r)
+	wrapperLog.Debug("connecting my remote neighbour")
 	err = p.paxosNode.AcceptNeighbourConnection(addr, r)
 	//singletonlogger.Debug("[paxoswrapper] error on connection? ", *r)
 	return err
@@ -52,28 +83,88 @@ func (p *PaxosNodeRPCWrapper) ConnectRemoteNeighbour(addr string, r *bool) (err
 
 // RPC to the Learner from other node's Acceptor about value it accepted
 func (p *PaxosNodeRPCWrapper) NotifyAboutAccepted(m *Message, r *bool) (err error) {
-	singletonlogger.Debug(fmt.Sprintf("[paxosnodewrapper] notify about accepted %v", m.Type))
-	p.paxosNode.CountForNumAlreadyAccepted(m)
+	defer p.paxosNode.Trace.RecordRPC("NotifyAboutAccepted", p.paxosNode.Round(), m, r)
+	singletonlogger.UnpackReceive(p.paxosNode.ID, fmt.Sprintf("receive ACCEPTED %v %q", m.ID, m.Value), m.Clock)
+	span := p.paxosNode.Tracer.Start("PaxosNodeRPCWrapper.NotifyAboutAccepted", m.Span, tracing.SERVER)
+	defer span.End()
+	span.SetAttribute("paxos.ballot", m.ID)
+	wrapperLog.Debugf("notify about accepted %v", m.Type)
+	counted := *m
+	counted.Span = span.Context()
+	p.paxosNode.CountForNumAlreadyAccepted(&counted)
 	return err
 }
 
 // RPC from a new PN that joined the network and needs to read
 // the state of the log from every other PN's learner
 func (p *PaxosNodeRPCWrapper) ReadFromLearner(placeholder string, log *[]Message) (err error) {
+	defer p.paxosNode.Trace.RecordRPC("ReadFromLearner", p.paxosNode.Round(), placeholder, log)
 	*log, err = p.paxosNode.GetLog()
 	return nil
 }
 
+// RPC from an auditor that needs every value this PN's proposer has proposed,
+// keyed by message hash
+func (p *PaxosNodeRPCWrapper) ReadProposals(placeholder string, proposals *map[string]string) (err error) {
+	defer p.paxosNode.Trace.RecordRPC("ReadProposals", p.paxosNode.Round(), placeholder, proposals)
+	*proposals = p.paxosNode.Proposer.GetProposals()
+	return nil
+}
+
+// RPC from a debugging tool that needs this PN's tracked rounds, to merge them with other PNs
+func (p *PaxosNodeRPCWrapper) ReadRounds(placeholder string, export *paxostracker.Export) (err error) {
+	defer p.paxosNode.Trace.RecordRPC("ReadRounds", p.paxosNode.Round(), placeholder, export)
+	*export = p.paxosNode.Tracker.Export()
+	return nil
+}
+
+// RPC from another PN's debugger to arm a paxostracker breakpoint on this PN
+func (p *PaxosNodeRPCWrapper) SetBreakpoint(bp paxostracker.Breakpoint, r *bool) (err error) {
+	defer p.paxosNode.Trace.RecordRPC("SetBreakpoint", p.paxosNode.Round(), bp, r)
+	wrapperLog.Debugf("remote breakpoint before %v", bp)
+	err = p.paxosNode.Tracker.AddBreakpoint(bp)
+	*r = err == nil
+	return err
+}
+
+// RPC from another PN's debugger to continue the round this PN is paused in
+func (p *PaxosNodeRPCWrapper) ContinueRound(placeholder string, r *bool) (err error) {
+	defer p.paxosNode.Trace.RecordRPC("ContinueRound", p.paxosNode.Round(), placeholder, r)
+	wrapperLog.Debug("remote continue")
+	err = p.paxosNode.Tracker.Continue()
+	*r = err == nil
+	return err
+}
+
+// RPC from another PN's debugger to step the round this PN is paused in
+func (p *PaxosNodeRPCWrapper) StepRound(placeholder string, r *bool) (err error) {
+	defer p.paxosNode.Trace.RecordRPC("StepRound", p.paxosNode.Round(), placeholder, r)
+	wrapperLog.Debug("remote step")
+	err = p.paxosNode.Tracker.Step()
+	*r = err == nil
+	return err
+}
+
 // RPC to notify a PN that majority failed and needs to be recalibrated
 // makes a call to a node to clean failed neighbours
 func (p *PaxosNodeRPCWrapper) CleanYourNeighbours(neighbour string, b *bool) (err error) {
-	singletonlogger.Debug(fmt.Sprintf("[paxosnodewrapper] cleaning request from %s", neighbour))
+	defer p.paxosNode.Trace.RecordRPC("CleanYourNeighbours", p.paxosNode.Round(), neighbour, b)
+	wrapperLog.Debugf("cleaning request from %s", neighbour)
 	*b = p.paxosNode.CleanNbrsOnRequest(neighbour)
 	return nil
 }
 
+// RPC from a neighbour that is leaving the network
+func (p *PaxosNodeRPCWrapper) NeighbourLeaving(addr string, r *bool) (err error) {
+	defer p.paxosNode.Trace.RecordRPC("NeighbourLeaving", p.paxosNode.Round(), addr, r)
+	wrapperLog.Debugf("neighbour %s is leaving", addr)
+	*r = p.paxosNode.RemoveNeighbour(addr) == nil
+	return nil
+}
+
 // RPC that asks a PN whether it still alive
 func (p *PaxosNodeRPCWrapper) RUAlive(placeholder string, b *bool) (err error) {
+	defer p.paxosNode.Trace.RecordRPC("RUAlive", p.paxosNode.Round(), placeholder, b)
 	*b = true
 	return nil
 }
@@ -0,0 +1,104 @@
+package proposer
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// Proposal of a value in an accept request
+type Proposal struct {
+	MsgHash string
+	Value   string
+}
+
+// Proposals the proposer made, which are backed up so an audit after a restart still finds
+// the values learned from them were proposed
+type Proposals struct {
+	sync.RWMutex
+	internal  map[string]string // MsgHash to value
+	backupDir string
+	id        string
+}
+
+func NewProposals(id string) *Proposals {
+	return &Proposals{
+		internal: make(map[string]string),
+		id:       id,
+	}
+}
+
+// Add the proposal of value under msgHash, backing it up unless it was already proposed
+func (p *Proposals) Add(msgHash, value string) (err error) {
+	p.Lock()
+	defer p.Unlock()
+	if _, ok := p.internal[msgHash]; ok {
+		return nil
+	}
+	p.internal[msgHash] = value
+	return p.saveIntoFile(Proposal{msgHash, value})
+}
+
+// Copy of every proposal, keyed by message hash
+func (p *Proposals) Copy() map[string]string {
+	p.RLock()
+	defer p.RUnlock()
+	proposals := make(map[string]string, len(p.internal))
+	for hash, value := range p.internal {
+		proposals[hash] = value
+	}
+	return proposals
+}
+
+func (p *Proposals) SetBackupDir(dir string) {
+	p.Lock()
+	defer p.Unlock()
+	p.backupDir = dir
+}
+
+// Reads the proposals backed up before the proposer was restarted
+func (p *Proposals) RestoreFromBackup() (err error) {
+	p.Lock()
+	defer p.Unlock()
+	if p.backupDir == "" {
+		return nil
+	}
+	f, err := os.Open(p.backupDir + p.id + "proposals.json")
+	if os.IsNotExist(err) {
+		proposerLog.Debugf("no such file exist, no values were proposed %v", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var proposal Proposal
+		if err = json.Unmarshal(scanner.Bytes(), &proposal); err != nil {
+			// the last line is torn when the node died while writing it
+			proposerLog.Debugf("error on unmarshalling proposal %v", err)
+			break
+		}
+		p.internal[proposal.MsgHash] = proposal.Value
+	}
+	proposerLog.Debugf("restored %v proposals", len(p.internal))
+	return nil
+}
+
+// appends a proposal to the backup, holding the lock
+func (p *Proposals) saveIntoFile(proposal Proposal) (err error) {
+	if p.backupDir == "" {
+		return nil
+	}
+	os.MkdirAll(p.backupDir, os.ModePerm)
+	f, err := os.OpenFile(p.backupDir+p.id+"proposals.json", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		proposerLog.Debugf("errored on opening file %v", err)
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(proposal)
+}
@@ -3,9 +3,11 @@ package proposer
 import (
 	"consensuslib/message"
 	"filelogger/singletonlogger"
-	"fmt"
+	"paxostracker"
 )
 
+var proposerLog = singletonlogger.Component("proposer")
+
 type Message = message.Message
 
 type ProposerRole struct {
@@ -13,6 +15,8 @@ type ProposerRole struct {
 	messageID             uint64
 	CurrentPrepareRequest Message
 	CurrentAcceptRequest  Message
+	Proposed              *Proposals // every value this proposer put in an accept request
+	tracker               *paxostracker.PaxosTracker
 }
 
 type ProposerInterface interface {
@@ -38,12 +42,25 @@ type ProposerInterface interface {
 
 	// This method increments current Message ID by 1 to ensure all proposers in the NW has same PSN
 	IncrementMessageID()
+
+	// Returns the highest message ID this proposer has used or seen
+	GetMessageID() uint64
+
+	// Returns every value this proposer has put in an accept request, keyed by message hash.
+	// Used to audit that the network only ever learns proposed values.
+	GetProposals() map[string]string
+
+	// Reads the proposals saved in the backup directory before the proposer was restarted
+	RestoreFromBackup() (err error)
+
+	// Sets the directory backup files are saved in and restored from
+	SetBackupDir(dir string)
 }
 
 func (proposer *ProposerRole) CreatePrepareRequest(roundNum int, msgHash string, ttl int) Message {
 	// Increment the messageID (n value) every time a new prepare request is made
 	proposer.messageID++
-	singletonlogger.Debug(fmt.Sprintf("[Proposer] message ID at proposer %v", proposer.messageID))
+	proposerLog.Debugf("message ID at proposer %v", proposer.messageID)
 	/*prepareRequest := Message{
 		ID:             proposer.messageID,
 		Type:           message.PREPARE,
@@ -52,6 +69,7 @@ func (proposer *ProposerRole) CreatePrepareRequest(roundNum int, msgHash string,
 		RoundNum:		roundNum,
 	}*/
 	prepareRequest := message.NewMessage(proposer.messageID, msgHash, message.PREPARE, "", proposer.proposerID, roundNum, ttl)
+	proposer.CurrentPrepareRequest = prepareRequest
 	return prepareRequest
 }
 
@@ -63,28 +81,55 @@ func (proposer *ProposerRole) CreateAcceptRequest(value, msgHash string, roundNu
 		FromProposerID: proposer.proposerID,
 		RoundNum:		roundNum,
 	}*/
-	acceptRequest := message.NewMessage(proposer.messageID, msgHash, message.ACCEPT, value, proposer.proposerID, roundNum, ttl)
+	// the accept request is for the proposal number the acceptors promised, even if the proposer has seen
+	// higher ones since
+	acceptRequest := message.NewMessage(proposer.CurrentPrepareRequest.ID, msgHash, message.ACCEPT, value, proposer.proposerID, roundNum, ttl)
+	proposer.CurrentAcceptRequest = acceptRequest
+	if err := proposer.Proposed.Add(msgHash, value); err != nil {
+		// the value is still proposed, but an audit after a restart may report it as never proposed
+		proposerLog.Errorf("unable to back up proposal of %v: %s", value, err)
+	}
+	proposer.tracker.Propose(acceptRequest.ID, value, roundNum)
 	return acceptRequest
 }
 
+func (proposer *ProposerRole) GetProposals() map[string]string {
+	return proposer.Proposed.Copy()
+}
+
+func (proposer *ProposerRole) SetBackupDir(dir string) {
+	proposer.Proposed.SetBackupDir(dir)
+}
+
+// Reads the proposals this proposer backed up before it was restarted
+func (proposer *ProposerRole) RestoreFromBackup() (err error) {
+	return proposer.Proposed.RestoreFromBackup()
+}
+
 func (proposer *ProposerRole) UpdateMessageID(messageID uint64) {
 	proposer.messageID = messageID
 }
 
+func (proposer *ProposerRole) GetMessageID() uint64 {
+	return proposer.messageID
+}
+
 func (proposer *ProposerRole) IncrementMessageID() {
-	singletonlogger.Debug(fmt.Sprintf("[Proposer] increasing message ID before %v", proposer.messageID))
+	proposerLog.Debugf("increasing message ID before %v", proposer.messageID)
 	proposer.messageID++
-	singletonlogger.Debug(fmt.Sprintf("[Proposer] increasing message ID after %v", proposer.messageID))
+	proposerLog.Debugf("increasing message ID after %v", proposer.messageID)
 }
 
 // The constructor for a new ProposerRole object instance. A PN should only interact with just one
-// ProposerRole instance at a time
-func NewProposer(proposerID string) ProposerRole {
+// ProposerRole instance at a time. The tracker is told of every accept request the proposer creates.
+func NewProposer(proposerID string, tracker *paxostracker.PaxosTracker) ProposerRole {
 	proposer := ProposerRole{
 		proposerID:            proposerID,
 		messageID:             0,
 		CurrentPrepareRequest: Message{},
 		CurrentAcceptRequest:  Message{},
+		Proposed:              NewProposals(proposerID),
+		tracker:               tracker,
 	}
 	return proposer
 }
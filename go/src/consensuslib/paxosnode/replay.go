@@ -0,0 +1,395 @@
+package paxosnode
+
+import (
+	"bytes"
+	"consensuslib/errors"
+	"consensuslib/paxosnode/trace"
+	"encoding/json"
+	"fmt"
+	"net/rpc"
+	"sync"
+	"time"
+)
+
+/**
+ * Replay re-drives a PaxosNode from a recorded trace. The PN is reset to every recorded INIT state,
+ * and is sent each recorded inbound RPC in the order they were handled, with the round number it had
+ * at the time. Recorded writes are made again, and the requests they make of the PN's own acceptor and
+ * of its neighbours wait until the replay reaches the recorded request. Neighbours are answered with
+ * their recorded reply, error or timeout, and random choices with the recorded choice.
+ *
+ * RPCs that depend on a debugger (connecting and cleaning neighbours, breakpoints) are not re-driven.
+ * Every reply the replayed PN gives is compared with the recorded reply, and a request the replayed PN
+ * never makes, such as a call to a neighbour with a different argument, counts as a divergence.
+ * A replayed PN stays off the network after the replay, since its neighbours are only the recorded ones.
+ */
+
+// replayWait is how long the replay waits on the PN to make a recorded request before it diverges
+const replayWait = 2 * time.Second
+
+// ReplayState is the state of a PN recorded in a trace INIT entry
+type ReplayState struct {
+	ID           string
+	Addr         string
+	RoundNum     int
+	MessageID    uint64
+	LastPromised Message
+	LastAccepted Message
+	Log          []Message
+	Neighbours   []string
+}
+
+// StartTrace records the PN's inputs to t from now on, starting from its current state
+func (pn *PaxosNode) StartTrace(t *trace.Trace) {
+	pn.Trace = t
+	pn.RecordState()
+}
+
+// RecordState records the PN's current state in its trace, for state that was learned from outside
+// the recorded inputs, such as a log caught up from neighbours
+func (pn *PaxosNode) RecordState() {
+	if pn.Trace == nil {
+		return
+	}
+	log, _ := pn.GetLog()
+	pn.lock.RLock()
+	neighbours := pn.neighbourAddrs()
+	pn.lock.RUnlock()
+	lastPromised, lastAccepted := pn.Acceptor.State()
+	pn.Trace.RecordInit(ReplayState{
+		ID:           pn.ID,
+		Addr:         pn.Addr,
+		RoundNum:     pn.Round(),
+		MessageID:    pn.Proposer.GetMessageID(),
+		LastPromised: lastPromised,
+		LastAccepted: lastAccepted,
+		Log:          log,
+		Neighbours:   neighbours,
+	})
+}
+
+// Replay the trace entries on the PN. The PN should have no neighbours.
+func (pn *PaxosNode) Replay(entries []trace.Entry) (divergences []string, err error) {
+	wrapper, err := NewPaxosNodeRPCWrapper(pn)
+	if err != nil {
+		return nil, err
+	}
+	r := newReplayer()
+	pn.replay = r
+	defer r.finish()
+
+	for _, e := range entries {
+		var reply interface{}
+		replayed := true
+		switch e.Kind {
+		case trace.INIT:
+			var state ReplayState
+			if err = json.Unmarshal(e.Arg, &state); err != nil {
+				return divergences, fmt.Errorf("unable to read state of entry %d: %s", e.Seq, err)
+			}
+			pn.SetRoundNum(state.RoundNum)
+			pn.Proposer.UpdateMessageID(state.MessageID)
+			pn.Acceptor.SetState(state.LastPromised, state.LastAccepted)
+			pn.Learner.InitializeLog(state.Log)
+			pn.setReplayNeighbours(state.Neighbours)
+			continue
+		case trace.NEIGHBOURS:
+			var neighbours []string
+			if err = json.Unmarshal(e.Arg, &neighbours); err != nil {
+				return divergences, fmt.Errorf("unable to read neighbours of entry %d: %s", e.Seq, err)
+			}
+			pn.setReplayNeighbours(neighbours)
+			continue
+		case trace.WRITE:
+			var w trace.Write
+			if err = json.Unmarshal(e.Arg, &w); err != nil {
+				return divergences, fmt.Errorf("unable to read write of entry %d: %s", e.Seq, err)
+			}
+			if !r.write(pn, w) {
+				divergences = append(divergences, fmt.Sprintf("#%d %s %q: never reached a request", e.Seq, e.Kind, w.Value))
+			}
+			continue
+		case trace.CALL, trace.TIMER, trace.RANDOM:
+			if _, ok := r.hand(e); !ok {
+				divergences = append(divergences, fmt.Sprintf("#%d %s %s %s: not made by the replayed PN", e.Seq, e.Kind, e.Method, e.Peer))
+			}
+			continue
+		case trace.RPC:
+			pn.SetRoundNum(e.Round)
+			reply, replayed, err = pn.replayRPC(wrapper, e)
+		case trace.LOCAL:
+			pn.SetRoundNum(e.Round)
+			if r.writing() {
+				var ok bool
+				if reply, ok = r.hand(e); !ok {
+					divergences = append(divergences, fmt.Sprintf("#%d %s %s: not made by the replayed PN", e.Seq, e.Kind, e.Method))
+					continue
+				}
+			} else {
+				// made by a write that was running before the trace started
+				reply, err = pn.replayLocal(e)
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return divergences, fmt.Errorf("unable to replay entry %d: %s", e.Seq, err)
+		}
+		if !replayed {
+			nodeLog.Debugf("not replaying %s #%d", e.Method, e.Seq)
+			continue
+		}
+		replied, err := json.Marshal(reply)
+		if err != nil {
+			return divergences, err
+		}
+		got, want := withoutPiggybacked(replied), withoutPiggybacked(e.Reply)
+		if !bytes.Equal(got, want) {
+			divergences = append(divergences, fmt.Sprintf("#%d %s %s: replayed %s, recorded %s", e.Seq, e.Kind, e.Method, got, want))
+		}
+	}
+	return divergences, nil
+}
+
+// setReplayNeighbours to the recorded neighbours. They have no connection, as the replay answers every call to them.
+func (pn *PaxosNode) setReplayNeighbours(addrs []string) {
+	pn.lock.Lock()
+	defer pn.lock.Unlock()
+	pn.NbrAddrs = append([]string(nil), addrs...)
+	pn.Neighbours = make(map[string]*rpc.Client, len(addrs))
+	for _, ip := range addrs {
+		pn.Neighbours[ip] = nil
+	}
+}
+
+// replayer hands recorded entries to the requests of the PN being replayed, as the replay reaches them
+type replayer struct {
+	sync.Mutex
+	gates   map[string]chan *handoff // keyed by the request waiting on them, see replayKey
+	done    chan struct{}            // closed once the replay finished
+	arrived chan struct{}            // signalled when a request starts waiting
+	writes  sync.WaitGroup
+	running int // replayed writes still running
+}
+
+// handoff of a recorded entry to the request waiting on it, which answers with its reply once handled
+type handoff struct {
+	entry  trace.Entry
+	answer chan interface{}
+}
+
+var errReplayDone = fmt.Errorf("the replay finished")
+
+func newReplayer() *replayer {
+	return &replayer{
+		gates:   make(map[string]chan *handoff),
+		done:    make(chan struct{}),
+		arrived: make(chan struct{}, 1),
+	}
+}
+
+// replayKey identifies a request by its kind, method, peer and argument. Calls and their timeouts share a key.
+func replayKey(kind trace.Kind, method, peer string, arg json.RawMessage) string {
+	if kind == trace.TIMER {
+		kind = trace.CALL
+	}
+	return fmt.Sprintf("%s %s %s %s", kind, method, peer, withoutPiggybacked(arg))
+}
+
+func (r *replayer) gate(key string) chan *handoff {
+	r.Lock()
+	defer r.Unlock()
+	gate, ok := r.gates[key]
+	if !ok {
+		gate = make(chan *handoff)
+		r.gates[key] = gate
+	}
+	return gate
+}
+
+// await the recorded entry for a request. The request must answer the handoff once it is handled.
+func (r *replayer) await(kind trace.Kind, method, peer string, arg interface{}) (*handoff, error) {
+	var raw json.RawMessage
+	if arg != nil {
+		raw, _ = json.Marshal(arg)
+	}
+	gate := r.gate(replayKey(kind, method, peer, raw))
+	select {
+	case r.arrived <- struct{}{}:
+	default:
+	}
+	select {
+	case h := <-gate:
+		return h, nil
+	case <-r.done:
+		return nil, errReplayDone
+	}
+}
+
+// hand e to the request waiting on it, and return the request's answer. Returns false when the PN
+// did not make the request in time.
+func (r *replayer) hand(e trace.Entry) (answer interface{}, ok bool) {
+	h := &handoff{entry: e, answer: make(chan interface{}, 1)}
+	select {
+	case r.gate(replayKey(e.Kind, e.Method, e.Peer, e.Arg)) <- h:
+	case <-time.After(replayWait):
+		return nil, false
+	}
+	select {
+	case answer = <-h.answer:
+		return answer, true
+	case <-time.After(replayWait):
+		return nil, false
+	}
+}
+
+// write w on pn again, returning once it made its first request, or false when it did not in time
+func (r *replayer) write(pn *PaxosNode, w trace.Write) bool {
+	select {
+	case <-r.arrived:
+	default:
+	}
+	r.Lock()
+	r.running++
+	r.Unlock()
+	r.writes.Add(1)
+	go func() {
+		defer r.writes.Done()
+		if _, err := pn.WriteToPaxosNode(w.Value, w.MsgHash, w.TTL); err != nil {
+			nodeLog.Debugf("replayed write of %v ended: %s", w.Value, err)
+		}
+		r.Lock()
+		r.running--
+		r.Unlock()
+	}()
+	select {
+	case <-r.arrived:
+		return true
+	case <-time.After(replayWait):
+		return false
+	}
+}
+
+// writing tells whether a replayed write is still running
+func (r *replayer) writing() bool {
+	r.Lock()
+	defer r.Unlock()
+	return r.running > 0
+}
+
+// call to the neighbour at k, answered from the recorded reply, error or timeout
+func (r *replayer) call(k, method string, arg interface{}, reply interface{}) error {
+	h, err := r.await(trace.CALL, method, k, arg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		h.answer <- nil
+	}()
+	switch {
+	case h.entry.Kind == trace.TIMER:
+		return errors.TimeoutError(k)
+	case h.entry.Error != "":
+		return rpc.ServerError(h.entry.Error)
+	default:
+		return json.Unmarshal(h.entry.Reply, reply)
+	}
+}
+
+// random choice made in method, answered from the recorded choice
+func (r *replayer) random(method string) int {
+	h, err := r.await(trace.RANDOM, method, "", nil)
+	if err != nil {
+		return 0
+	}
+	h.answer <- nil
+	return h.entry.Value
+}
+
+// finish the replay, failing every request still waiting, and wait for the replayed writes to end
+func (r *replayer) finish() {
+	close(r.done)
+	r.writes.Wait()
+}
+
+// replayRPC sends a recorded RPC through the wrapper, as the RPC server would have
+func (pn *PaxosNode) replayRPC(wrapper *PaxosNodeRPCWrapper, e trace.Entry) (reply interface{}, replayed bool, err error) {
+	switch e.Method {
+	case "ProcessPrepareRequest":
+		var m, r Message
+		if err = json.Unmarshal(e.Arg, &m); err == nil {
+			err = wrapper.ProcessPrepareRequest(m, &r)
+		}
+		return r, true, err
+	case "ProcessAcceptRequest":
+		var m, r Message
+		if err = json.Unmarshal(e.Arg, &m); err == nil {
+			err = wrapper.ProcessAcceptRequest(m, &r)
+		}
+		return r, true, err
+	case "NotifyAboutAccepted":
+		var m Message
+		var r bool
+		if err = json.Unmarshal(e.Arg, &m); err == nil {
+			err = wrapper.NotifyAboutAccepted(&m, &r)
+		}
+		return r, true, err
+	case "ReadFromLearner":
+		log := make([]Message, 0)
+		err = wrapper.ReadFromLearner("placeholder", &log)
+		return log, true, err
+	case "RUAlive":
+		var r bool
+		err = wrapper.RUAlive("placeholder", &r)
+		return r, true, err
+	default:
+		return nil, false, nil
+	}
+}
+
+// replayLocal makes a recorded request from the PN's proposer to its own acceptor, as DisseminateRequest does
+func (pn *PaxosNode) replayLocal(e trace.Entry) (reply interface{}, err error) {
+	var m Message
+	if err = json.Unmarshal(e.Arg, &m); err != nil {
+		return nil, err
+	}
+	switch e.Method {
+	case "ProcessPrepare":
+		return pn.Acceptor.ProcessPrepare(m, pn.Round()), nil
+	case "ProcessAccept":
+		resp := pn.Acceptor.ProcessAccept(m, pn.Round())
+		if resp.Equals(&m) {
+			pn.SayAccepted(&m)
+		}
+		return resp, nil
+	default:
+		return nil, fmt.Errorf("unknown local request %s", e.Method)
+	}
+}
+
+// withoutPiggybacked drops the vector clocks and span contexts piggybacked on messages in a reply,
+// which depend on whether vector clock logging and tracing were on rather than on the PN's state
+func withoutPiggybacked(reply json.RawMessage) []byte {
+	var decoded interface{}
+	if err := json.Unmarshal(reply, &decoded); err != nil {
+		return reply
+	}
+	var strip func(v interface{})
+	strip = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			delete(v, "Clock")
+			delete(v, "Span")
+			for _, field := range v {
+				strip(field)
+			}
+		case []interface{}:
+			for _, elem := range v {
+				strip(elem)
+			}
+		}
+	}
+	strip(decoded)
+	stripped, _ := json.Marshal(decoded)
+	return stripped
+}
@@ -0,0 +1,58 @@
+package paxosnode
+
+import (
+	"consensuslib/membership"
+	"paxostracker"
+	"time"
+)
+
+// Status of a PN, for dashboards and health checks
+type Status struct {
+	ID               string
+	Addr             string
+	RoundNum         int
+	MessageID        uint64 // the proposer's next message ID
+	LastPromised     Message
+	LastAccepted     Message
+	LogLength        int
+	Neighbours       []string
+	FailedNeighbours []string
+	// Leader is the distinguished proposer this PN follows. Every PN proposes in this implementation,
+	// so it is always empty until a leader is elected.
+	Leader string `json:",omitempty"`
+	// Members is the view of the membership, when the PN has one
+	Members []membership.Member `json:",omitempty"`
+	// Paused is the stage the PN is held at by a breakpoint, if any
+	Paused paxostracker.Stage `json:",omitempty"`
+	Time   time.Time
+}
+
+// Status of this PN now. The round and log, and the neighbours, are each read under the lock guarding them.
+func (pn *PaxosNode) Status() Status {
+	pn.lock.RLock()
+	neighbours := pn.neighbourAddrs()
+	failed := append([]string{}, pn.FailedNeighbours...)
+	pn.lock.RUnlock()
+	pn.state.Lock()
+	round, logLength := pn.RoundNum, len(pn.Learner.Log)
+	pn.state.Unlock()
+	lastPromised, lastAccepted := pn.Acceptor.State()
+	var members []membership.Member
+	if pn.Membership != nil {
+		members = pn.Membership.Members()
+	}
+	return Status{
+		ID:               pn.ID,
+		Addr:             pn.Addr,
+		RoundNum:         round,
+		MessageID:        pn.Proposer.GetMessageID(),
+		LastPromised:     lastPromised,
+		LastAccepted:     lastAccepted,
+		LogLength:        logLength,
+		Neighbours:       neighbours,
+		FailedNeighbours: failed,
+		Members:          members,
+		Paused:           pn.Tracker.Paused(),
+		Time:             time.Now(),
+	}
+}
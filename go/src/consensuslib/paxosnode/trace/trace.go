@@ -0,0 +1,177 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"filelogger/singletonlogger"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var traceLog = singletonlogger.Component("trace")
+
+/**
+ * Trace records everything from outside a PaxosNode that decides what it does: the RPCs it receives,
+ * the writes it is asked to make, the replies to the requests it makes of its neighbours and of its own
+ * acceptor, its neighbours, the timers that fire while it waits on neighbours, and the random choices it makes.
+ * Entries are written as JSON lines, so a trace can be read while it is still being recorded.
+ *
+ * A nil *Trace records nothing, so the PaxosNode can call it unconditionally.
+ */
+
+// Kind of a trace entry
+type Kind string
+
+const (
+	// INIT is the state the node started recording from, or caught up to from its neighbours
+	INIT Kind = "init"
+	// RPC is an inbound RPC, with its argument and reply
+	RPC Kind = "rpc"
+	// LOCAL is a request from the node's proposer to its own acceptor, with its argument and reply
+	LOCAL Kind = "local"
+	// TIMER is a timeout firing while waiting on a neighbour
+	TIMER Kind = "timer"
+	// RANDOM is a random choice, and the value chosen
+	RANDOM Kind = "random"
+	// WRITE is a value the application asked the node to write
+	WRITE Kind = "write"
+	// CALL is a request the node made to a neighbour, with its argument and the neighbour's reply or error
+	CALL Kind = "call"
+	// NEIGHBOURS is the set of neighbours the node has, recorded whenever it changes
+	NEIGHBOURS Kind = "neighbours"
+)
+
+// Entry in a trace
+type Entry struct {
+	Seq    uint64
+	Time   time.Time
+	Kind   Kind
+	Method string          // RPC method, the method a call or timer was for, or the function the choice was made in
+	Peer   string          // neighbour the call was made to or the timer fired for
+	Round  int             // round number of the node when an RPC or local request was handled
+	Arg    json.RawMessage // RPC or call argument, write, neighbours or initial state
+	Reply  json.RawMessage // RPC or call reply
+	Error  string          // error a call to a neighbour returned
+	Value  int             // value of a random choice
+}
+
+// Write the application asked the node to make
+type Write struct {
+	Value   string
+	MsgHash string
+	TTL     int
+}
+
+// Trace file being recorded
+type Trace struct {
+	sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+	seq  uint64
+}
+
+// Create a trace file at path
+func Create(path string) (t *Trace, err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create trace file: %s", err)
+	}
+	return &Trace{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Close the trace file
+func (t *Trace) Close() error {
+	if t == nil {
+		return nil
+	}
+	t.Lock()
+	defer t.Unlock()
+	return t.file.Close()
+}
+
+// RecordInit records the state the node starts from
+func (t *Trace) RecordInit(state interface{}) {
+	t.record(Entry{Kind: INIT, Arg: marshal(state)})
+}
+
+// RecordRPC records an inbound RPC once it has been handled in round
+func (t *Trace) RecordRPC(method string, round int, arg interface{}, reply interface{}) {
+	t.record(Entry{Kind: RPC, Method: method, Round: round, Arg: marshal(arg), Reply: marshal(reply)})
+}
+
+// RecordLocal records a request the node's proposer made to its own acceptor in round
+func (t *Trace) RecordLocal(method string, round int, arg interface{}, reply interface{}) {
+	t.record(Entry{Kind: LOCAL, Method: method, Round: round, Arg: marshal(arg), Reply: marshal(reply)})
+}
+
+// RecordCall records the reply to, or error from, a request the node made of peer
+func (t *Trace) RecordCall(method string, peer string, arg interface{}, reply interface{}, err error) {
+	e := Entry{Kind: CALL, Method: method, Peer: peer, Arg: marshal(arg), Reply: marshal(reply)}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	t.record(e)
+}
+
+// RecordNeighbours records the addresses of the node's neighbours, after they changed
+func (t *Trace) RecordNeighbours(addrs []string) {
+	t.record(Entry{Kind: NEIGHBOURS, Arg: marshal(addrs)})
+}
+
+// RecordTimer records a timeout firing while waiting on peer to handle method with arg
+func (t *Trace) RecordTimer(method string, peer string, arg interface{}) {
+	t.record(Entry{Kind: TIMER, Method: method, Peer: peer, Arg: marshal(arg)})
+}
+
+// RecordRandom records a random choice made in method
+func (t *Trace) RecordRandom(method string, value int) {
+	t.record(Entry{Kind: RANDOM, Method: method, Value: value})
+}
+
+// RecordWrite records a value the application is writing through this node
+func (t *Trace) RecordWrite(w Write) {
+	t.record(Entry{Kind: WRITE, Arg: marshal(w)})
+}
+
+func (t *Trace) record(e Entry) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+	t.seq++
+	e.Seq = t.seq
+	e.Time = time.Now()
+	if err := t.enc.Encode(e); err != nil {
+		traceLog.Errorf("unable to record entry %d: %s", e.Seq, err)
+	}
+}
+
+// Load every entry of the trace at path
+func Load(path string) (entries []Entry, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open trace file: %s", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err = json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("unable to read trace entry %d: %s", len(entries)+1, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+func marshal(v interface{}) json.RawMessage {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return json.RawMessage(fmt.Sprintf("%q", err.Error()))
+	}
+	return buf
+}
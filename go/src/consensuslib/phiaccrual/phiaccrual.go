@@ -0,0 +1,106 @@
+/*
+Package phiaccrual is an adaptive failure detector (Hayashibara et al., 2004).
+
+Rather than suspecting a node once a fixed timeout passes without a heartbeat, the detector keeps the
+intervals between the node's last heartbeats, and computes phi, how unlikely it is to still be waiting for
+the next one if the intervals are normally distributed:
+
+	phi = -log10(P(the next heartbeat arrives later than now))
+
+A phi of 1 means a one in ten chance of being wrong in suspecting the node, a phi of 2 one in a hundred, and so on.
+As the intervals get longer or more irregular, such as over a slow network, the detector waits longer before
+phi crosses the threshold.
+*/
+package phiaccrual
+
+import (
+	"math"
+	"time"
+)
+
+// Config of a detector
+type Config struct {
+	Threshold       float64       // phi above which the node is suspected
+	WindowSize      int           // number of the last intervals the distribution is estimated from
+	MinStdDev       time.Duration // lower bound of the standard deviation, so very regular heartbeats are not too strict
+	AcceptablePause time.Duration // added to the mean interval, to allow for pauses such as garbage collection
+	FirstInterval   time.Duration // interval assumed before the second heartbeat arrives
+}
+
+// DefaultConfig suspects a node once there is less than a one in 10^8 chance it is still alive
+func DefaultConfig() Config {
+	return Config{
+		Threshold:       8,
+		WindowSize:      1000,
+		MinStdDev:       100 * time.Millisecond,
+		AcceptablePause: 0,
+		FirstInterval:   time.Second,
+	}
+}
+
+// Detector of one node's failure. It is not safe for concurrent use.
+type Detector struct {
+	config     Config
+	intervals  []float64 // in seconds, a ring of the last WindowSize intervals
+	next       int       // index of intervals the next interval replaces, once the window is full
+	sum        float64
+	sumSquares float64
+	last       time.Time
+}
+
+// New detector for a node first heard from at now
+func New(config Config, now time.Time) *Detector {
+	if config.WindowSize < 1 {
+		config.WindowSize = 1
+	}
+	d := &Detector{
+		config:    config,
+		intervals: make([]float64, 0, config.WindowSize),
+		last:      now,
+	}
+	d.add(config.FirstInterval.Seconds())
+	return d
+}
+
+// Heartbeat from the node at now
+func (d *Detector) Heartbeat(now time.Time) {
+	d.add(now.Sub(d.last).Seconds())
+	d.last = now
+}
+
+// Phi of the node at now. It grows the longer the node goes without a heartbeat.
+func (d *Detector) Phi(now time.Time) float64 {
+	n := float64(len(d.intervals))
+	mean := d.sum / n
+	variance := d.sumSquares/n - mean*mean
+	stdDev := math.Max(math.Sqrt(math.Max(variance, 0)), d.config.MinStdDev.Seconds())
+	mean += d.config.AcceptablePause.Seconds()
+
+	elapsed := now.Sub(d.last).Seconds()
+	later := 0.5 * math.Erfc((elapsed-mean)/(stdDev*math.Sqrt2))
+	if later <= 0 {
+		// beyond what a float64 represents
+		return math.Inf(1)
+	}
+	return -math.Log10(later)
+}
+
+// Available says if the node is not suspected at now
+func (d *Detector) Available(now time.Time) bool {
+	return d.Phi(now) < d.config.Threshold
+}
+
+// add an interval to the window, dropping the oldest one once it is full
+func (d *Detector) add(interval float64) {
+	if len(d.intervals) < d.config.WindowSize {
+		d.intervals = append(d.intervals, interval)
+	} else {
+		old := d.intervals[d.next]
+		d.sum -= old
+		d.sumSquares -= old * old
+		d.intervals[d.next] = interval
+		d.next = (d.next + 1) % d.config.WindowSize
+	}
+	d.sum += interval
+	d.sumSquares += interval * interval
+}
@@ -0,0 +1,171 @@
+package consensuslib
+
+import (
+	"sort"
+	"time"
+)
+
+/**
+ * Replication keeps a group of servers with the same users, so nodes can still join and heartbeat
+ * when a server dies. It is primary/backup:
+ *   - the servers are ordered by address, and the primary is the first one that is alive
+ *   - only the primary registers, removes and monitors users, and backups reject them with a NotPrimaryError
+ *   - the primary pushes its users to every backup each time they change
+ *   - each backup probes the servers before it, and takes over when none of them answer,
+ *     giving every user a fresh heartbeat so they have time to fail over to it
+ *
+ * A partition can leave two primaries until it heals, so the group assumes servers crash rather than
+ * get partitioned.
+ */
+
+// joinGroup of the servers at peers, learning the users of whoever is already running,
+// then taking the primary or backup role
+func (s *Server) joinGroup(peers []string) {
+	s.group = append([]string{s.addr}, peers...)
+	sort.Strings(s.group)
+	if len(peers) == 0 {
+		s.primary = true
+		s.primaryAddr = s.addr
+		return
+	}
+	for _, peer := range peers {
+		var members []Member
+		if err := s.transport.Call(peer, s.probeInterval(), "Server.Members", "placeholder", &members); err != nil {
+			continue
+		}
+		var ignored bool
+		s.Replicate(members, &ignored)
+		serverLog.Infof("Learned %d users from %s", len(members), peer)
+		break
+	}
+	s.elect()
+	go s.watch()
+}
+
+// IsPrimary says if this server is the primary of its group
+func (s *Server) IsPrimary() bool {
+	s.allUsers.RLock()
+	defer s.allUsers.RUnlock()
+	return s.primary
+}
+
+// watch the servers before this one in the group, taking over as primary when they all die
+func (s *Server) watch() {
+	for {
+		select {
+		case <-s.closed:
+			return
+		case <-time.After(s.probeInterval()):
+			s.elect()
+		}
+	}
+}
+
+// elect the first alive server in the group as the primary
+func (s *Server) elect() {
+	primaryAddr := s.addr
+	for _, addr := range s.group {
+		if addr == s.addr {
+			break
+		}
+		if s.probe(addr) {
+			primaryAddr = addr
+			break
+		}
+	}
+
+	s.allUsers.Lock()
+	defer s.allUsers.Unlock()
+	s.primaryAddr = primaryAddr
+	if primaryAddr == s.addr && !s.primary {
+		serverLog.Infof("Taking over as primary with %d users", len(s.allUsers.all))
+		s.primary = true
+		for addr, user := range s.allUsers.all {
+			s.allUsers.all[addr] = s.newUser(addr, user.ID)
+			s.startMonitor(addr)
+		}
+	} else if primaryAddr != s.addr && s.primary {
+		serverLog.Infof("Stepping down for primary %s", primaryAddr)
+		s.primary = false
+	}
+}
+
+// Replicate the users of the primary onto this server. Users it did not know get a fresh heartbeat.
+// Only the other servers of the group can replicate, see authorize.
+func (s *Server) Replicate(members []Member, ok *bool) error {
+	s.allUsers.Lock()
+	defer s.allUsers.Unlock()
+
+	known := make(map[string]bool, len(members))
+	for _, m := range members {
+		addr := m.Address
+		known[addr] = true
+		if user, exists := s.allUsers.all[addr]; exists {
+			user.ID = m.ID
+			continue
+		}
+		s.allUsers.all[addr] = s.newUser(addr, m.ID)
+		if s.primary {
+			s.startMonitor(addr)
+		}
+	}
+	for addr := range s.allUsers.all {
+		if !known[addr] {
+			delete(s.allUsers.all, addr)
+		}
+	}
+	*ok = true
+	return nil
+}
+
+// replicate the users to every backup
+func (s *Server) replicate() {
+	s.replicateLock.Lock()
+	defer s.replicateLock.Unlock()
+
+	s.allUsers.RLock()
+	members := make([]Member, 0, len(s.allUsers.all))
+	for addr, user := range s.allUsers.all {
+		members = append(members, Member{Address: addr, ID: user.ID})
+	}
+	s.allUsers.RUnlock()
+	for _, peer := range s.group {
+		if peer == s.addr {
+			continue
+		}
+		var ok bool
+		if err := s.transport.Call(peer, s.probeInterval(), "Server.Replicate", members, &ok); err != nil {
+			serverLog.Debugf("Unable to replicate to %s: %s", peer, err)
+		}
+	}
+}
+
+// isReplica says if addr is another server of the group
+func (s *Server) isReplica(addr string) bool {
+	for _, replica := range s.group {
+		if replica == addr && addr != s.addr {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) isClosed() bool {
+	select {
+	case <-s.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// probeInterval is how often a backup probes the servers before it
+func (s *Server) probeInterval() time.Duration {
+	return s.config.HeartbeatTimeout / 4
+}
+
+// probe if the server at addr is alive, giving up on it if it does not answer in a probeInterval
+func (s *Server) probe(addr string) bool {
+	var alive bool
+	return s.transport.Call(addr, s.probeInterval(), "Server.CheckAlive", "placeholder", &alive) == nil && alive
+}
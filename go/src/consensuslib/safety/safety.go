@@ -0,0 +1,216 @@
+package safety
+
+import (
+	"consensuslib/message"
+	"consensuslib/transport"
+	"fmt"
+	"net/rpc"
+	"sort"
+	"strings"
+)
+
+/**
+ * Safety audits the learner logs of every node in a Paxos network against the safety properties of consensus:
+ *   - Agreement: no two nodes learned different values at the same index
+ *   - Validity:  every learned value was proposed by some node
+ *
+ * Agreement at every index also means every node's log is a prefix of the longest log.
+ * The logs are gathered over the same ReadFromLearner RPC a new node uses to catch up.
+ */
+
+type Message = message.Message
+
+// Property is a safety property of Paxos
+type Property string
+
+const (
+	// AGREEMENT is violated when two nodes learned different values at the same index
+	AGREEMENT Property = "Agreement"
+	// VALIDITY is violated when a learned value was never proposed
+	VALIDITY Property = "Validity"
+)
+
+// Violation of a safety property at a slot of the log
+type Violation struct {
+	Property Property
+	Index    int
+	Slots    map[string]*Message // node address to what it holds at Index, nil if the node has no entry there
+}
+
+// Snapshot of the network: the log and proposals of every node, keyed by node address
+type Snapshot struct {
+	Logs      map[string][]Message
+	Proposals map[string]map[string]string // node address to the MsgHash -> value of everything it proposed
+}
+
+// NewSnapshot creates an empty snapshot
+func NewSnapshot() *Snapshot {
+	return &Snapshot{
+		Logs:      make(map[string][]Message),
+		Proposals: make(map[string]map[string]string),
+	}
+}
+
+// Gather the learner log and proposals of every node over open RPC connections.
+// Nodes which fail to answer are left out of the snapshot and returned as unreachable.
+func Gather(snapshot *Snapshot, nodes map[string]*rpc.Client) (unreachable []string) {
+	for addr, conn := range nodes {
+		log := make([]Message, 0)
+		proposals := make(map[string]string)
+		if err := conn.Call("PaxosNodeRPCWrapper.ReadFromLearner", "placeholder", &log); err != nil {
+			unreachable = append(unreachable, addr)
+			continue
+		}
+		if err := conn.Call("PaxosNodeRPCWrapper.ReadProposals", "placeholder", &proposals); err != nil {
+			unreachable = append(unreachable, addr)
+			continue
+		}
+		snapshot.Logs[addr] = log
+		snapshot.Proposals[addr] = proposals
+	}
+	return unreachable
+}
+
+// GatherFrom dials every address with t, and gathers a snapshot from them
+func GatherFrom(t *transport.Transport, addrs []string) (snapshot *Snapshot, err error) {
+	nodes := make(map[string]*rpc.Client, len(addrs))
+	for _, addr := range addrs {
+		conn, err := t.DialRPC(addr)
+		if err != nil {
+			return nil, fmt.Errorf("[LIB/SAFETY]#GatherFrom: unable to dial node %s: %s", addr, err)
+		}
+		defer conn.Close()
+		nodes[addr] = conn
+	}
+	snapshot = NewSnapshot()
+	if unreachable := Gather(snapshot, nodes); len(unreachable) != 0 {
+		return nil, fmt.Errorf("[LIB/SAFETY]#GatherFrom: unable to read from nodes %v", unreachable)
+	}
+	return snapshot, nil
+}
+
+// Check the snapshot for safety violations. Proposals from every node count towards validity,
+// so checking a partial snapshot may report values proposed by a missing node as invalid.
+func Check(snapshot *Snapshot) (violations []Violation) {
+	nodes := make([]string, 0, len(snapshot.Logs))
+	longest := 0
+	for addr, log := range snapshot.Logs {
+		nodes = append(nodes, addr)
+		if len(log) > longest {
+			longest = len(log)
+		}
+	}
+	sort.Strings(nodes)
+
+	proposed := make(map[string]string)
+	for _, proposals := range snapshot.Proposals {
+		for hash, value := range proposals {
+			proposed[hash] = value
+		}
+	}
+
+	for i := 0; i < longest; i++ {
+		slots := make(map[string]*Message, len(nodes))
+		distinct := make(map[string]bool)
+		for _, addr := range nodes {
+			log := snapshot.Logs[addr]
+			if i >= len(log) {
+				slots[addr] = nil
+				continue
+			}
+			slots[addr] = &log[i]
+			distinct[key(&log[i])] = true
+		}
+		if len(distinct) > 1 {
+			violations = append(violations, Violation{AGREEMENT, i, slots})
+		}
+		for _, addr := range nodes {
+			m := slots[addr]
+			if m == nil {
+				continue
+			}
+			if value, ok := proposed[m.MsgHash]; !ok || value != m.Value {
+				violations = append(violations, Violation{VALIDITY, i, map[string]*Message{addr: m}})
+			}
+		}
+	}
+	return violations
+}
+
+// Difference between the logs of the nodes at a slot, where they hold different values or some
+// have yet to learn one. Unlike a Violation, a node lagging behind is a difference but is safe.
+type Difference struct {
+	Index int
+	Slots map[string]*Message // node address to what it holds at Index, nil if the node has no entry there
+}
+
+// Diff the logs of the snapshot, returning every slot the nodes do not all hold the same value at
+func Diff(snapshot *Snapshot) (differences []Difference) {
+	longest := 0
+	for _, log := range snapshot.Logs {
+		if len(log) > longest {
+			longest = len(log)
+		}
+	}
+	for i := 0; i < longest; i++ {
+		slots := make(map[string]*Message, len(snapshot.Logs))
+		distinct := make(map[string]bool)
+		for addr, log := range snapshot.Logs {
+			if i >= len(log) {
+				slots[addr] = nil
+				distinct[""] = true
+				continue
+			}
+			slots[addr] = &log[i]
+			distinct[key(&log[i])] = true
+		}
+		if len(distinct) > 1 {
+			differences = append(differences, Difference{i, slots})
+		}
+	}
+	return differences
+}
+
+// String renders the violation with a diff of the conflicting slot
+func (v Violation) String() string {
+	return renderSlots(fmt.Sprintf("%s violated at index %d:", v.Property, v.Index), v.Slots)
+}
+
+// String renders the difference with a diff of the slot
+func (d Difference) String() string {
+	return renderSlots(fmt.Sprintf("Logs differ at index %d:", d.Index), d.Slots)
+}
+
+// renderSlots under a header, marking the nodes that hold something other than the first node
+func renderSlots(header string, slots map[string]*Message) string {
+	nodes := make([]string, 0, len(slots))
+	for addr := range slots {
+		nodes = append(nodes, addr)
+	}
+	sort.Strings(nodes)
+	lines := []string{header}
+	var first *Message
+	for _, addr := range nodes {
+		m := slots[addr]
+		marker := " "
+		if first == nil && m != nil {
+			first = m
+		} else if m == nil || first == nil || key(m) != key(first) {
+			marker = "!"
+		}
+		lines = append(lines, fmt.Sprintf("  %s %-21s %s", marker, addr, describe(m)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func describe(m *Message) string {
+	if m == nil {
+		return "<missing>"
+	}
+	return fmt.Sprintf("id: %d, hash: %s, round: %d, value: '%s'", m.ID, m.MsgHash, m.RoundNum, m.Value)
+}
+
+// key identifies a learned value
+func key(m *Message) string {
+	return m.MsgHash + ":" + m.Value
+}
@@ -2,25 +2,71 @@
 package consensuslib
 
 import (
+	"consensuslib/admission"
 	"consensuslib/errors"
+	"consensuslib/metrics"
+	"consensuslib/phiaccrual"
+	"consensuslib/transport"
 	"filelogger/singletonlogger"
 	"fmt"
 	"net"
 	"net/rpc"
+	"sort"
 	"sync"
 	"time"
 )
 
+var serverLog = singletonlogger.Component("server")
+
 // Server is our server
 type Server struct {
 	rpcServer *rpc.Server
 	listener  net.Listener
+	allUsers  AllUsers
+	config    ServerConfig
+	transport *transport.Transport
+
+	// replication with the other servers of a group, see replication.go
+	addr          string
+	group         []string // every server of the group, in order of precedence
+	primary       bool     // guarded by allUsers
+	primaryAddr   string   // guarded by allUsers
+	replicateLock sync.Mutex
+	conns         map[net.Conn]bool // open connections, guarded by allUsers
+	closed        chan struct{}
+	// users with a monitor running, guarded by allUsers, so a server that steps down and takes over again
+	// before its old monitors noticed does not run two for the same user
+	monitoring map[string]bool
+
+	// membership events for subscribers, see events.go, guarded by allUsers
+	events    []Event
+	lastSeq   uint64
+	published chan struct{} // closed when an event is published
+
+	metrics          *metrics.Registry
+	heartbeats       *metrics.Counter
+	heartbeatsMissed *metrics.Counter
 }
 
 // User represents a connected client
 type User struct {
 	Address   string
+	ID        string // of the user's paxos node, "" if it is not known yet
 	Heartbeat int64
+	detector  *phiaccrual.Detector // when the server detects failures adaptively
+}
+
+// Member is a registered user as seen by an inspector
+type Member struct {
+	Address      string
+	ID           string        // of the member's paxos node
+	HeartbeatAge time.Duration // time since the member's last heartbeat
+}
+
+// RegisterArgs identify the node registering
+type RegisterArgs struct {
+	Addr string // the address the node is reached at
+	ID   string // the ID of the node, which stays the same across restarts
 }
 
 // AllUsers is the collection of all our users
@@ -29,29 +75,92 @@ type AllUsers struct {
 	all map[string]*User
 }
 
-// HeartBeat is our heartbeat rate
-type HeartBeat uint32
+// ServerConfig of a server's timings
+type ServerConfig struct {
+	HeartbeatTimeout time.Duration // a user is dropped once it goes this long without a heartbeat
+	// when not nil, a user is dropped once a phi accrual detector suspects it instead, adapting to how regularly
+	// its heartbeats arrive, so users on a slow network are not dropped for heartbeats that are merely late
+	Detector *phiaccrual.Config
+	// when not nil, clients, inspectors and the other servers of the group must connect with mutual TLS
+	TLS *transport.Config
+	// when not empty, clients, inspectors and the other servers of the group must sign every connection with
+	// this key shared by the cluster
+	ClusterKey []byte
+}
 
-var (
-	heartBeat HeartBeat = 2
-	allUsers            = AllUsers{all: make(map[string]*User)}
-)
+// DefaultServerConfig drops users after 2 seconds without a heartbeat
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{HeartbeatTimeout: 2 * time.Second}
+}
 
 // NewServer creates a new server ready to register paxosnodes
 func NewServer(addr string) (server *Server, err error) {
+	return NewReplicatedServer(addr, nil)
+}
+
+// NewReplicatedServer creates a new server replicating its users with the servers at peers.
+// Every server of the group must be given the others under the same addresses they listen on.
+func NewReplicatedServer(addr string, peers []string) (server *Server, err error) {
+	return NewServerWithConfig(addr, peers, DefaultServerConfig())
+}
+
+// NewServerWithConfig creates a new server replicating its users with the servers at peers, with the timings in config
+func NewServerWithConfig(addr string, peers []string, config ServerConfig) (server *Server, err error) {
 	server = &Server{
-		rpcServer: rpc.NewServer(),
+		rpcServer:  rpc.NewServer(),
+		allUsers:   AllUsers{all: make(map[string]*User)},
+		config:     config,
+		conns:      make(map[net.Conn]bool),
+		closed:     make(chan struct{}),
+		monitoring: make(map[string]bool),
+	}
+	server.transport, err = transport.New(config.TLS, admission.New(config.ClusterKey))
+	if err != nil {
+		return nil, fmt.Errorf("unable to set up TLS: %s", err)
 	}
 	server.rpcServer.Register(server)
-	listener, err := net.Listen("tcp", addr)
+	server.registerMetrics()
+	listener, err := server.transport.Listen(addr)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create a listener on the server addres: %s", err)
 	}
 	server.listener = listener
-	singletonlogger.Info("Server started at " + listener.Addr().String())
+	server.addr = listener.Addr().String()
+	// the other servers of the group know this one by the address it listens on
+	server.transport = server.transport.As(server.addr)
+	server.joinGroup(peers)
+	serverLog.Info("Server started at " + listener.Addr().String())
 	return server, nil
 }
 
+// registerMetrics about the server's users and their heartbeats
+func (s *Server) registerMetrics() {
+	s.metrics = metrics.NewRegistry()
+	s.metrics.NewGauge("server_registered_users", "Number of paxos nodes registered and alive", func() float64 {
+		s.allUsers.RLock()
+		defer s.allUsers.RUnlock()
+		return float64(len(s.allUsers.all))
+	})
+	s.heartbeats = s.metrics.NewCounter("server_heartbeats_total", "Heartbeats received from registered nodes")
+	s.heartbeatsMissed = s.metrics.NewCounter("server_heartbeats_missed_total", "Nodes dropped for missing a heartbeat")
+	s.metrics.NewGauge("server_primary", "1 if this server is the primary of its group, 0 if it is a backup", func() float64 {
+		if s.IsPrimary() {
+			return 1
+		}
+		return 0
+	})
+}
+
+// ServeMetrics at http://addr/metrics, or over mutual TLS when the server has TLS, until the server is closed.
+// Returns the address they are served on. A cluster key does not cover them, see transport.ListenHTTP.
+func (s *Server) ServeMetrics(addr string) (string, error) {
+	listener, err := s.transport.ListenHTTP(addr)
+	if err != nil {
+		return "", fmt.Errorf("unable to listen for metrics on %s: %s", addr, err)
+	}
+	return s.metrics.Serve(listener), nil
+}
+
 // Serve for clients
 func (s *Server) Serve() error {
 	for {
@@ -59,29 +168,95 @@ func (s *Server) Serve() error {
 		if err != nil {
 			return fmt.Errorf("[ConsensusLib/serv] Unable to accept connection: %s", err)
 		}
-		singletonlogger.Debug(fmt.Sprintf("[ConsensusLib/serv] Serving %s\n", s.listener.Addr().String()))
-		go s.rpcServer.ServeConn(conn)
+		serverLog.Debugf("Serving %s", s.listener.Addr().String())
+		s.allUsers.Lock()
+		s.conns[conn] = true
+		s.allUsers.Unlock()
+		go func() {
+			// backups and clients dial a connection per probe, so forget each one once it closes
+			defer func() {
+				s.allUsers.Lock()
+				delete(s.conns, conn)
+				s.allUsers.Unlock()
+			}()
+			transport.ServeConn(s.rpcServer, conn, s.authorize)
+		}()
 	}
 }
 
-// Register a client with the server
-func (s *Server) Register(addr string, res *[]string) error {
-	allUsers.Lock()
-	defer allUsers.Unlock()
-
-	if _, exists := allUsers.all[addr]; exists {
-		return errors.AddressAlreadyRegisteredError(addr)
+// authorize a call acting for a node only from that node, see transport.Authorizer
+func (s *Server) authorize(peer *transport.Peer, method string, args interface{}) error {
+	var addr string
+	switch method {
+	case "Server.Register":
+		addr = args.(RegisterArgs).Addr
+	case "Server.HeartBeat", "Server.Leave":
+		addr = args.(string)
+	case "Server.Subscribe":
+		addr = args.(SubscribeArgs).Addr
+	case "Server.Replicate":
+		if peer != nil && !s.isReplica(peer.Addr) {
+			serverLog.Infof("Refused %s from %s, which is not a server of the group", method, peer.Addr)
+			return errors.UnverifiedCallerError(peer.Addr)
+		}
+		return nil
+	default:
+		return nil
 	}
-	allUsers.all[addr] = &User{
-		addr,
-		time.Now().UnixNano(),
+	if !peer.Vouches(addr) {
+		serverLog.Infof("Refused %s for %s from %s", method, addr, peer.Addr)
+		return errors.UnverifiedCallerError(addr)
 	}
+	return nil
+}
 
-	go monitor(addr, time.Duration(heartBeat)*time.Second)
+// Close the server, and every connection to it
+func (s *Server) Close() error {
+	close(s.closed)
+	err := s.listener.Close()
+	s.metrics.Close()
+	s.allUsers.Lock()
+	defer s.allUsers.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	return err
+}
+
+// Register a client with the server. A node registering an address it is already registered at
+// is taken to be rejoining after a restart, before it timed out, and any other node gets an
+// AddressAlreadyRegisteredError until that one leaves or times out. A node registering its ID
+// at a new address restarted there, and its old address is dropped.
+func (s *Server) Register(args RegisterArgs, res *[]string) error {
+	addr := args.Addr
+	s.allUsers.Lock()
+	defer s.allUsers.Unlock()
+
+	if !s.primary {
+		return errors.NotPrimaryError(s.primaryAddr)
+	}
+	if user, exists := s.allUsers.all[addr]; exists {
+		if user.ID != "" && user.ID != args.ID {
+			serverLog.Infof("Refused %s at %s, which %s is registered at", args.ID, addr, user.ID)
+			return errors.AddressAlreadyRegisteredError(addr)
+		}
+		// already monitored
+		serverLog.Infof("%s rejoined", addr)
+		user.ID = args.ID
+		user.Heartbeat = time.Now().UnixNano()
+	} else {
+		if moved := s.userWithID(args.ID); moved != nil {
+			serverLog.Infof("%s moved from %s to %s", args.ID, moved.Address, addr)
+			delete(s.allUsers.all, moved.Address)
+			s.publish(LEAVE, moved.Address)
+		}
+		s.allUsers.all[addr] = s.newUser(addr, args.ID)
+		s.startMonitor(addr)
+	}
 
 	neighbourAddresses := make([]string, 0)
 
-	for _, val := range allUsers.all {
+	for _, val := range s.allUsers.all {
 		if addr == val.Address {
 			continue
 		}
@@ -89,7 +264,9 @@ func (s *Server) Register(addr string, res *[]string) error {
 	}
 	*res = neighbourAddresses
 
-	singletonlogger.Info(fmt.Sprintf("Got Register from %s", addr))
+	serverLog.Infof("Got Register from %s", addr)
+	s.publish(JOIN, addr)
+	go s.replicate()
 
 	return nil
 
@@ -97,15 +274,24 @@ func (s *Server) Register(addr string, res *[]string) error {
 
 // HeartBeat from proj1 server.go implementation by Ivan Beschastnikh adapted by Alex Budkina
 func (s *Server) HeartBeat(addr string, _ignored *bool) error {
-	allUsers.Lock()
-	defer allUsers.Unlock()
+	s.allUsers.Lock()
+	defer s.allUsers.Unlock()
 
-	if _, ok := allUsers.all[addr]; !ok {
+	if !s.primary {
+		return errors.NotPrimaryError(s.primaryAddr)
+	}
+	if _, ok := s.allUsers.all[addr]; !ok {
 		// TODO: check right chanage
 		return errors.UnknownKeyError("")
 	}
 
-	allUsers.all[addr].Heartbeat = time.Now().UnixNano()
+	now := time.Now()
+	user := s.allUsers.all[addr]
+	user.Heartbeat = now.UnixNano()
+	if user.detector != nil {
+		user.detector.Heartbeat(now)
+	}
+	s.heartbeats.Inc()
 
 	return nil
 }
@@ -116,18 +302,131 @@ func (s *Server) CheckAlive(addr string, alive *bool) error {
 	return nil
 }
 
+// Members lists every registered user, sorted by address
+func (s *Server) Members(placeholder string, members *[]Member) error {
+	s.allUsers.RLock()
+	defer s.allUsers.RUnlock()
+
+	now := time.Now().UnixNano()
+	*members = make([]Member, 0, len(s.allUsers.all))
+	for _, user := range s.allUsers.all {
+		*members = append(*members, Member{user.Address, user.ID, time.Duration(now - user.Heartbeat)})
+	}
+	sort.Slice(*members, func(i, j int) bool { return (*members)[i].Address < (*members)[j].Address })
+	return nil
+}
+
+// Remove a user, so new users are no longer told about it. With TLS or a cluster key, only callers
+// holding a certificate from the cluster's CA or the key can remove users.
+func (s *Server) Remove(addr string, removed *bool) error {
+	err := s.removeUser(addr)
+	if err == nil {
+		serverLog.Infof("Removed %s", addr)
+	}
+	*removed = err == nil
+	return err
+}
+
+// Leave from a client that is leaving the network, so it is not waited on to time out.
+// Only the client itself can leave, see authorize.
+func (s *Server) Leave(addr string, left *bool) error {
+	err := s.removeUser(addr)
+	if err == nil {
+		serverLog.Infof("%s left", addr)
+	}
+	*left = err == nil
+	return err
+}
+
+// removeUser and tell subscribers it left
+func (s *Server) removeUser(addr string) error {
+	s.allUsers.Lock()
+	defer s.allUsers.Unlock()
+
+	if !s.primary {
+		return errors.NotPrimaryError(s.primaryAddr)
+	}
+	if _, ok := s.allUsers.all[addr]; !ok {
+		return errors.UnknownKeyError(addr)
+	}
+	delete(s.allUsers.all, addr)
+	s.publish(LEAVE, addr)
+	go s.replicate()
+	return nil
+}
+
+// userWithID registered, or nil if there is none or id is empty. Called with allUsers locked.
+func (s *Server) userWithID(id string) *User {
+	if id == "" {
+		return nil
+	}
+	for _, user := range s.allUsers.all {
+		if user.ID == id {
+			return user
+		}
+	}
+	return nil
+}
+
+// startMonitor of the user at addr, unless one is running. Called with allUsers locked.
+func (s *Server) startMonitor(addr string) {
+	if s.monitoring[addr] {
+		return
+	}
+	s.monitoring[addr] = true
+	go s.monitor(addr)
+}
+
 // from proj1 server.go implementation by Ivan Beschastnikh, adapted by Alex Budkina and Graham Brown
-func monitor(k string, heartBeatInterval time.Duration) {
+func (s *Server) monitor(k string) {
+	interval := s.monitorInterval()
 	for {
-		allUsers.Lock()
-		if time.Now().UnixNano()-allUsers.all[k].Heartbeat > int64(heartBeatInterval) {
-			singletonlogger.Info(fmt.Sprintf("%s timed out", allUsers.all[k].Address))
-			delete(allUsers.all, k)
-			allUsers.Unlock()
+		s.allUsers.Lock()
+		if _, ok := s.allUsers.all[k]; !ok || !s.primary || s.isClosed() {
+			// removed by an inspector, or no longer the primary to monitor it
+			delete(s.monitoring, k)
+			s.allUsers.Unlock()
+			return
+		}
+		if s.timedOut(s.allUsers.all[k]) {
+			serverLog.Infof("%s timed out", s.allUsers.all[k].Address)
+			s.heartbeatsMissed.Inc()
+			delete(s.allUsers.all, k)
+			delete(s.monitoring, k)
+			s.publish(LEAVE, k)
+			s.allUsers.Unlock()
+			go s.replicate()
 			return
 		}
-		singletonlogger.Info(fmt.Sprintf("%s is alive", allUsers.all[k].Address))
-		allUsers.Unlock()
-		time.Sleep(heartBeatInterval)
+		serverLog.Infof("%s is alive", s.allUsers.all[k].Address)
+		s.allUsers.Unlock()
+		time.Sleep(interval)
+	}
+}
+
+// newUser at addr with the node id, given a fresh heartbeat
+func (s *Server) newUser(addr string, id string) *User {
+	now := time.Now()
+	user := &User{Address: addr, ID: id, Heartbeat: now.UnixNano()}
+	if s.config.Detector != nil {
+		user.detector = phiaccrual.New(*s.config.Detector, now)
+	}
+	return user
+}
+
+// timedOut says if the user went too long without a heartbeat
+func (s *Server) timedOut(user *User) bool {
+	if user.detector != nil {
+		return !user.detector.Available(time.Now())
+	}
+	return time.Now().UnixNano()-user.Heartbeat > int64(s.config.HeartbeatTimeout)
+}
+
+// monitorInterval is how often users are checked. Phi grows continuously, so it is checked more often
+// than a fixed timeout needs to be.
+func (s *Server) monitorInterval() time.Duration {
+	if s.config.Detector != nil {
+		return s.probeInterval()
 	}
+	return s.config.HeartbeatTimeout
 }
@@ -0,0 +1,99 @@
+package tracing
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// The subset of the OpenTelemetry protocol's JSON encoding needed to export spans.
+// See opentelemetry-proto's trace/v1/trace.proto for the full schema.
+
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope      `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string      `json:"traceId"`
+	SpanID            string      `json:"spanId"`
+	ParentSpanID      string      `json:"parentSpanId,omitempty"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []keyValue  `json:"attributes,omitempty"`
+	Status            *otlpStatus `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 2 is an error
+	Message string `json:"message,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"` // int64s are strings in OTLP/JSON
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// attributes sorted by key
+func attributes(attrs map[string]interface{}) []keyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kvs := make([]keyValue, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, keyValue{Key: k, Value: toAnyValue(attrs[k])})
+	}
+	return kvs
+}
+
+func toAnyValue(v interface{}) (a anyValue) {
+	switch v := v.(type) {
+	case string:
+		a.StringValue = &v
+	case bool:
+		a.BoolValue = &v
+	case int:
+		i := strconv.FormatInt(int64(v), 10)
+		a.IntValue = &i
+	case int64:
+		i := strconv.FormatInt(v, 10)
+		a.IntValue = &i
+	case uint64:
+		i := strconv.FormatUint(v, 10)
+		a.IntValue = &i
+	case float64:
+		a.DoubleValue = &v
+	default:
+		s := fmt.Sprintf("%v", v)
+		a.StringValue = &s
+	}
+	return a
+}
@@ -0,0 +1,184 @@
+/*
+Package tracing records spans of a write as it fans out across paxos nodes, and exports them
+in the OpenTelemetry protocol's JSON encoding, one export request per line.
+
+Span contexts travel between nodes on the messages they exchange. A nil Tracer or Span does nothing,
+so call sites need not check whether tracing is on.
+*/
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"filelogger/singletonlogger"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var tracingLog = singletonlogger.Component("tracing")
+
+// Kind of a span, numbered as in OpenTelemetry
+type Kind int
+
+const (
+	// INTERNAL work within a node
+	INTERNAL Kind = 1
+	// SERVER handling of an RPC from another node
+	SERVER Kind = 2
+	// CLIENT side of an RPC to another node
+	CLIENT Kind = 3
+)
+
+// SpanContext identifies a span across nodes
+type SpanContext struct {
+	TraceID string // 32 hex digits, shared by every span of a write
+	SpanID  string // 16 hex digits
+}
+
+// Tracer starts spans on behalf of a node, and exports them when they end
+type Tracer struct {
+	sync.Mutex
+	service string
+	file    *os.File
+}
+
+// NewTracer for the node named service, exporting to a new file at path
+func NewTracer(service string, path string) (t *Tracer, err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create span file: %s", err)
+	}
+	return &Tracer{service: service, file: f}, nil
+}
+
+// Close the tracer's file
+func (t *Tracer) Close() error {
+	if t == nil {
+		return nil
+	}
+	t.Lock()
+	defer t.Unlock()
+	return t.file.Close()
+}
+
+// Start a span named name, as a child of parent or as the root of a new trace if parent is nil
+func (t *Tracer) Start(name string, parent *SpanContext, kind Kind) *Span {
+	if t == nil {
+		return nil
+	}
+	s := &Span{
+		tracer:     t,
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: make(map[string]interface{}),
+	}
+	s.context.SpanID = randomID(8)
+	if parent != nil {
+		s.context.TraceID = parent.TraceID
+		s.parentID = parent.SpanID
+	} else {
+		s.context.TraceID = randomID(16)
+	}
+	return s
+}
+
+// Span is a timed operation within a trace
+type Span struct {
+	sync.Mutex
+	tracer     *Tracer
+	name       string
+	kind       Kind
+	context    SpanContext
+	parentID   string
+	start      time.Time
+	attributes map[string]interface{}
+	err        string
+	ended      bool
+}
+
+// Context to propagate to the span's children, or nil for a nil span
+func (s *Span) Context() *SpanContext {
+	if s == nil {
+		return nil
+	}
+	c := s.context
+	return &c
+}
+
+// SetAttribute of the span, such as the round or peer it is about
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.attributes[key] = value
+}
+
+// SetError marks the span as failed with err, if err is not nil
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.err = err.Error()
+}
+
+// End the span and export it. Later calls do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	if s.ended {
+		s.Unlock()
+		return
+	}
+	s.ended = true
+	line, err := json.Marshal(s.export(time.Now()))
+	s.Unlock()
+	if err != nil {
+		tracingLog.Errorf("unable to export span %s: %s", s.name, err)
+		return
+	}
+	t := s.tracer
+	t.Lock()
+	defer t.Unlock()
+	_, err = t.file.Write(append(line, '\n'))
+	if err != nil {
+		tracingLog.Errorf("unable to export span %s: %s", s.name, err)
+	}
+}
+
+// export the span as an OTLP/JSON export request. Must be called holding the span's lock.
+func (s *Span) export(end time.Time) exportRequest {
+	span := otlpSpan{
+		TraceID:           s.context.TraceID,
+		SpanID:            s.context.SpanID,
+		ParentSpanID:      s.parentID,
+		Name:              s.name,
+		Kind:              int(s.kind),
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+		Attributes:        attributes(s.attributes),
+	}
+	if s.err != "" {
+		span.Status = &otlpStatus{Code: 2, Message: s.err}
+	}
+	return exportRequest{ResourceSpans: []resourceSpans{{
+		Resource:   resource{Attributes: attributes(map[string]interface{}{"service.name": s.tracer.service})},
+		ScopeSpans: []scopeSpans{{Scope: scope{Name: "consensuslib"}, Spans: []otlpSpan{span}}},
+	}}}
+}
+
+func randomID(bytes int) string {
+	id := make([]byte, bytes)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,76 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/gob"
+	"io"
+	"net/rpc"
+	"reflect"
+)
+
+// serverCodec is the gob codec of net/rpc, checking every call with an Authorizer once its argument is read.
+// net/rpc answers a call whose argument cannot be read with the error, and goes on serving the connection.
+type serverCodec struct {
+	rwc       io.ReadWriteCloser
+	dec       *gob.Decoder
+	enc       *gob.Encoder
+	encBuf    *bufio.Writer
+	closed    bool
+	peer      *Peer
+	authorize Authorizer
+	method    string // of the call being read
+}
+
+func newServerCodec(conn io.ReadWriteCloser, peer *Peer, authorize Authorizer) *serverCodec {
+	buf := bufio.NewWriter(conn)
+	return &serverCodec{
+		rwc:       conn,
+		dec:       gob.NewDecoder(conn),
+		enc:       gob.NewEncoder(buf),
+		encBuf:    buf,
+		peer:      peer,
+		authorize: authorize,
+	}
+}
+
+func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	err := c.dec.Decode(r)
+	c.method = r.ServiceMethod
+	return err
+}
+
+func (c *serverCodec) ReadRequestBody(body interface{}) error {
+	if err := c.dec.Decode(body); err != nil {
+		return err
+	}
+	if body == nil || c.authorize == nil {
+		// the call is discarded anyway
+		return nil
+	}
+	return c.authorize(c.peer, c.method, reflect.ValueOf(body).Elem().Interface())
+}
+
+func (c *serverCodec) WriteResponse(r *rpc.Response, body interface{}) (err error) {
+	if err = c.enc.Encode(r); err != nil {
+		if c.encBuf.Flush() == nil {
+			// gob couldn't encode the header, so the connection is out of sync
+			c.Close()
+		}
+		return err
+	}
+	if err = c.enc.Encode(body); err != nil {
+		if c.encBuf.Flush() == nil {
+			c.Close()
+		}
+		return err
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *serverCodec) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.rwc.Close()
+}
@@ -0,0 +1,403 @@
+/*
+Package transport carries the RPCs between servers, clients and paxos nodes, over plain TCP or mutual TLS.
+
+With TLS, every connection is authenticated both ways against the cluster's CA:
+  - a dialler verifies that the certificate of the node it dials names the address it knows the node by,
+    such as a neighbour's address from the membership, so no other node can answer in its place
+  - a listener requires a certificate from every caller, and verifies it names the IP address the caller
+    connects from, so a node cannot pass itself off as another member
+
+Once the TLS handshake is done, the dialler says hello with the address it is reached at, and the listener
+checks the caller's certificate names its host. The caller is then the Peer at that address for as long as
+the connection lasts, and ServeConn lets an Authorizer check every call against it, so a node can only
+register, heartbeat or leave as itself. Tools such as paxosctl declare no address.
+
+With a cluster key, with or without TLS, the hellos are signed with it (see the admission package), and the
+listener's answer is signed in reply to the dialler's, so neither end takes part in a connection unless the
+other holds the key. Stray nodes cannot then register, become neighbours, ping or send paxos messages.
+
+Certificates name nodes by IP address, or by DNS name for nodes known by their host name. Nodes sharing a
+host share its certificate, so they can vouch for each other's ports.
+
+A nil *Transport is plain TCP, so callers can use one whether or not TLS is configured.
+*/
+package transport
+
+import (
+	"consensuslib/admission"
+	"consensuslib/errors"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"filelogger/singletonlogger"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"time"
+)
+
+var transportLog = singletonlogger.Component("transport")
+
+// handshakeTimeout is how long either end waits for the other to complete the handshake
+const handshakeTimeout = 5 * time.Second
+
+// maxHello is the size of the largest hello either end reads
+const maxHello = 4096
+
+// Config of mutual TLS
+type Config struct {
+	CAFile   string // PEM certificates of the CA that signs every node's certificate
+	CertFile string // PEM certificate of this node, signed by the CA
+	KeyFile  string // PEM private key of the certificate
+}
+
+// Transport dials and listens for RPC connections
+type Transport struct {
+	tls       *tls.Config          // nil without TLS
+	admission *admission.Admission // signs and verifies the hellos, nil without a cluster key
+	self      string               // the address declared to the nodes dialled, "" for a tool
+}
+
+// New transport with TLS configured by config, or plain TCP if config is nil, saying hello with the cluster
+// key of a if it is not nil. Without either, the transport is nil.
+func New(config *Config, a *admission.Admission) (t *Transport, err error) {
+	if config == nil {
+		if a == nil {
+			return nil, nil
+		}
+		return &Transport{admission: a}, nil
+	}
+	cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the certificate: %s", err)
+	}
+	pem, err := ioutil.ReadFile(config.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the CA: %s", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate in the CA file %s", config.CAFile)
+	}
+	return &Transport{tls: &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, admission: a}, nil
+}
+
+// As the node reached at self: the transport declares self to every node it dials, which lets it act for self
+func (t *Transport) As(self string) *Transport {
+	if t == nil {
+		return nil
+	}
+	as := *t
+	as.self = self
+	return &as
+}
+
+// Secure says if the transport uses TLS
+func (t *Transport) Secure() bool {
+	return t != nil && t.tls != nil
+}
+
+// Listen for connections at addr
+func (t *Transport) Listen(addr string) (net.Listener, error) {
+	inner, err := net.Listen("tcp", addr)
+	if err != nil || t == nil {
+		return inner, err
+	}
+	l := &listener{
+		Listener:  inner,
+		config:    t.tls,
+		admission: t.admission,
+		conns:     make(chan net.Conn),
+		closed:    make(chan struct{}),
+	}
+	go l.serve()
+	return l, nil
+}
+
+// ListenHTTP for HTTP requests at addr, such as status and metrics scrapes. With TLS, requests are served
+// over mutual TLS, so a scraper needs a certificate from the cluster's CA. HTTP has no hello, so the cluster
+// key does not admit its callers, and without TLS the listener is plain TCP open to anyone who can reach addr.
+func (t *Transport) ListenHTTP(addr string) (net.Listener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil || !t.Secure() {
+		return l, err
+	}
+	return tls.NewListener(l, t.tls), nil
+}
+
+// Dial the node at addr
+func (t *Transport) Dial(addr string) (net.Conn, error) {
+	return t.DialTimeout(addr, 0)
+}
+
+// DialTimeout the node at addr, giving up after timeout unless it is 0
+func (t *Transport) DialTimeout(addr string, timeout time.Duration) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	if t == nil {
+		return dialer.Dial("tcp", addr)
+	}
+	var conn net.Conn
+	var err error
+	if t.tls == nil {
+		conn, err = dialer.Dial("tcp", addr)
+	} else {
+		host, _, splitErr := net.SplitHostPort(addr)
+		if splitErr != nil {
+			return nil, splitErr
+		}
+		config := t.tls.Clone()
+		// the node's certificate must name the address it is known by
+		config.ServerName = host
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, config)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	if err = t.sayHello(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to say hello to %s: %s", addr, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// sayHello on conn as the dialler, and check the listener's answer
+func (t *Transport) sayHello(conn net.Conn) error {
+	h, err := t.admission.Sign(t.self)
+	if err != nil {
+		return err
+	}
+	if err = writeHello(conn, h); err != nil {
+		return err
+	}
+	answer, err := readHello(conn)
+	if err != nil {
+		return err
+	}
+	return t.admission.VerifyReply(answer, h)
+}
+
+// DialRPC to the node at addr
+func (t *Transport) DialRPC(addr string) (*rpc.Client, error) {
+	conn, err := t.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	return rpc.NewClient(conn), nil
+}
+
+// Call method on the node at addr over a connection for that call only, giving up after timeout unless it is 0
+func (t *Transport) Call(addr string, timeout time.Duration, method string, args interface{}, reply interface{}) error {
+	conn, err := t.DialTimeout(addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if timeout != 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+	return rpc.NewClient(conn).Call(method, args, reply)
+}
+
+// Peer at the other end of a connection a listener accepted, as verified by the handshake
+type Peer struct {
+	Addr string // the address the peer is reached at, "" for a tool
+}
+
+// Vouches says if the peer is the node at addr, so it can act for it. With plain TCP, callers cannot
+// be told apart, and the nil peer vouches for any node.
+func (p *Peer) Vouches(addr string) bool {
+	return p == nil || p.Addr != "" && p.Addr == addr
+}
+
+// Conn a listener accepted, from a verified peer
+type Conn struct {
+	net.Conn
+	Peer *Peer
+}
+
+// PeerOf the caller on conn, or nil for plain TCP
+func PeerOf(conn net.Conn) *Peer {
+	if c, ok := conn.(*Conn); ok {
+		return c.Peer
+	}
+	return nil
+}
+
+// Authorizer says why peer cannot call method with args, or nil if it can. args is the call's
+// argument, dereferenced. A nil peer means the call came over plain TCP.
+type Authorizer func(peer *Peer, method string, args interface{}) error
+
+// Serve the RPCs of server on every connection listener accepts, until it is closed
+func Serve(server *rpc.Server, listener net.Listener, authorize Authorizer) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			transportLog.Debugf("stopped serving %s: %s", listener.Addr(), err)
+			return
+		}
+		go ServeConn(server, conn, authorize)
+	}
+}
+
+// ServeConn serves the RPCs of server on conn, refusing the calls authorize does not allow
+func ServeConn(server *rpc.Server, conn net.Conn, authorize Authorizer) {
+	server.ServeCodec(newServerCodec(conn, PeerOf(conn), authorize))
+}
+
+// hello a dialler says once a connection is secured, and the listener answers. Addr is the address the sender
+// is reached at, "" for a tool, and the rest is only set with a cluster key.
+type hello = admission.Request
+
+// writeHello as a 4 byte big-endian length followed by the hello in JSON, so it can be read without reading ahead
+func writeHello(conn net.Conn, h hello) error {
+	buf, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, 4+len(buf))
+	binary.BigEndian.PutUint32(frame, uint32(len(buf)))
+	copy(frame[4:], buf)
+	_, err = conn.Write(frame)
+	return err
+}
+
+// readHello written by writeHello
+func readHello(conn net.Conn) (h hello, err error) {
+	var size [4]byte
+	if _, err = io.ReadFull(conn, size[:]); err != nil {
+		return h, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxHello {
+		return h, fmt.Errorf("hello of %d bytes is too long", n)
+	}
+	buf := make([]byte, n)
+	if _, err = io.ReadFull(conn, buf); err != nil {
+		return h, err
+	}
+	return h, json.Unmarshal(buf, &h)
+}
+
+// listener completing the handshake of every connection, and verifying the caller, before accepting it.
+// Handshakes run concurrently, so a slow caller does not hold up the others.
+type listener struct {
+	net.Listener
+	config    *tls.Config // nil without TLS
+	admission *admission.Admission
+	conns     chan net.Conn
+	closed    chan struct{}
+	err       error // why serving stopped, once closed is
+}
+
+// Accept the next connection that completed its handshake
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, l.err
+	}
+}
+
+// serve handshakes until the listener is closed
+func (l *listener) serve() {
+	for {
+		raw, err := l.Listener.Accept()
+		if err != nil {
+			l.err = err
+			close(l.closed)
+			return
+		}
+		go l.handshake(raw)
+	}
+}
+
+// handshake with the caller on raw, handing the connection to Accept if the caller is verified
+func (l *listener) handshake(raw net.Conn) {
+	conn := raw
+	raw.SetDeadline(time.Now().Add(handshakeTimeout))
+	var cert *x509.Certificate
+	var err error
+	if l.config != nil {
+		secured := tls.Server(raw, l.config)
+		if err = secured.Handshake(); err == nil {
+			err = verifyCaller(secured)
+		}
+		if err == nil {
+			cert = secured.ConnectionState().PeerCertificates[0]
+		}
+		conn = secured
+	}
+	var peer *Peer
+	if err == nil {
+		peer, err = l.greet(conn, cert)
+	}
+	if err != nil {
+		transportLog.Infof("rejected connection: %s", err)
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	select {
+	case l.conns <- &Conn{conn, peer}:
+	case <-l.closed:
+		conn.Close()
+	}
+}
+
+// greet the caller on conn: read its hello, check it is signed with the cluster key and that the caller's
+// certificate, if it has one, names the host it declares, and answer it
+func (l *listener) greet(conn net.Conn, cert *x509.Certificate) (peer *Peer, err error) {
+	h, err := readHello(conn)
+	if err != nil {
+		return nil, fmt.Errorf("no hello from %s: %s", conn.RemoteAddr(), err)
+	}
+	if h.InReplyTo != "" {
+		// an answer captured on the network, replayed as a hello
+		return nil, errors.AdmissionDeniedError(h.Addr)
+	}
+	if err = l.admission.Verify(h); err != nil {
+		return nil, err
+	}
+	if h.Addr != "" && cert != nil {
+		host, _, err := net.SplitHostPort(h.Addr)
+		if err != nil {
+			return nil, err
+		}
+		if err = cert.VerifyHostname(host); err != nil {
+			return nil, errors.UnverifiedCallerError(h.Addr)
+		}
+	}
+	answer, err := l.admission.SignReply(l.Addr().String(), h)
+	if err != nil {
+		return nil, err
+	}
+	return &Peer{Addr: h.Addr}, writeHello(conn, answer)
+}
+
+// verifyCaller checks the caller's certificate names the IP address it connects from
+func verifyCaller(conn *tls.Conn) error {
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return fmt.Errorf("no certificate from %s", conn.RemoteAddr())
+	}
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return err
+	}
+	if err = certs[0].VerifyHostname(host); err != nil {
+		return fmt.Errorf("certificate of %s does not name it: %s", conn.RemoteAddr(), err)
+	}
+	return nil
+}
@@ -13,12 +13,19 @@ package main
 
 import (
 	"consensuslib"
+	"consensuslib/admission"
+	"consensuslib/membership"
+	"consensuslib/transport"
 	"distributeddiaryapp/cli"
 	"distributeddiaryapp/networking"
+	"encoding/json"
+	"filelogger/format"
+	"filelogger/logger"
 	"filelogger/singletonlogger"
 	"filelogger/state"
 	"fmt"
 	"os"
+	"path/filepath"
 	"paxostracker"
 	"regexp"
 	"strconv"
@@ -26,48 +33,184 @@ import (
 	"time"
 )
 
-var validArgs = regexp.MustCompile("[0-9]{1,3}\\.[0-9]{1,3}\\.[0-9]{1,3}:[0-9]{1,5} [0-9]{1,5}( " + localFlag + ")*( " + debugFlag + ")*")
-var breaked bool
-var written bool
-var breakState, killState string
+var appLog = singletonlogger.Component("app")
+
+var validArgs = regexp.MustCompile("(" + noServer + "|[0-9]{1,3}\\.[0-9]{1,3}\\.[0-9]{1,3}\\.[0-9]{1,3}:[0-9]{1,5}(,[0-9]{1,3}\\.[0-9]{1,3}\\.[0-9]{1,3}\\.[0-9]{1,3}:[0-9]{1,5})*) [0-9]{1,5}( " + localFlag + ")*( " + debugFlag + ")*( " + recordFlag + ")*( " + shivizFlag + ")*( " + jsonLogsFlag + ")*( " + logDirFlag + "=\\S+)*( " + httpFlag + "=\\S+)*( " + tracingFlag + ")*( " + peersFlag + "=\\S+)*( " + swimFlag + ")*( " + resumeFlag + ")*( " + heartbeatFlag + "=\\S+)*( " + auditFlag + "=\\S+)*( " + dataDirFlag + "=\\S+)*( " + advertiseAddrFlag + "=\\S+)*( " + advertiseIfaceFlag + "=\\S+)*( " + advertiseCIDRFlag + "=\\S+)*( " + tlsCAFlag + "=\\S+)*( " + tlsCertFlag + "=\\S+)*( " + tlsKeyFlag + "=\\S+)*( " + clusterKeyFlag + "=\\S+)*")
+var killState string
 
 const (
-	debugFlag = "--debug"
-	localFlag = "--local"
-	usage     = `==================================================
+	debugFlag          = "--debug"
+	localFlag          = "--local"
+	recordFlag         = "--record"
+	shivizFlag         = "--shiviz"
+	jsonLogsFlag       = "--jsonlogs"
+	logDirFlag         = "--logdir"
+	httpFlag           = "--http"
+	tracingFlag        = "--tracing"
+	peersFlag          = "--peers"
+	swimFlag           = "--swim"
+	resumeFlag         = "--resume"
+	heartbeatFlag      = "--heartbeat"
+	auditFlag          = "--audit"
+	dataDirFlag        = "--datadir"
+	advertiseAddrFlag  = "--advertise-addr"
+	advertiseIfaceFlag = "--advertise-iface"
+	advertiseCIDRFlag  = "--advertise-cidr"
+	tlsCAFlag          = "--tls-ca"
+	tlsCertFlag        = "--tls-cert"
+	tlsKeyFlag         = "--tls-key"
+	clusterKeyFlag     = "--cluster-key"
+	noServer           = "-"
+	usage              = `==================================================
 The Chamber of Secrets: A Distributed Diary App
 ==================================================
 Usage: go run app.go serverAddress PORT [options]
 
-Server address must be of the form 255.255.255.255:12345
+Server address must be of the form 255.255.255.255:12345. With a replicated server, give every server
+of the group separated by commas, such as 10.0.0.1:12345,10.0.0.2:12345, to fail over between them.
+Use - as the server address to run without a server, joining the nodes given with --peers.
 
 Valid options:
 
 --local : run on local machine at 127.0.0.1 with the specified port
 --debug : run with debugging turned on for verbose logging
+--record : record every input of this node into traces/ for replaying with paxosreplay
+--shiviz : log paxos messages with vector clocks into logs/*.shiviz.log, for visualising with ShiViz
+--jsonlogs : write logs/*.log as one JSON object per line, with the component and fields as keys
+--logdir=DIR : write logs to DIR instead of logs/. Logs are rotated daily or every 10MB, and kept for a week
+--http=ADDR : serve this node's status as JSON at http://ADDR/status, and Prometheus metrics at http://ADDR/metrics
+  With --tls-*, they are served over mutual TLS instead. --cluster-key does not cover them, so without TLS
+  give ADDR on a private interface
+--tracing : export spans of every write and RPC into traces/*.spans.json, as OpenTelemetry JSON lines
+--peers=ADDR,ADDR : with - as the server address, join the network through whichever of the nodes at ADDR,ADDR
+  are up, learning its other members from them. Without any up, start a new network
+--swim : detect failed nodes by gossiping with the other --swim nodes, instead of dropping a node as soon as
+  a request to it fails
+--resume : rejoin with the log this node saved in its data directory before it was restarted, instead of
+  starting from an empty log. The acceptor state saved there is restored either way
+--datadir=DIR : keep this node's ID and state in DIR instead of temp1/PORT. The node keeps its ID across restarts
+  on the same DIR, even when its port or IP address changes
+--heartbeat=DURATION : send heartbeats to the server every DURATION, such as 500ms. Defaults to 1ms
+--audit=DURATION : audit the logs of every reachable node for violations of Paxos safety every DURATION, such as
+  30s, logging any found as errors. The audit command runs one audit on demand
+--advertise-addr=IP[:PORT] : tell other nodes to reach this one at IP, and at PORT if it is mapped to another port
+--advertise-iface=NAME : tell other nodes to reach this one at the address of the network interface NAME
+--advertise-cidr=CIDR : tell other nodes to reach this one at its address in the network CIDR, such as 10.0.0.0/8
+  Without any of these, the address is read from $DIARY_ADVERTISE_ADDR or $POD_IP, or else is the address of the
+  interface the default route goes through. None of these need internet access
+--tls-ca=FILE --tls-cert=FILE --tls-key=FILE : talk to the server and the other nodes over mutual TLS, with the
+  PEM certificate and key of this node signed by the CA in FILE. The certificate must name the address other nodes
+  reach this one at. Every node and server of the network must be given certificates from the same CA
+--cluster-key=FILE : sign every connection to the server and the other nodes with the key shared by the cluster
+  in FILE, and only serve connections signed with it. Every node and server of the network, and paxosctl, must
+  be given the same key, and their clocks must agree to within a minute
 `
 )
 
+// options set by flags
+type options struct {
+	logstate  state.State
+	logformat format.Format
+	logdir    string
+	record    bool
+	shiviz    bool
+	http      string
+	tracing   bool
+	peers     []string
+	swim      bool
+	resume    bool
+	heartbeat time.Duration
+	audit     time.Duration
+	datadir   string
+	advertise networking.Options
+	tls       transport.Config
+	keyFile   string
+}
+
+// tlsConfig set by the flags, or nil if TLS is not asked for
+func (o options) tlsConfig() *transport.Config {
+	if o.tls == (transport.Config{}) {
+		return nil
+	}
+	return &o.tls
+}
+
 func main() {
 	// Parse command line arguments
-	serverAddr, localAddr, outboundAddr, logstate, err := parseArgs(os.Args[1:])
+	serverAddr, localAddr, outboundAddr, opts, err := parseArgs(os.Args[1:])
 	checkError(err)
 
 	// Create our logger
-	err = singletonlogger.NewSingletonLogger("app", logstate)
+	err = singletonlogger.NewSingletonLoggerWithConfig("app", opts.logstate, logger.ServiceConfig(opts.logdir))
+	checkError(err)
+	err = singletonlogger.SetFormat(opts.logformat)
 	checkError(err)
-	singletonlogger.Debug("[LIB/APP] starting application at " + localAddr + " with outbound address " + outboundAddr)
+	appLog.Debug("starting application at " + localAddr + " with outbound address " + outboundAddr)
 
 	// Create a new ConsensusLib client
-	client, err := consensuslib.NewClient(localAddr, outboundAddr, 1*time.Millisecond)
+	config := consensuslib.DefaultClientConfig()
+	config.HeartbeatRate = opts.heartbeat
+	config.AuditInterval = opts.audit
+	config.Node.DataDir = opts.datadir
+	config.TLS = opts.tlsConfig()
+	if opts.keyFile != "" {
+		config.ClusterKey, err = admission.LoadKey(opts.keyFile)
+		checkError(err)
+	}
+	// Export spans of writes, if asked to
+	if opts.tracing {
+		err = os.MkdirAll("traces", 0700)
+		checkError(err)
+		config.SpanFile = traceFile(outboundAddr, ".spans.json")
+	}
+	client, err := consensuslib.NewClientWithConfig(localAddr, outboundAddr, config)
 	checkError(err)
-	singletonlogger.Debug("[LIB/APP] created client at " + localAddr)
+	appLog.Debug("created client " + client.ID() + " at " + localAddr)
 
-	// Connect to the ConsensusLib server at serverAddr
-	err = client.Connect(serverAddr)
-	checkError(err)
-	singletonlogger.Debug("[LIB/APP] connected to server at " + serverAddr)
-	singletonlogger.Debug("[LIB/APP] serving cli")
+	// Record a trace of this node before it joins, if asked to
+	if opts.record {
+		err = os.MkdirAll("traces", 0700)
+		checkError(err)
+		err = client.RecordTrace(traceFile(outboundAddr, ".trace"))
+		checkError(err)
+	}
+
+	// Log paxos messages with vector clocks, if asked to
+	if opts.shiviz {
+		err = client.EnableVectorClock()
+		checkError(err)
+	}
+
+	// Detect failed nodes by gossip, if asked to
+	if opts.swim {
+		err = client.EnableMembership(membership.DefaultConfig())
+		checkError(err)
+	}
+
+	// Resume from the state saved before a restart, if asked to
+	if opts.resume {
+		err = client.Resume()
+		checkError(err)
+	}
+
+	// Serve status and metrics over HTTP, if asked to
+	if opts.http != "" {
+		httpAddr, err := client.ServeHTTP(opts.http)
+		checkError(err)
+		appLog.Info("serving status at http://" + httpAddr + "/status and metrics at http://" + httpAddr + "/metrics")
+	}
+
+	// Connect to the ConsensusLib server at serverAddr, or join the peers without one
+	if serverAddr == noServer {
+		err = client.Join(opts.peers...)
+		checkError(err)
+		appLog.Debugf("joined through peers %v", opts.peers)
+	} else {
+		err = client.Connect(strings.Split(serverAddr, ",")...)
+		checkError(err)
+		appLog.Debug("connected to servers at " + serverAddr)
+	}
+	appLog.Debug("serving cli")
 
 	// Serve the CLI interface to the Distributed Diary app
 	serveCli(client)
@@ -76,23 +219,24 @@ func main() {
 func serveCli(client *consensuslib.Client) {
 	for {
 		command := cli.Run()
-		singletonlogger.Debug(fmt.Sprintf("[app] received command %v", command))
+		appLog.Debugf("received command %v", command)
 		switch command.Command {
 		case cli.ALIVE:
 			isAlive, err := client.IsAlive()
-			checkError(err)
+			if err != nil {
+				singletonlogger.Error(err.Error())
+				break
+			}
 			singletonlogger.Info(fmt.Sprintf("Alive: %v", isAlive))
 		case cli.EXIT:
-			Exit()
+			Exit(client)
 		case cli.READ:
 			value, err := client.Read()
 			checkError(err)
 			singletonlogger.Info(fmt.Sprintf("Reading: \n%s", value))
 		case cli.WRITE:
-			if breaked && !written {
-				written = true
-			} else if breaked && written {
-				singletonlogger.Info("This client is at a breakpoint. Please 'continue' before writing again.")
+			if stage := client.Tracker().Paused(); stage != "" {
+				singletonlogger.Info(fmt.Sprintf("This client is at a breakpoint before %s. Please 'continue' before writing again.", stage))
 				break
 			}
 			value := ""
@@ -106,31 +250,15 @@ func serveCli(client *consensuslib.Client) {
 			}
 			go client.Write(value)
 		case cli.BREAK:
-			if breaked && !written {
-				singletonlogger.Info("This client is ready to hit a breakpoint. Please 'continue' before pausing again.")
-				break
-			} else if breaked && written {
-				singletonlogger.Info("This client is at a breakpoint. Please 'continue' before pausing again.")
+			bp, err := parseBreakpoint(*command.Data, client.Addr())
+			if err != nil {
+				singletonlogger.Error(err.Error())
 				break
 			}
-			breaked = true
-			data := *command.Data
-			breakState = data[0]
-			singletonlogger.Info("Breaking before next " + breakState)
-			switch breakState {
-			case cli.Prepare:
-				go paxostracker.BreakNextPrepare()
-			case cli.Propose:
-				go paxostracker.BreakNextPropose()
-			case cli.Learn:
-				go paxostracker.BreakNextLearn()
-			case cli.Idle:
-				go paxostracker.BreakNextIdle()
-			case cli.Custom:
-				go paxostracker.BreakNextCustom()
-			default:
-				singletonlogger.Error(fmt.Sprintf("Couldn't identify '%s'", breakState))
-				breaked = false
+			singletonlogger.Info(fmt.Sprintf("Breaking before next %v", bp))
+			err = client.SetBreakpoint(bp)
+			if err != nil {
+				singletonlogger.Error(err.Error())
 			}
 		case cli.KILL:
 			data := *command.Data
@@ -138,71 +266,113 @@ func serveCli(client *consensuslib.Client) {
 			singletonlogger.Info("Killing before next " + killState)
 			switch killState {
 			case cli.Prepare:
-				go paxostracker.KillNextPrepare()
+				go client.Tracker().KillNextPrepare()
 			case cli.Propose:
-				go paxostracker.KillNextPropose()
+				go client.Tracker().KillNextPropose()
 			case cli.Learn:
-				go paxostracker.KillNextLearn()
+				go client.Tracker().KillNextLearn()
 			case cli.Idle:
-				go paxostracker.KillNextIdle()
+				go client.Tracker().KillNextIdle()
 			case cli.Custom:
-				go paxostracker.KillNextCustom()
+				go client.Tracker().KillNextCustom()
 			default:
 				singletonlogger.Error(fmt.Sprintf("Couldn't identify '%s'", killState))
 			}
 		case cli.CONTINUE:
-			breaked = false
-			written = false
-			singletonlogger.Info("Continuing...")
-			go paxostracker.Continue()
+			target := parseTarget(*command.Data, client.Addr())
+			singletonlogger.Info("Continuing" + describeTarget(target) + "...")
+			go func() {
+				if err := client.Continue(target); err != nil {
+					singletonlogger.Error(err.Error())
+				}
+			}()
 		case cli.ROUNDS:
-			singletonlogger.Info(paxostracker.AsTable())
-		case cli.STEP:
-			if !breaked {
-				singletonlogger.Info("Unable to step: Not at a breakpoint!")
+			if len(*command.Data) == 0 {
+				singletonlogger.Info(client.Tracker().AsTable())
 				break
 			}
-			switch breakState {
-			case cli.Prepare:
-				singletonlogger.Info("Breaking before next Propose")
-				breakState = cli.Propose
-				go paxostracker.BreakNextPropose()
-				go paxostracker.Continue()
-			case cli.Propose:
-				singletonlogger.Info("Breaking before next Learn")
-				breakState = cli.Learn
-				go paxostracker.BreakNextLearn()
-				go paxostracker.Continue()
-			case cli.Learn:
-				singletonlogger.Info("Breaking before next Idle")
-				breakState = cli.Idle
-				go paxostracker.BreakNextIdle()
-				go paxostracker.Continue()
-			case cli.Idle:
-				singletonlogger.Info("Cannot step beyond Idle. Please 'continue'")
-			default:
-				singletonlogger.Error(fmt.Sprintf("Couldn't identify '%s'", breakState))
+			// the export is written as is, not logged, so it can be given to paxostimeline
+			if len(*command.Data) == 1 {
+				err := client.Tracker().WriteJSON(os.Stdout)
+				checkError(err)
+				break
+			}
+			if err := writeRounds(client.Tracker(), (*command.Data)[1]); err != nil {
+				singletonlogger.Error(err.Error())
+				break
+			}
+			singletonlogger.Info("Wrote the rounds to " + (*command.Data)[1])
+		case cli.AUDIT:
+			violations, err := client.Audit()
+			checkError(err)
+			if len(violations) == 0 {
+				singletonlogger.Info("No safety violations found")
+			}
+			for _, v := range violations {
+				singletonlogger.Info(v.String())
+			}
+		case cli.LOGLEVEL:
+			target, args := parseLogLevel(*command.Data)
+			levels, err := client.SetLogLevel(target, args)
+			if err != nil {
+				singletonlogger.Error(err.Error())
+				break
+			}
+			if len(levels) == 0 {
+				singletonlogger.Info("No log levels set")
+			}
+			for component, lvl := range levels {
+				if component == "" {
+					component = cli.Default
+				}
+				singletonlogger.Info(fmt.Sprintf("%s: %s", component, lvl))
 			}
+		case cli.STATUS:
+			target := ""
+			if len(*command.Data) == 2 {
+				target = (*command.Data)[1]
+			}
+			status, err := client.Status(target)
+			if err != nil {
+				singletonlogger.Error(err.Error())
+				break
+			}
+			out, err := json.MarshalIndent(status, "", "  ")
+			checkError(err)
+			singletonlogger.Info(string(out))
+		case cli.STEP:
+			target := parseTarget(*command.Data, client.Addr())
+			singletonlogger.Info("Stepping" + describeTarget(target) + "...")
+			go func() {
+				if err := client.Step(target); err != nil {
+					singletonlogger.Info("Unable to step: " + err.Error())
+				}
+			}()
 		default:
 		}
 	}
 }
 
-// Exit nicely from the program
-func Exit() {
-	// TODO: Delete temp folder
+// Exit nicely from the program, leaving the network so the other nodes do not wait for this one to time out
+func Exit(client *consensuslib.Client) {
+	// the data directory is kept, to --resume from it
 	singletonlogger.Info("Closing the Chamber of Secrets...")
+	if err := client.Leave(); err != nil {
+		singletonlogger.Error(err.Error())
+	}
 	singletonlogger.Info("Goodbye!")
 	os.Exit(0)
 }
 
-func parseArgs(args []string) (serverAddr string, localAddr string, outboundAddr string, logstate state.State, err error) {
+func parseArgs(args []string) (serverAddr string, localAddr string, outboundAddr string, opts options, err error) {
 	if !validArgs.MatchString(strings.Join(args, " ")) {
 		fmt.Println(usage)
 		os.Exit(1)
 	}
 	port := 0
 	isLocal := false
+	opts.logdir = logger.DefaultConfig().Dir
+	opts.heartbeat = 1 * time.Millisecond
 	for i, arg := range args {
 		// positional args
 		switch i {
@@ -211,7 +381,7 @@ func parseArgs(args []string) (serverAddr string, localAddr string, outboundAddr
 		case 1:
 			port, err = strconv.Atoi(args[i])
 			if err != nil {
-				return serverAddr, localAddr, outboundAddr, logstate, fmt.Errorf("error while converting port: %s", err)
+				return serverAddr, localAddr, outboundAddr, opts, fmt.Errorf("error while converting port: %s", err)
 			}
 		default:
 			// option flags
@@ -219,7 +389,65 @@ func parseArgs(args []string) (serverAddr string, localAddr string, outboundAddr
 			case localFlag:
 				isLocal = true
 			case debugFlag:
-				logstate = state.DEBUGGING
+				opts.logstate = state.DEBUGGING
+			case recordFlag:
+				opts.record = true
+			case shivizFlag:
+				opts.shiviz = true
+			case jsonLogsFlag:
+				opts.logformat = format.JSON
+			case tracingFlag:
+				opts.tracing = true
+			case swimFlag:
+				opts.swim = true
+			case resumeFlag:
+				opts.resume = true
+			default:
+				if strings.HasPrefix(arg, logDirFlag+"=") {
+					opts.logdir = strings.TrimPrefix(arg, logDirFlag+"=")
+				}
+				if strings.HasPrefix(arg, httpFlag+"=") {
+					opts.http = strings.TrimPrefix(arg, httpFlag+"=")
+				}
+				if strings.HasPrefix(arg, peersFlag+"=") {
+					opts.peers = strings.Split(strings.TrimPrefix(arg, peersFlag+"="), ",")
+				}
+				if strings.HasPrefix(arg, advertiseAddrFlag+"=") {
+					opts.advertise.Addr = strings.TrimPrefix(arg, advertiseAddrFlag+"=")
+				}
+				if strings.HasPrefix(arg, advertiseIfaceFlag+"=") {
+					opts.advertise.Interface = strings.TrimPrefix(arg, advertiseIfaceFlag+"=")
+				}
+				if strings.HasPrefix(arg, advertiseCIDRFlag+"=") {
+					opts.advertise.CIDR = strings.TrimPrefix(arg, advertiseCIDRFlag+"=")
+				}
+				if strings.HasPrefix(arg, tlsCAFlag+"=") {
+					opts.tls.CAFile = strings.TrimPrefix(arg, tlsCAFlag+"=")
+				}
+				if strings.HasPrefix(arg, tlsCertFlag+"=") {
+					opts.tls.CertFile = strings.TrimPrefix(arg, tlsCertFlag+"=")
+				}
+				if strings.HasPrefix(arg, tlsKeyFlag+"=") {
+					opts.tls.KeyFile = strings.TrimPrefix(arg, tlsKeyFlag+"=")
+				}
+				if strings.HasPrefix(arg, clusterKeyFlag+"=") {
+					opts.keyFile = strings.TrimPrefix(arg, clusterKeyFlag+"=")
+				}
+				if strings.HasPrefix(arg, dataDirFlag+"=") {
+					opts.datadir = strings.TrimPrefix(arg, dataDirFlag+"=")
+				}
+				if strings.HasPrefix(arg, heartbeatFlag+"=") {
+					opts.heartbeat, err = time.ParseDuration(strings.TrimPrefix(arg, heartbeatFlag+"="))
+					if err != nil {
+						return serverAddr, localAddr, outboundAddr, opts, fmt.Errorf("error while parsing the heartbeat: %s", err)
+					}
+				}
+				if strings.HasPrefix(arg, auditFlag+"=") {
+					opts.audit, err = time.ParseDuration(strings.TrimPrefix(arg, auditFlag+"="))
+					if err != nil {
+						return serverAddr, localAddr, outboundAddr, opts, fmt.Errorf("error while parsing the audit interval: %s", err)
+					}
+				}
 			}
 		}
 	}
@@ -228,15 +456,92 @@ func parseArgs(args []string) (serverAddr string, localAddr string, outboundAddr
 		localAddr = "127.0.0.1" + addrEnd
 		outboundAddr = "127.0.0.1" + addrEnd
 	} else {
-		outboundIP, err := networking.GetOutboundIP()
+		outboundAddr, err = networking.Advertise(opts.advertise, strconv.Itoa(port))
 		if err != nil {
-			return serverAddr, localAddr, outboundAddr, logstate, fmt.Errorf("error while fetching ip: %s", err)
+			return serverAddr, localAddr, outboundAddr, opts, fmt.Errorf("error while fetching ip: %s", err)
 		}
-		outboundAddr = outboundIP + addrEnd
 		localAddr = addrEnd
 
 	}
-	return serverAddr, localAddr, outboundAddr, logstate, nil
+	return serverAddr, localAddr, outboundAddr, opts, nil
+}
+
+// parseBreakpoint reads `STAGE [all | node ADDR] [round N] [value TEXT]`. Without all or node,
+// the breakpoint is only set on this client.
+func parseBreakpoint(data []string, self string) (bp paxostracker.Breakpoint, err error) {
+	bp = paxostracker.Breakpoint{Stage: paxostracker.Stage(data[0]), Node: self}
+	for i := 1; i < len(data); i++ {
+		switch data[i] {
+		case cli.All:
+			bp.Node = ""
+		case cli.Node:
+			i++
+			bp.Node = data[i]
+		case cli.Round:
+			i++
+			bp.MinRound, err = strconv.Atoi(data[i])
+			if err != nil {
+				return bp, fmt.Errorf("error while converting round: %s", err)
+			}
+		case cli.Value:
+			bp.Value = strings.Join(data[i+1:], " ")
+			return bp, nil
+		}
+	}
+	return bp, nil
+}
+
+// parseLogLevel reads `[COMPONENT LEVEL] [node ADDR]`, where no node is this client
+func parseLogLevel(data []string) (target string, args consensuslib.LogLevelArgs) {
+	if len(data) >= 2 && data[0] != cli.Node {
+		args.Component, args.Level = data[0], data[1]
+		if args.Component == cli.Default {
+			args.Component = ""
+		}
+		data = data[2:]
+	}
+	if len(data) == 2 && data[0] == cli.Node {
+		target = data[1]
+	}
+	return target, args
+}
+
+// parseTarget reads `[all | node ADDR]`, where all is the empty target and no target is this client
+func parseTarget(data []string, self string) (target string) {
+	if len(data) == 0 {
+		return self
+	}
+	if data[0] == cli.All {
+		return ""
+	}
+	return data[1]
+}
+
+func describeTarget(target string) string {
+	if target == "" {
+		return " on all nodes"
+	}
+	return " on " + target
+}
+
+// writeRounds exported from tracker as JSON to a new file at path
+func writeRounds(tracker *paxostracker.PaxosTracker, path string) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = tracker.WriteJSON(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// traceFile in traces/ for the node at addr, named after it and the time with extension ext.
+// Names keep clear of ':', which some filesystems do not allow.
+func traceFile(addr, ext string) string {
+	name := addr + "_" + time.Now().Format("2006-01-02_15-04-05")
+	return filepath.Join("traces", strings.Replace(name, ":", "_", -1)+ext)
 }
 
 func checkError(err error) {
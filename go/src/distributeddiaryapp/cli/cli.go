@@ -26,6 +26,20 @@ const (
 	CONTINUE = "continue"
 	STEP     = "step"
 	KILL     = "kill"
+	AUDIT    = "audit"
+	LOGLEVEL = "loglevel"
+	STATUS   = "status"
+)
+
+// Options
+const (
+	JSON  = "--json"
+	All   = "all"
+	Node  = "node"
+	Round = "round"
+	Value = "value"
+	// Default is the component standing for every component without its own log level
+	Default = "default"
 )
 
 // Breaks
@@ -37,7 +51,7 @@ const (
 	Custom  = "custom"
 )
 
-var validCommand = regexp.MustCompile("(alive|read|write ([0-9a-zA-Z ]*)?|help|exit|rounds|(break|kill) (prepare|propose|learn|idle|custom)|continue|step)")
+var validCommand = regexp.MustCompile("(alive|read|write ([0-9a-zA-Z ]*)?|help|exit|rounds( --json( \\S+)?)?|break (prepare|propose|learn|idle|custom)( all| node [0-9.]+:[0-9]+)?( round [0-9]+)?( value [0-9a-zA-Z ]+)?|kill (prepare|propose|learn|idle|custom)|(continue|step)( all| node [0-9.]+:[0-9]+)?|audit|loglevel( [a-z]+ (debug|info|warning|error|fatal|reset))?( node [0-9.]+:[0-9]+)?|status( node [0-9.]+:[0-9]+)?)")
 
 var helpString = `
 ===========================================
@@ -47,7 +61,7 @@ Valid Commands:
    
 alive
 -----
-- Report if this client is connected to the server
+- Report if this client is connected to the server. Without a server, reports an error
 
 exit
 ----
@@ -65,25 +79,48 @@ write [a-zA-Z0-9 ]?
 -------------------
 - write to the log a string consisiting of one or more lower and upper case letters, 0-9, and spaces.
 
-rounds
--------
+rounds [--json [FILE]]
+----------------------
 - produce the round results from the paxostracker
+- with --json, print every round, transition and timestamp as JSON, for use with paxostimeline
+- FILE: write the JSON to FILE instead
 
-break [prepare|propose|learn|idle|custom]
-----------------------------------
+break [prepare|propose|learn|idle|custom] [all | node IP:PORT] [round N] [value TEXT]
+-------------------------------------------------------------------------------------
 - break the client's execution at the selected stage for the next round until 'continue' is called
+- all: break on every node; node IP:PORT: break on that node instead of this one
+- round N: only break in a round numbered N or higher
+- value TEXT: only break in a round proposing exactly TEXT. Must come last
 
 kill [prepare|propose|learn|idle|custom]
 ----------------------------------
 - kill the client's execution at the selected stage. Exits roughly with os.Exit(1).
 
-continue
---------
-- continue the round
+continue [all | node IP:PORT]
+-----------------------------
+- continue the round on this node, on every node, or on the given node
+- on a node that is not at a breakpoint, clears its breakpoints instead
 
-step
-----
-- step one stage further
+step [all | node IP:PORT]
+-------------------------
+- step one stage further on this node, on every node at a breakpoint, or on the given node
+
+audit
+-----
+- check every reachable node's log for Paxos safety violations
+
+loglevel [COMPONENT debug|info|warning|error|fatal|reset] [node IP:PORT]
+-------------------------------------------------------------------------
+- set the log level of a component such as paxosnode, acceptor, proposer, learner, client or paxostracker
+- lines below the level are dropped, and the rest are printed without needing --debug
+- default: set the level of every component without its own. reset: go back to the --debug setting
+- node IP:PORT: set the level on that node instead of this one
+- without a component, list the levels set
+
+status [node IP:PORT]
+---------------------
+- print the status of this node, or of the given node, as JSON: its round, message ID, last promised
+  and accepted messages, log length, neighbours and failed neighbours
 
 Created for:
 CPSC 416 Distributed Systems, in the 2017W2 Session at the University of British Columbia (UBC)
@@ -110,22 +147,34 @@ func Run() (cmd Command) {
 			case 'k':
 				when := strings.Split(command[0], " ")[1:]
 				return Command{KILL, &when}
+			case 'c':
+				target := strings.Split(command[0], " ")[1:]
+				return Command{CONTINUE, &target}
+			case 's':
+				target := strings.Split(command[0], " ")[1:]
+				if strings.HasPrefix(command[0], STATUS) {
+					return Command{STATUS, &target}
+				}
+				return Command{STEP, &target}
+			case 'l':
+				args := strings.Split(command[0], " ")[1:]
+				return Command{LOGLEVEL, &args}
+			case 'r':
+				if strings.HasPrefix(command[0], ROUNDS) {
+					options := strings.Split(command[0], " ")[1:]
+					return Command{ROUNDS, &options}
+				}
+				return Command{READ, nil}
 			default:
 				switch command[0] {
 				case ALIVE:
 					return Command{ALIVE, nil}
-				case READ:
-					return Command{READ, nil}
 				case EXIT:
 					return Command{EXIT, nil}
 				case HELP:
 					fmt.Println(helpString)
-				case ROUNDS:
-					return Command{ROUNDS, nil}
-				case CONTINUE:
-					return Command{CONTINUE, nil}
-				case STEP:
-					return Command{STEP, nil}
+				case AUDIT:
+					return Command{AUDIT, nil}
 				default:
 					fmt.Println("Command not understood.")
 					fmt.Println("Type 'help' for command information.")
@@ -2,19 +2,205 @@ package networking
 
 import (
 	"fmt"
-	"os/exec"
+	"net"
+	"os"
+	"strconv"
 	"strings"
 )
 
-// GetOutboundIP Returns a machine's public (outbound) Azure IP address e.g. "270.0.21.1"
-// We make the assumption that all production apps will be run on an Azure VM.
+// Resolver finds the IP address other machines reach this one on
+type Resolver interface {
+	Resolve() (ip string, err error)
+}
+
+// ResolverFunc lets a function be used as a Resolver
+type ResolverFunc func() (ip string, err error)
+
+// Resolve by calling the function
+func (f ResolverFunc) Resolve() (ip string, err error) {
+	return f()
+}
+
+// EnvVars the default resolver reads an address from, in order. POD_IP is set by Kubernetes
+// when a pod's spec passes it down.
+var EnvVars = []string{"DIARY_ADVERTISE_ADDR", "POD_IP"}
+
+// routeTarget is dialled to find the address of the interface the default route goes through.
+// Dialling UDP sends no packets, so the target does not have to be reachable.
+const routeTarget = "192.0.2.1:9"
+
+// Options choosing how the address is resolved. The first one set is used, and with none set, DefaultResolver.
+type Options struct {
+	Addr      string // an explicit IP address, or IP:PORT to also advertise another port than the one listened on
+	Interface string // the name of the network interface whose address is used, such as eth0
+	CIDR      string // a network such as 10.0.0.0/8, whose first address on any interface is used
+}
+
+// Resolver for the options
+func (o Options) Resolver() Resolver {
+	switch {
+	case o.Addr != "":
+		host := o.Addr
+		if h, _, err := net.SplitHostPort(o.Addr); err == nil {
+			host = h
+		}
+		return Static(host)
+	case o.Interface != "":
+		return Interface(o.Interface)
+	case o.CIDR != "":
+		return CIDR(o.CIDR)
+	}
+	return DefaultResolver()
+}
+
+// Advertise the address other machines reach this one on at port, as resolved with the options
+func Advertise(o Options, port string) (addr string, err error) {
+	if host, p, err := net.SplitHostPort(o.Addr); err == nil {
+		// the port is mapped, such as by a container runtime
+		ip, err := Static(host).Resolve()
+		if err != nil {
+			return "", err
+		}
+		if n, err := strconv.Atoi(p); err != nil || n < 1 || n > 65535 {
+			return "", fmt.Errorf("%q is not a port", p)
+		}
+		return net.JoinHostPort(ip, p), nil
+	}
+	ip, err := o.Resolver().Resolve()
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(ip, port), nil
+}
+
+// DefaultResolver reads the address from EnvVars, or else takes the address of the interface the default route
+// goes through, or else the first address of any interface that is up and not a loopback.
+// None of them need internet access.
+func DefaultResolver() Resolver {
+	return Chain(Env(EnvVars...), Route(routeTarget), FirstInterface())
+}
+
+// GetOutboundIP Returns the IP address other machines reach this one on, e.g. "10.0.21.1", with the default resolver
 func GetOutboundIP() (ipString string, err error) {
-	out, err := exec.Command("curl", "-s", "http://checkip.amazonaws.com").Output()
+	return DefaultResolver().Resolve()
+}
 
+// Static resolves to ip
+func Static(ip string) Resolver {
+	return ResolverFunc(func() (string, error) {
+		if net.ParseIP(ip) == nil {
+			return "", fmt.Errorf("%q is not an IP address", ip)
+		}
+		return ip, nil
+	})
+}
+
+// Env resolves to the address in the first of the environment variables that is set
+func Env(names ...string) Resolver {
+	return ResolverFunc(func() (string, error) {
+		for _, name := range names {
+			if value := strings.TrimSpace(os.Getenv(name)); value != "" {
+				return Static(value).Resolve()
+			}
+		}
+		return "", fmt.Errorf("none of %v is set", names)
+	})
+}
+
+// Route resolves to the local address of the route to target, an IP:PORT
+func Route(target string) Resolver {
+	return ResolverFunc(func() (string, error) {
+		conn, err := net.Dial("udp", target)
+		if err != nil {
+			return "", fmt.Errorf("no route to %s: %s", target, err)
+		}
+		defer conn.Close()
+		return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+	})
+}
+
+// Interface resolves to the first IPv4 address of the interface called name, or its first IPv6 one if it has none
+func Interface(name string) Resolver {
+	return ResolverFunc(func() (string, error) {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			return "", fmt.Errorf("no interface %s: %s", name, err)
+		}
+		ip, ok := pickAddr(iface, func(net.IP) bool { return true })
+		if !ok {
+			return "", fmt.Errorf("interface %s has no address", name)
+		}
+		return ip, nil
+	})
+}
+
+// CIDR resolves to the first address of any interface that is in the network cidr
+func CIDR(cidr string) Resolver {
+	return ResolverFunc(func() (string, error) {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return "", err
+		}
+		return firstAddr(network.Contains, false)
+	})
+}
+
+// FirstInterface resolves to the first address of any interface that is up and not a loopback
+func FirstInterface() Resolver {
+	return ResolverFunc(func() (string, error) {
+		return firstAddr(func(ip net.IP) bool { return !ip.IsLinkLocalUnicast() }, true)
+	})
+}
+
+// Chain resolves with each resolver in turn, until one of them succeeds
+func Chain(resolvers ...Resolver) Resolver {
+	return ResolverFunc(func() (string, error) {
+		errs := make([]string, 0, len(resolvers))
+		for _, r := range resolvers {
+			ip, err := r.Resolve()
+			if err == nil {
+				return ip, nil
+			}
+			errs = append(errs, err.Error())
+		}
+		return "", fmt.Errorf("unable to resolve an address: %s", strings.Join(errs, "; "))
+	})
+}
+
+// firstAddr of any interface that is up and matches, skipping loopbacks if asked to
+func firstAddr(matches func(net.IP) bool, skipLoopback bool) (string, error) {
+	ifaces, err := net.Interfaces()
 	if err != nil {
-		fmt.Println(err)
+		return "", err
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || skipLoopback && iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if ip, ok := pickAddr(&iface, matches); ok {
+			return ip, nil
+		}
 	}
+	return "", fmt.Errorf("no interface has a matching address")
+}
 
-	result := fmt.Sprintf("%s", out)
-	return strings.TrimSpace(result), nil
+// pickAddr of iface that matches, preferring IPv4
+func pickAddr(iface *net.Interface, matches func(net.IP) bool) (ip string, ok bool) {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", false
+	}
+	for _, a := range addrs {
+		ipNet, isNet := a.(*net.IPNet)
+		if !isNet || !matches(ipNet.IP) {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP.String(), true
+		}
+		if !ok {
+			ip, ok = ipNet.IP.String(), true
+		}
+	}
+	return ip, ok
 }
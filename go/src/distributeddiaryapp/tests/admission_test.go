@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"consensuslib"
+	"consensuslib/admission"
+	"consensuslib/message"
+	"consensuslib/transport"
+	"distributeddiaryapp/tests/util"
+	"testing"
+	"time"
+)
+
+func TestClusterKey(t *testing.T) {
+	serverAddr := "127.0.0.1:12370"
+	key := []byte("cluster key")
+	serverConfig := consensuslib.DefaultServerConfig()
+	serverConfig.ClusterKey = key
+	server, err := consensuslib.NewServerWithConfig(serverAddr, nil, serverConfig)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestClusterKey\" produced err: %v", err)
+	}
+	go server.Serve()
+
+	var tests = []struct {
+		Name  string
+		Key   []byte
+		Joins bool
+	}{
+		{"Member", key, true},
+		{"OtherMember", key, true},
+		{"NoKey", nil, false},
+		{"OtherKey", []byte("another key"), false},
+	}
+	var joined []*consensuslib.Client
+	for _, test := range tests {
+		config := util.ClientConfig()
+		config.HeartbeatRate = 10 * time.Millisecond
+		config.ClusterKey = test.Key
+		client, err := consensuslib.NewClientWithConfig("127.0.0.1:0", "", config)
+		if err != nil {
+			t.Fatalf("Bad Exit: %s: produced err: %v", test.Name, err)
+		}
+		err = client.Connect(serverAddr)
+		if (err == nil) != test.Joins {
+			t.Errorf("Bad Exit: %s: expected joining to be %v, got err: %v", test.Name, test.Joins, err)
+		}
+		if err == nil {
+			joined = append(joined, client)
+		}
+	}
+	if len(joined) != 2 {
+		t.Fatalf("Bad Exit: expected 2 nodes to join, got %d", len(joined))
+	}
+	if status, _ := joined[1].Status(""); len(status.Neighbours) != 1 {
+		t.Errorf("Bad Exit: expected 1 neighbour, got %v", status.Neighbours)
+	}
+
+	// a member of the cluster that is not a server of the group cannot replicate users onto the server
+	member, err := transport.New(nil, admission.New(key))
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestClusterKey\" produced err: %v", err)
+	}
+	var replicated bool
+	if err = member.As(joined[0].Addr()).Call(serverAddr, time.Second, "Server.Replicate", []consensuslib.Member{}, &replicated); err == nil {
+		t.Errorf("Bad Exit: expected a node to be refused replicating")
+	}
+	inspector, err := consensuslib.NewInspectorWithTransport(member, serverAddr)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestClusterKey\" produced err: %v", err)
+	}
+	if members, err := inspector.Members(); err != nil || len(members) != 2 {
+		t.Errorf("Bad Exit: expected 2 members, got %v, err: %v", members, err)
+	}
+
+	// a stray node with another key cannot reach a member directly, to become its neighbour or send it paxos messages
+	stray, err := transport.New(nil, admission.New([]byte("another key")))
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestClusterKey\" produced err: %v", err)
+	}
+	stray = stray.As("127.0.0.1:12371")
+	connected := false
+	err = stray.Call(joined[0].Addr(), time.Second, "PaxosNodeRPCWrapper.ConnectRemoteNeighbour", "127.0.0.1:12371", &connected)
+	if err == nil || connected {
+		t.Errorf("Bad Exit: expected an unsigned neighbour to be refused")
+	}
+	var promise message.Message
+	prepare := message.Message{Type: message.PREPARE, ID: 1 << 40, FromProposerID: "stray"}
+	if err = stray.Call(joined[0].Addr(), time.Second, "PaxosNodeRPCWrapper.ProcessPrepareRequest", prepare, &promise); err == nil {
+		t.Errorf("Bad Exit: expected an unsigned prepare request to be refused")
+	}
+	var levels map[string]string
+	setLevel := consensuslib.LogLevelArgs{Component: "acceptor", Level: "debug"}
+	if err = stray.Call(joined[0].Addr(), time.Second, "Admin.SetLogLevel", setLevel, &levels); err == nil {
+		t.Errorf("Bad Exit: expected an unsigned caller not to set the log level")
+	}
+	// a node holding the key that never joined cannot send paxos messages or change a member's state either
+	outsider := member.As("127.0.0.1:12385")
+	if err = outsider.Call(joined[0].Addr(), time.Second, "PaxosNodeRPCWrapper.ProcessPrepareRequest", prepare, &promise); err == nil {
+		t.Errorf("Bad Exit: expected a prepare request from a node that is not a member to be refused")
+	}
+	if err = outsider.Call(joined[0].Addr(), time.Second, "Admin.SetLogLevel", setLevel, &levels); err == nil {
+		t.Errorf("Bad Exit: expected a node that is not a member not to set the log level")
+	}
+	var members []string
+	if err = outsider.Call(joined[0].Addr(), time.Second, "Admin.Members", "placeholder", &members); err != nil {
+		t.Errorf("Bad Exit: expected a node that is not a member to list the members to join through, got err: %v", err)
+	}
+	// members of the cluster still can
+	if _, err = joined[1].SetLogLevel(joined[0].Addr(), consensuslib.LogLevelArgs{}); err != nil {
+		t.Errorf("Bad Exit: expected a member to read the log levels, got err: %v", err)
+	}
+	if status, _ := joined[0].Status(""); len(status.Neighbours) != 1 || status.LastPromised.ID == prepare.ID {
+		t.Errorf("Bad Exit: expected the stray not to take part, got neighbours %v and promise %v", status.Neighbours, status.LastPromised.ID)
+	}
+}
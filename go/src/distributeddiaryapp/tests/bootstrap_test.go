@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"consensuslib"
+	"distributeddiaryapp/tests/util"
+	"testing"
+)
+
+func TestJoinWithoutServer(t *testing.T) {
+	addrs := []string{"127.0.0.1:12356", "127.0.0.1:12357", "127.0.0.1:12358", "127.0.0.1:12359"}
+	var tests = []struct {
+		Peers      []string
+		Neighbours int
+	}{
+		// the first node finds no peer up, and starts the network
+		{addrs[1:3], 0},
+		// a static list of peers, of which only the first is up
+		{[]string{addrs[0], addrs[2]}, 1},
+		// a single seed, which knows of the other member
+		{addrs[1:2], 2},
+		{addrs[:1], 3},
+	}
+	clients := make([]*consensuslib.Client, len(addrs))
+	var err error
+	for i, test := range tests {
+		clients[i], err = consensuslib.NewClientWithConfig(addrs[i], "", util.ClientConfig())
+		if err != nil {
+			t.Fatalf("Bad Exit: \"TestJoinWithoutServer(%v)\" produced err: %v", test, err)
+		}
+		if err = clients[i].Join(test.Peers...); err != nil {
+			t.Fatalf("Bad Exit: \"TestJoinWithoutServer(%v)\" produced err: %v", test, err)
+		}
+		status, _ := clients[i].Status("")
+		if len(status.Neighbours) != test.Neighbours {
+			t.Errorf("Bad Exit: %s joined %v with neighbours %v, expected %d", addrs[i], test.Peers, status.Neighbours, test.Neighbours)
+		}
+		if i == 1 {
+			if err = clients[0].Write("before the seeds"); err != nil {
+				t.Errorf("Bad Exit: \"TestJoinWithoutServer\" produced err: %v", err)
+			}
+		}
+	}
+	for i, client := range clients {
+		status, _ := client.Status("")
+		if len(status.Neighbours) != 3 || status.LogLength != 1 {
+			t.Errorf("Bad Exit: %s has neighbours %v and %d values, expected 3 neighbours and 1 value", addrs[i], status.Neighbours, status.LogLength)
+		}
+	}
+	if _, err = clients[0].IsAlive(); err == nil {
+		t.Errorf("Bad Exit: expected a client without a server to not report the server alive")
+	}
+}
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"paxostracker"
+	"paxostracker/errors"
+	"testing"
+	"time"
+)
+
+// waitPaused waits until the tracker is paused at stage
+func waitPaused(t *testing.T, tracker *paxostracker.PaxosTracker, stage paxostracker.Stage) {
+	deadline := time.Now().Add(2 * time.Second)
+	for tracker.Paused() != stage {
+		if time.Now().After(deadline) {
+			t.Fatalf("Bad Exit: tracker paused at %q, expected %q", tracker.Paused(), stage)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestContinueNotPaused(t *testing.T) {
+	tracker := paxostracker.NewPaxosTracker("127.0.0.1:12379")
+	tracker.AddBreakpoint(paxostracker.Breakpoint{Stage: paxostracker.PREPARE})
+	if _, ok := tracker.Continue().(errors.NotAtBreakpoint); !ok {
+		t.Errorf("Bad Exit: \"TestContinueNotPaused\" continued a node that was not paused")
+	}
+	if len(tracker.Breakpoints()) != 1 {
+		t.Errorf("Bad Exit: \"TestContinueNotPaused\" left breakpoints %v, expected the one armed", tracker.Breakpoints())
+	}
+}
+
+func TestNilTrackerBreakpoints(t *testing.T) {
+	var tracker *paxostracker.PaxosTracker
+	tracker.AddBreakpoint(paxostracker.Breakpoint{Stage: paxostracker.PREPARE})
+	if len(tracker.Breakpoints()) != 0 || tracker.Paused() != "" {
+		t.Errorf("Bad Exit: \"TestNilTrackerBreakpoints\" armed %v and paused at %q, expected neither", tracker.Breakpoints(), tracker.Paused())
+	}
+	if _, ok := tracker.Continue().(errors.NotAtBreakpoint); !ok {
+		t.Errorf("Bad Exit: \"TestNilTrackerBreakpoints\" continued a nil tracker")
+	}
+	if _, ok := tracker.Step().(errors.NotAtBreakpoint); !ok {
+		t.Errorf("Bad Exit: \"TestNilTrackerBreakpoints\" stepped a nil tracker")
+	}
+}
+
+func TestConcurrentContinue(t *testing.T) {
+	tracker := paxostracker.NewPaxosTracker("127.0.0.1:12379")
+	tracker.AddBreakpoint(paxostracker.Breakpoint{Stage: paxostracker.PREPARE})
+	done := make(chan struct{})
+	go func() {
+		tracker.Prepare("n0", "a", 0)
+		close(done)
+	}()
+	waitPaused(t, tracker, paxostracker.PREPARE)
+
+	// only one of two continues racing for the same breakpoint resumes it, and neither blocks
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			errs <- tracker.Continue()
+		}()
+	}
+	failed := 0
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if err != nil {
+				failed++
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Bad Exit: \"TestConcurrentContinue\" blocked in continue")
+		}
+	}
+	if failed != 1 {
+		t.Errorf("Bad Exit: \"TestConcurrentContinue\" had %d continues fail, expected 1", failed)
+	}
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Bad Exit: \"TestConcurrentContinue\" never resumed the round")
+	}
+}
+
+func TestStep(t *testing.T) {
+	tracker := paxostracker.NewPaxosTracker("127.0.0.1:12379")
+	if _, ok := tracker.Step().(errors.NotAtBreakpoint); !ok {
+		t.Errorf("Bad Exit: \"TestStep\" stepped a node that was not paused")
+	}
+	tracker.AddBreakpoint(paxostracker.Breakpoint{Stage: paxostracker.PREPARE})
+	done := make(chan struct{})
+	go func() {
+		tracker.Prepare("n0", "a", 0)
+		tracker.Propose(1, "a", 0)
+		close(done)
+	}()
+	waitPaused(t, tracker, paxostracker.PREPARE)
+	if err := tracker.Step(); err != nil {
+		t.Fatalf("Bad Exit: \"TestStep\" produced err: %v", err)
+	}
+	waitPaused(t, tracker, paxostracker.PROPOSE)
+	if err := tracker.Continue(); err != nil {
+		t.Fatalf("Bad Exit: \"TestStep\" produced err: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Bad Exit: \"TestStep\" never resumed the round")
+	}
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"consensuslib"
+	"consensuslib/phiaccrual"
+	"distributeddiaryapp/tests/util"
+	"testing"
+	"time"
+)
+
+func TestPhiAccrualDetector(t *testing.T) {
+	start := time.Now()
+	config := phiaccrual.DefaultConfig()
+	var tests = []struct {
+		Name      string
+		Interval  time.Duration // between the heartbeats the detector learns from
+		Silence   time.Duration // since the last heartbeat
+		Available bool
+	}{
+		{"OnTime", 100 * time.Millisecond, 100 * time.Millisecond, true},
+		{"LateButSlowNetwork", time.Second, 1500 * time.Millisecond, true},
+		{"LongSilenceFastNetwork", 100 * time.Millisecond, time.Second, false},
+		{"LongSilenceSlowNetwork", time.Second, 5 * time.Second, false},
+	}
+	for _, test := range tests {
+		detector := phiaccrual.New(config, start)
+		now := start
+		for i := 0; i < 100; i++ {
+			now = now.Add(test.Interval)
+			detector.Heartbeat(now)
+		}
+		if available := detector.Available(now.Add(test.Silence)); available != test.Available {
+			t.Errorf("Bad Exit: %s: expected available to be %v, got %v with phi %v",
+				test.Name, test.Available, available, detector.Phi(now.Add(test.Silence)))
+		}
+	}
+}
+
+func TestSlowHeartbeats(t *testing.T) {
+	detector := phiaccrual.DefaultConfig()
+	var tests = []struct {
+		Name     string
+		Addr     string
+		Detector *phiaccrual.Config
+		Kept     bool
+	}{
+		{"FixedTimeout", "127.0.0.1:12363", nil, false},
+		{"PhiAccrual", "127.0.0.1:12364", &detector, true},
+	}
+	for _, test := range tests {
+		server, err := consensuslib.NewServerWithConfig(test.Addr, nil, consensuslib.ServerConfig{
+			HeartbeatTimeout: 100 * time.Millisecond,
+			Detector:         test.Detector,
+		})
+		if err != nil {
+			t.Fatalf("Bad Exit: \"TestSlowHeartbeats\" produced err: %v", err)
+		}
+		go server.Serve()
+		defer server.Close()
+		// heartbeats take longer than the server's timeout to arrive
+		config := util.ClientConfig()
+		config.HeartbeatRate = 300 * time.Millisecond
+		client, err := consensuslib.NewClientWithConfig("127.0.0.1:0", "", config)
+		if err != nil {
+			t.Fatalf("Bad Exit: \"TestSlowHeartbeats\" produced err: %v", err)
+		}
+		if err = client.Connect(test.Addr); err != nil {
+			t.Fatalf("Bad Exit: \"TestSlowHeartbeats\" produced err: %v", err)
+		}
+		time.Sleep(time.Second)
+		inspector, err := consensuslib.NewInspector(test.Addr)
+		if err != nil {
+			t.Fatalf("Bad Exit: \"TestSlowHeartbeats\" produced err: %v", err)
+		}
+		members, err := inspector.Members()
+		if err != nil || (len(members) == 1) != test.Kept {
+			t.Errorf("Bad Exit: %s: expected the client to be kept: %v, got members %v, err: %v", test.Name, test.Kept, members, err)
+		}
+	}
+}
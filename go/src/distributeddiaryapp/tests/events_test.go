@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"consensuslib"
+	"distributeddiaryapp/tests/util"
+	"net/rpc"
+	"testing"
+	"time"
+)
+
+func TestMembershipEvents(t *testing.T) {
+	serverAddr := "127.0.0.1:12360"
+	err := util.SetupServer(serverAddr)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestMembershipEvents\" produced err: %v", err)
+	}
+	conn, err := rpc.Dial("tcp", serverAddr)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestMembershipEvents\" produced err: %v", err)
+	}
+	defer conn.Close()
+	var events consensuslib.Events
+	if err = conn.Call("Server.Subscribe", consensuslib.SubscribeArgs{}, &events); err != nil || !events.Reset {
+		t.Fatalf("Bad Exit: expected a first subscription to reset, got %v, err: %v", events, err)
+	}
+	after := events.Next
+
+	clients := make([]*consensuslib.Client, 3)
+	for i := range clients {
+		clients[i], err = util.SetupClient(serverAddr, "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Bad Exit: \"TestMembershipEvents\" produced err: %v", err)
+		}
+	}
+	removed := clients[2].Addr()
+	inspector, err := consensuslib.NewInspector(serverAddr)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestMembershipEvents\" produced err: %v", err)
+	}
+	// only the server is told, so the other clients learn of it from the event
+	var ok bool
+	if err = conn.Call("Server.Remove", removed, &ok); err != nil {
+		t.Fatalf("Bad Exit: \"TestMembershipEvents\" produced err: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	var expected = []struct {
+		Type consensuslib.EventType
+		Addr string
+	}{
+		{consensuslib.JOIN, clients[0].Addr()},
+		{consensuslib.JOIN, clients[1].Addr()},
+		{consensuslib.JOIN, clients[2].Addr()},
+		{consensuslib.LEAVE, removed},
+	}
+	if err = conn.Call("Server.Subscribe", consensuslib.SubscribeArgs{After: after, Following: true}, &events); err != nil {
+		t.Fatalf("Bad Exit: \"TestMembershipEvents\" produced err: %v", err)
+	}
+	if len(events.Events) != len(expected) {
+		t.Fatalf("Bad Exit: expected %d events, got %v", len(expected), events.Events)
+	}
+	for i, e := range expected {
+		if events.Events[i].Type != e.Type || events.Events[i].Addr != e.Addr {
+			t.Errorf("Bad Exit: expected event %d to be %s %s, got %v", i, e.Type, e.Addr, events.Events[i])
+		}
+	}
+	for _, client := range clients[:2] {
+		status, err := inspector.Status(client.Addr())
+		if err != nil || len(status.Neighbours) != 1 {
+			t.Errorf("Bad Exit: expected %s to drop %s, has neighbours %v, err: %v", client.Addr(), removed, status.Neighbours, err)
+		}
+	}
+}
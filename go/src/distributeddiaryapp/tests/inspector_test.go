@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"consensuslib"
+	"consensuslib/safety"
+	"distributeddiaryapp/tests/util"
+	"testing"
+	"time"
+)
+
+func TestInspector(t *testing.T) {
+	serverAddr := "127.0.0.1:12353"
+	err := util.SetupServer(serverAddr)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestInspector\" produced err: %v", err)
+	}
+	clients := make([]*consensuslib.Client, 3)
+	for i := range clients {
+		clients[i], err = util.SetupClient(serverAddr, "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Bad Exit: \"TestInspector\" produced err: %v", err)
+		}
+	}
+	a, dead := clients[0], clients[2]
+	a.Write("inspected")
+	time.Sleep(100 * time.Millisecond)
+
+	inspector, err := consensuslib.NewInspector(serverAddr)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestInspector\" produced err: %v", err)
+	}
+
+	members, err := inspector.Members()
+	if err != nil || len(members) != 3 {
+		t.Errorf("Bad Exit: expected 3 members, got %v, err: %v", members, err)
+	}
+	addrs, err := inspector.Addrs(nil)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestInspector\" produced err: %v", err)
+	}
+	snapshot, err := inspector.Snapshot(addrs)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestInspector\" produced err: %v", err)
+	}
+	if differences := safety.Diff(snapshot); len(differences) != 0 {
+		t.Errorf("Bad Exit: expected identical logs, got %v", differences)
+	}
+	learned, err := inspector.CatchUp(a.Addr())
+	if err != nil || learned != 0 {
+		t.Errorf("Bad Exit: expected a caught up node to learn nothing, learned %d, err: %v", learned, err)
+	}
+
+	unreachable, err := inspector.Remove(dead.Addr())
+	if err != nil || len(unreachable) != 0 {
+		t.Fatalf("Bad Exit: \"TestInspector\" unable to remove %s: %v, unreachable: %v", dead.Addr(), err, unreachable)
+	}
+	members, _ = inspector.Members()
+	for _, m := range members {
+		if m.Address == dead.Addr() {
+			t.Errorf("Bad Exit: %s is still a member after being removed", dead.Addr())
+		}
+	}
+	status, err := inspector.Status(a.Addr())
+	if err != nil || len(status.Neighbours) != 1 {
+		t.Errorf("Bad Exit: expected %s to have 1 neighbour left, got %v, err: %v", a.Addr(), status.Neighbours, err)
+	}
+
+	// a lagging log differs from the others where it has yet to learn
+	snapshot.Logs[dead.Addr()] = nil
+	if differences := safety.Diff(snapshot); len(differences) != 1 || differences[0].Index != 0 {
+		t.Errorf("Bad Exit: expected the logs to differ at index 0, got %v", differences)
+	}
+}
@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"consensuslib"
+	"distributeddiaryapp/tests/util"
+	"net/rpc"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLeaveAndRejoin(t *testing.T) {
+	serverAddr := "127.0.0.1:12361"
+	config := util.ClientConfig()
+	err := util.SetupServer(serverAddr)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestLeaveAndRejoin\" produced err: %v", err)
+	}
+	stayer, err := util.SetupClient(serverAddr, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestLeaveAndRejoin\" produced err: %v", err)
+	}
+	leaver, err := consensuslib.NewClientWithConfig("127.0.0.1:12362", "", config)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestLeaveAndRejoin\" produced err: %v", err)
+	}
+	if err = leaver.Connect(serverAddr); err != nil {
+		t.Fatalf("Bad Exit: \"TestLeaveAndRejoin\" produced err: %v", err)
+	}
+	if err = leaver.Write("before"); err != nil {
+		t.Fatalf("Bad Exit: \"TestLeaveAndRejoin\" produced err: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if err = leaver.Leave(); err != nil {
+		t.Fatalf("Bad Exit: \"TestLeaveAndRejoin\" produced err: %v", err)
+	}
+	if err = leaver.Write("after leaving"); err == nil {
+		t.Errorf("Bad Exit: expected writing after leaving to fail")
+	}
+	// the server and the neighbour are told, rather than waiting for the leaver to time out
+	inspector, err := consensuslib.NewInspector(serverAddr)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestLeaveAndRejoin\" produced err: %v", err)
+	}
+	members, err := inspector.Members()
+	if err != nil || len(members) != 1 || members[0].Address != stayer.Addr() {
+		t.Errorf("Bad Exit: expected only %s to be a member, got %v, err: %v", stayer.Addr(), members, err)
+	}
+	status, _ := stayer.Status("")
+	if len(status.Neighbours) != 0 {
+		t.Errorf("Bad Exit: expected no neighbours left, got %v", status.Neighbours)
+	}
+
+	// restarted on another address, the node keeps its ID and resumes from its backup
+	rejoined, err := consensuslib.NewClientWithConfig("127.0.0.1:12365", "", config)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestLeaveAndRejoin\" produced err: %v", err)
+	}
+	if rejoined.ID() != leaver.ID() {
+		t.Errorf("Bad Exit: expected to rejoin as %s, got %s", leaver.ID(), rejoined.ID())
+	}
+	if err = rejoined.Resume(); err != nil {
+		t.Fatalf("Bad Exit: \"TestLeaveAndRejoin\" produced err: %v", err)
+	}
+	if status, _ = rejoined.Status(""); status.LogLength != 1 {
+		t.Errorf("Bad Exit: expected to resume with 1 value, got %d", status.LogLength)
+	}
+	if err = rejoined.Connect(serverAddr); err != nil {
+		t.Fatalf("Bad Exit: \"TestLeaveAndRejoin\" produced err: %v", err)
+	}
+	if err = stayer.Write("after rejoining"); err != nil {
+		t.Fatalf("Bad Exit: \"TestLeaveAndRejoin\" produced err: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	expected := "before\nafter rejoining\n"
+	for _, client := range []*consensuslib.Client{stayer, rejoined} {
+		value, err := client.Read()
+		if err != nil || value != expected {
+			t.Errorf("Bad Exit: expected %s to read %q, got %q, err: %v", client.Addr(), expected, value, err)
+		}
+	}
+
+	// registering again before timing out is a rejoin rather than an error
+	conn, err := rpc.Dial("tcp", serverAddr)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestLeaveAndRejoin\" produced err: %v", err)
+	}
+	defer conn.Close()
+	var neighbours []string
+	rejoin := consensuslib.RegisterArgs{Addr: rejoined.Addr(), ID: rejoined.ID()}
+	if err = conn.Call("Server.Register", rejoin, &neighbours); err != nil {
+		t.Errorf("Bad Exit: expected registering again to rejoin, got err: %v", err)
+	}
+	if strings.Join(neighbours, ",") != stayer.Addr() {
+		t.Errorf("Bad Exit: expected the neighbours to be %s, got %v", stayer.Addr(), neighbours)
+	}
+	impostor := consensuslib.RegisterArgs{Addr: rejoined.Addr(), ID: "impostor"}
+	if err = conn.Call("Server.Register", impostor, &neighbours); err == nil {
+		t.Errorf("Bad Exit: expected another node registering at %s to be refused", rejoined.Addr())
+	}
+
+	// registering the same ID at a new address moves the node there, rather than adding a second member
+	moved := consensuslib.RegisterArgs{Addr: "127.0.0.1:12386", ID: rejoined.ID()}
+	if err = conn.Call("Server.Register", moved, &neighbours); err != nil {
+		t.Errorf("Bad Exit: expected registering at a new address to move the node, got err: %v", err)
+	}
+	if strings.Join(neighbours, ",") != stayer.Addr() {
+		t.Errorf("Bad Exit: expected the old address to be dropped, leaving neighbours %s, got %v", stayer.Addr(), neighbours)
+	}
+}
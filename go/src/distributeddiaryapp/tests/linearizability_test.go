@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"consensuslib"
+	"consensuslib/history"
+	"distributeddiaryapp/tests/util"
+	"fmt"
+	"linearizability"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckerHistories(t *testing.T) {
+	var tests = []struct {
+		Name         string
+		Ops          []linearizability.Operation
+		Linearizable bool
+	}{
+		{
+			Name: "sequential",
+			Ops: []linearizability.Operation{
+				{ClientID: 0, Kind: linearizability.WRITE, Input: "a", Call: 0, Return: 10},
+				{ClientID: 1, Kind: linearizability.READ, Output: "a\n", Call: 20, Return: 30},
+			},
+			Linearizable: true,
+		},
+		{
+			Name: "concurrent writes in either order",
+			Ops: []linearizability.Operation{
+				{ClientID: 0, Kind: linearizability.WRITE, Input: "a", Call: 0, Return: 50},
+				{ClientID: 1, Kind: linearizability.WRITE, Input: "b", Call: 10, Return: 40},
+				{ClientID: 2, Kind: linearizability.READ, Output: "b\na\n", Call: 60, Return: 70},
+			},
+			Linearizable: true,
+		},
+		{
+			Name: "stale read",
+			Ops: []linearizability.Operation{
+				{ClientID: 0, Kind: linearizability.WRITE, Input: "a", Call: 0, Return: 10},
+				{ClientID: 1, Kind: linearizability.READ, Output: "", Call: 20, Return: 30},
+			},
+			Linearizable: false,
+		},
+		{
+			Name: "reordered log",
+			Ops: []linearizability.Operation{
+				{ClientID: 0, Kind: linearizability.WRITE, Input: "a", Call: 0, Return: 10},
+				{ClientID: 1, Kind: linearizability.WRITE, Input: "b", Call: 20, Return: 30},
+				{ClientID: 2, Kind: linearizability.READ, Output: "b\na\n", Call: 40, Return: 50},
+			},
+			Linearizable: false,
+		},
+		{
+			Name: "pending write seen",
+			Ops: []linearizability.Operation{
+				{ClientID: 0, Kind: linearizability.WRITE, Input: "a", Call: 0, Return: linearizability.Pending},
+				{ClientID: 1, Kind: linearizability.READ, Output: "a\n", Call: 20, Return: 30},
+			},
+			Linearizable: true,
+		},
+	}
+	for _, test := range tests {
+		result := linearizability.Check(linearizability.LogModel{}, test.Ops)
+		if result.Linearizable != test.Linearizable {
+			t.Errorf("Bad Exit: \"TestCheckerHistories(%s)\" produced linearizable %v, expected %v", test.Name, result.Linearizable, test.Linearizable)
+		}
+	}
+}
+
+func TestRandomizedLinearizable(t *testing.T) {
+	serverAddr := "127.0.0.1:12346"
+	localAddr := "127.0.0.1:0"
+	numClients := 3
+	numOps := 5
+	seed := time.Now().UnixNano()
+	t.Logf("seed %d", seed)
+	random := rand.New(rand.NewSource(seed))
+
+	util.SetupServer(serverAddr)
+	recorder := history.NewRecorder()
+	nodes := make([]*consensuslib.Client, 0, numClients)
+	clients := make([]*history.RecordedClient, 0, numClients)
+	for i := 0; i < numClients; i++ {
+		client, err := util.SetupClient(serverAddr, localAddr)
+		if err != nil {
+			t.Fatalf("Bad Exit: \"TestRandomizedLinearizable\" produced err: %v", err)
+		}
+		nodes = append(nodes, client)
+		clients = append(clients, recorder.Wrap(client))
+	}
+
+	// each client makes a random sequence of writes with pauses between them
+	schedules := make([][]time.Duration, numClients)
+	for i := range schedules {
+		for j := 0; j < numOps; j++ {
+			schedules[i] = append(schedules[i], time.Duration(random.Intn(50))*time.Millisecond)
+		}
+	}
+	// Client.Read only returns the node's own log, which is eventually consistent, so reads made while
+	// the writes run are not linearizable and are left out of the history. Each of them must still be
+	// a prefix of the log its node converges to.
+	var wg sync.WaitGroup
+	var written int32
+	done := make(chan struct{})
+	concurrentReads := make([][]string, numClients)
+	var readers sync.WaitGroup
+	for i, node := range nodes {
+		readers.Add(1)
+		go func(i int, node *consensuslib.Client) {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				case <-time.After(5 * time.Millisecond):
+				}
+				if log, err := node.Read(); err == nil {
+					concurrentReads[i] = append(concurrentReads[i], log)
+				}
+			}
+		}(i, node)
+	}
+	for i, client := range clients {
+		wg.Add(1)
+		go func(i int, client *history.RecordedClient) {
+			defer wg.Done()
+			for j, pause := range schedules[i] {
+				time.Sleep(pause)
+				if client.Write(fmt.Sprintf("c%dw%d", i, j)) == nil {
+					atomic.AddInt32(&written, 1)
+				}
+			}
+		}(i, client)
+	}
+	wg.Wait()
+	close(done)
+	readers.Wait()
+
+	// once every client learned every committed write, each must read the same, linearizable log
+	for _, node := range nodes {
+		_, err := util.WaitUntil(node, func(log string) bool {
+			return strings.Count(log, "\n") >= int(atomic.LoadInt32(&written))
+		}, 5*time.Second)
+		if err != nil {
+			t.Errorf("Bad Exit: \"TestRandomizedLinearizable\" produced err: %v", err)
+		}
+	}
+	for i, client := range clients {
+		final, err := client.Read()
+		if err != nil {
+			t.Errorf("Bad Exit: \"TestRandomizedLinearizable\" produced err: %v", err)
+			continue
+		}
+		for _, log := range concurrentReads[i] {
+			if !strings.HasPrefix(final, log) {
+				t.Errorf("Bad Exit: \"TestRandomizedLinearizable\" client %d read %q while writing, which is not a prefix of %q", i, log, final)
+			}
+		}
+	}
+
+	result := recorder.Check()
+	if !result.Linearizable {
+		t.Errorf("Bad Exit: history is not linearizable, longest linearization:")
+		for _, op := range result.Longest {
+			t.Errorf("    %v", op)
+		}
+	}
+}
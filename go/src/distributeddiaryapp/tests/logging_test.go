@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"bufio"
+	"consensuslib"
+	"distributeddiaryapp/tests/util"
+	"encoding/json"
+	"filelogger/format"
+	"filelogger/level"
+	"filelogger/logger"
+	"filelogger/state"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStructuredJSONLogs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonlogs")
+	if err != nil {
+		t.Fatalf("Bad Exit: unable to create log dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	l, err := logger.NewFileLoggerWithConfig("jsonlogs", state.QUIET, logger.Config{Dir: dir})
+	if err != nil {
+		t.Fatalf("Bad Exit: unable to create logger: %s", err)
+	}
+	l.SetFormat(format.JSON)
+	nodeLog := l.Component("paxosnode").With(logger.Fields{"node": "127.0.0.1:8080", "round": 3})
+	nodeLog.With(logger.Fields{"ballot": 7}).Debugf("Accepted %v", 2)
+	nodeLog.Warning("no majority")
+	l.Exit()
+
+	paths, _ := filepath.Glob(filepath.Join(dir, "jsonlogs*.log"))
+	if len(paths) == 0 {
+		t.Fatalf("Bad Exit: no log file written")
+	}
+	f, err := os.Open(paths[len(paths)-1])
+	if err != nil {
+		t.Fatalf("Bad Exit: unable to open log: %s", err)
+	}
+	defer f.Close()
+	var tests = []map[string]interface{}{
+		{"level": "debug", "component": "paxosnode", "msg": "Accepted 2", "node": "127.0.0.1:8080", "round": 3.0, "ballot": 7.0},
+		{"level": "warning", "component": "paxosnode", "msg": "no majority", "node": "127.0.0.1:8080", "round": 3.0},
+	}
+	scanner := bufio.NewScanner(f)
+	for i, want := range tests {
+		if !scanner.Scan() {
+			t.Fatalf("Bad Exit: missing line %d", i)
+		}
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("Bad Exit: line %d is not JSON: %s", i, scanner.Text())
+		}
+		for k, v := range want {
+			if line[k] != v {
+				t.Errorf("Bad Exit: line %d: %s = %v, want %v", i, k, line[k], v)
+			}
+		}
+		if _, ok := line["ballot"]; ok && want["ballot"] == nil {
+			t.Errorf("Bad Exit: line %d: fields of a child entry leaked into its parent", i)
+		}
+	}
+}
+
+func TestLogRotation(t *testing.T) {
+	var tests = []struct {
+		Name   string
+		Config logger.Config
+		Logs   int
+		Plain  int
+		Zipped int
+	}{
+		{
+			Name:   "no rotation",
+			Config: logger.Config{},
+			Logs:   20,
+			Plain:  1,
+		},
+		{
+			Name:   "rotate on size",
+			Config: logger.Config{MaxSize: 200},
+			Logs:   20,
+			Plain:  7,
+		},
+		{
+			Name:   "compress rotated files",
+			Config: logger.Config{MaxSize: 200, Compress: true},
+			Logs:   20,
+			Plain:  1,
+			Zipped: 6,
+		},
+		{
+			Name:   "retain newest files",
+			Config: logger.Config{MaxSize: 200, Compress: true, MaxFiles: 2},
+			Logs:   20,
+			Plain:  1,
+			Zipped: 2,
+		},
+	}
+	for i, test := range tests {
+		dir, err := ioutil.TempDir("", "rotation")
+		if err != nil {
+			t.Fatalf("Bad Exit: unable to create log dir: %s", err)
+		}
+		defer os.RemoveAll(dir)
+		test.Config.Dir = dir
+		// names must differ, as loggers are shared by name
+		l, err := logger.NewFileLoggerWithConfig(fmt.Sprintf("rotation%d", i), state.QUIET, test.Config)
+		if err != nil {
+			t.Fatalf("Bad Exit: %q: unable to create logger: %s", test.Name, err)
+		}
+		for n := 0; n < test.Logs; n++ {
+			// each line is roughly 55 bytes, so 3 fit in 200
+			l.Debugf("line %d", n)
+		}
+		l.Exit()
+		plain, _ := filepath.Glob(filepath.Join(dir, "*.log"))
+		zipped, _ := filepath.Glob(filepath.Join(dir, "*.log.gz"))
+		if len(plain) != test.Plain || len(zipped) != test.Zipped {
+			t.Errorf("Bad Exit: %q: got %d log and %d compressed files, want %d and %d", test.Name, len(plain), len(zipped), test.Plain, test.Zipped)
+		}
+	}
+}
+
+func TestComponentLevels(t *testing.T) {
+	dir, err := ioutil.TempDir("", "levels")
+	if err != nil {
+		t.Fatalf("Bad Exit: unable to create log dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	l, err := logger.NewFileLoggerWithConfig("levels", state.QUIET, logger.Config{Dir: dir})
+	if err != nil {
+		t.Fatalf("Bad Exit: unable to create logger: %s", err)
+	}
+	l.SetLevel("acceptor", level.DEBUG)
+	l.SetLevel("", level.INFO)
+	l.Component("acceptor").Debug("acceptor debug")
+	l.Component("paxosnode").Debug("paxosnode debug")
+	l.Component("paxosnode").Info("paxosnode info")
+	l.ResetLevel("")
+	l.Component("paxosnode").Debug("paxosnode debug after reset")
+	l.Exit()
+
+	paths, _ := filepath.Glob(filepath.Join(dir, "levels*.log"))
+	if len(paths) == 0 {
+		t.Fatalf("Bad Exit: no log file written")
+	}
+	written, _ := ioutil.ReadFile(paths[0])
+	var tests = []struct {
+		Line    string
+		Written bool
+	}{
+		{"[acceptor] acceptor debug", true},
+		{"[paxosnode] paxosnode debug\n", false},
+		{"[paxosnode] paxosnode info", true},
+		{"[paxosnode] paxosnode debug after reset", true},
+	}
+	for _, test := range tests {
+		if strings.Contains(string(written), test.Line) != test.Written {
+			t.Errorf("Bad Exit: %q written is %v, want %v", test.Line, !test.Written, test.Written)
+		}
+	}
+}
+
+func TestRemoteLogLevel(t *testing.T) {
+	serverAddr := "127.0.0.1:12349"
+	util.SetupServer(serverAddr)
+	a, err := util.SetupClient(serverAddr, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestRemoteLogLevel\" produced err: %v", err)
+	}
+	b, err := util.SetupClient(serverAddr, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestRemoteLogLevel\" produced err: %v", err)
+	}
+	defer a.SetLogLevel(b.Addr(), consensuslib.LogLevelArgs{Component: "acceptor", Level: "reset"})
+
+	var tests = []struct {
+		Args consensuslib.LogLevelArgs
+		Want string
+		Err  bool
+	}{
+		{consensuslib.LogLevelArgs{Component: "acceptor", Level: "warning"}, "warning", false},
+		{consensuslib.LogLevelArgs{}, "warning", false},
+		{consensuslib.LogLevelArgs{Component: "acceptor", Level: "loud"}, "", true},
+		{consensuslib.LogLevelArgs{Component: "acceptor", Level: "reset"}, "", false},
+	}
+	for _, test := range tests {
+		levels, err := a.SetLogLevel(b.Addr(), test.Args)
+		if (err != nil) != test.Err {
+			t.Errorf("Bad Exit: %v produced err: %v", test.Args, err)
+			continue
+		}
+		if !test.Err && levels["acceptor"] != test.Want {
+			t.Errorf("Bad Exit: %v left acceptor at %q, want %q", test.Args, levels["acceptor"], test.Want)
+		}
+	}
+}
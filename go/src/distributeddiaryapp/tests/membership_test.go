@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"consensuslib"
+	"consensuslib/membership"
+	"distributeddiaryapp/tests/util"
+	"net"
+	"net/rpc"
+	"testing"
+	"time"
+)
+
+// fastMembership probes every 20ms, and declares a member dead 200ms after suspecting it
+var fastMembership = membership.Config{
+	ProbeInterval:    20 * time.Millisecond,
+	ProbeTimeout:     8 * time.Millisecond,
+	IndirectProbes:   2,
+	SuspicionTimeout: 200 * time.Millisecond,
+}
+
+// startMember serves a membership on a new listener
+func startMember(t *testing.T) (*membership.Membership, net.Listener) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Bad Exit: unable to listen: %v", err)
+	}
+	m := membership.New(listener.Addr().String(), fastMembership, nil)
+	server := rpc.NewServer()
+	server.Register(m.RPC())
+	go server.Accept(listener)
+	return m, listener
+}
+
+func stateOf(m *membership.Membership, addr string) membership.State {
+	for _, member := range m.Members() {
+		if member.Addr == addr {
+			return member.State
+		}
+	}
+	return ""
+}
+
+func TestMembershipGossip(t *testing.T) {
+	a, aListener := startMember(t)
+	b, bListener := startMember(t)
+	c, cListener := startMember(t)
+	addrs := []string{aListener.Addr().String(), bListener.Addr().String(), cListener.Addr().String()}
+	// a admitted b and c, which only know of a, and learn of each other by gossip
+	a.Add(addrs[1], addrs[2])
+	b.Add(addrs[0])
+	c.Add(addrs[0])
+	for _, m := range []*membership.Membership{a, b, c} {
+		m.Start()
+	}
+	time.Sleep(300 * time.Millisecond)
+	if state := stateOf(b, addrs[2]); state != membership.ALIVE {
+		t.Errorf("Bad Exit: expected %s to learn %s is alive, got %q", addrs[1], addrs[2], state)
+	}
+	if state := stateOf(c, addrs[1]); state != membership.ALIVE {
+		t.Errorf("Bad Exit: expected %s to learn %s is alive, got %q", addrs[2], addrs[1], state)
+	}
+
+	// c dies: it is suspected first, then declared dead by both a and b
+	c.Stop()
+	cListener.Close()
+	time.Sleep(100 * time.Millisecond)
+	for _, m := range []*membership.Membership{a, b} {
+		if state := stateOf(m, addrs[2]); state != membership.SUSPECT && state != membership.DEAD {
+			t.Errorf("Bad Exit: expected %s to be suspected, got %q", addrs[2], state)
+		}
+	}
+	time.Sleep(400 * time.Millisecond)
+	for i, m := range []*membership.Membership{a, b} {
+		if state := stateOf(m, addrs[2]); state != membership.DEAD {
+			t.Errorf("Bad Exit: expected %s to declare %s dead, got %q", addrs[i], addrs[2], state)
+		}
+	}
+	if state := stateOf(a, addrs[1]); state != membership.ALIVE {
+		t.Errorf("Bad Exit: expected %s to stay alive, got %q", addrs[1], state)
+	}
+	a.Stop()
+	b.Stop()
+}
+
+func TestMembershipStranger(t *testing.T) {
+	a, aListener := startMember(t)
+	defer a.Stop()
+	defer aListener.Close()
+	stranger, strangerListener := startMember(t)
+	defer strangerListener.Close()
+	// the stranger pings a without having been added by any member
+	stranger.Add(aListener.Addr().String())
+	stranger.Start()
+	defer stranger.Stop()
+	a.Start()
+	time.Sleep(100 * time.Millisecond)
+	if state := stateOf(a, strangerListener.Addr().String()); state != "" {
+		t.Errorf("Bad Exit: expected the stranger not to become a member, got %q", state)
+	}
+	if state := stateOf(stranger, aListener.Addr().String()); state == membership.ALIVE {
+		t.Errorf("Bad Exit: expected the stranger's pings to be refused, got %q", state)
+	}
+}
+
+func TestMembershipNeighbours(t *testing.T) {
+	clients := make([]*consensuslib.Client, 3)
+	var err error
+	for i := range clients {
+		clients[i], err = consensuslib.NewClientWithConfig("127.0.0.1:0", "", util.ClientConfig())
+		if err != nil {
+			t.Fatalf("Bad Exit: \"TestMembershipNeighbours\" produced err: %v", err)
+		}
+		if err = clients[i].EnableMembership(fastMembership); err != nil {
+			t.Fatalf("Bad Exit: \"TestMembershipNeighbours\" produced err: %v", err)
+		}
+		if err = clients[i].Join(clients[0].Addr()); err != nil {
+			t.Fatalf("Bad Exit: \"TestMembershipNeighbours\" produced err: %v", err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err = clients[2].Write("gossiped"); err != nil {
+		t.Errorf("Bad Exit: \"TestMembershipNeighbours\" produced err: %v", err)
+	}
+	for _, client := range clients {
+		// the other nodes learn the value after the write returns
+		util.WaitForLog(client, "gossiped\n", time.Second)
+		status, _ := client.Status("")
+		if len(status.Members) != 2 || status.LogLength != 1 {
+			t.Errorf("Bad Exit: %s has members %v and %d values, expected 2 members and 1 value", client.Addr(), status.Members, status.LogLength)
+		}
+		for _, member := range status.Members {
+			if member.State != membership.ALIVE {
+				t.Errorf("Bad Exit: %s sees %s as %s", client.Addr(), member.Addr, member.State)
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"consensuslib"
+	"distributeddiaryapp/tests/util"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	serverAddr := "127.0.0.1:12350"
+	server, err := consensuslib.NewServer(serverAddr)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestMetricsEndpoint\" produced err: %v", err)
+	}
+	go server.Serve()
+	a, err := util.SetupClient(serverAddr, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestMetricsEndpoint\" produced err: %v", err)
+	}
+	_, err = util.SetupClient(serverAddr, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestMetricsEndpoint\" produced err: %v", err)
+	}
+	nodeMetrics, err := a.ServeHTTP("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestMetricsEndpoint\" produced err: %v", err)
+	}
+	serverMetrics, err := server.ServeMetrics("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestMetricsEndpoint\" produced err: %v", err)
+	}
+
+	a.Write("counted")
+	time.Sleep(100 * time.Millisecond)
+
+	var tests = []struct {
+		Addr string
+		Line string
+	}{
+		{nodeMetrics, "paxos_log_length 1\n"},
+		{nodeMetrics, "paxos_prepare_duration_seconds_count 1\n"},
+		{nodeMetrics, "paxos_accept_duration_seconds_count 1\n"},
+		{nodeMetrics, "paxos_learn_lag_seconds_count 1\n"},
+		{nodeMetrics, "paxos_retries_total 0\n"},
+		{nodeMetrics, "paxos_neighbours 1\n"},
+		{nodeMetrics, "# TYPE paxos_prepare_duration_seconds histogram\n"},
+		{serverMetrics, "server_registered_users 2\n"},
+		{serverMetrics, "server_heartbeats_missed_total 0\n"},
+	}
+	scraped := make(map[string]string)
+	for _, test := range tests {
+		if _, ok := scraped[test.Addr]; !ok {
+			resp, err := http.Get("http://" + test.Addr + "/metrics")
+			if err != nil {
+				t.Fatalf("Bad Exit: unable to scrape %s: %v", test.Addr, err)
+			}
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			scraped[test.Addr] = string(body)
+		}
+		if !strings.Contains(scraped[test.Addr], test.Line) {
+			t.Errorf("Bad Exit: %s is missing %q", test.Addr, test.Line)
+		}
+	}
+}
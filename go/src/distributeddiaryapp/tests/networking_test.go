@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"distributeddiaryapp/networking"
+	"net"
+	"os"
+	"testing"
+)
+
+func TestAdvertisedAddress(t *testing.T) {
+	loopback := ""
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 && iface.Flags&net.FlagUp != 0 {
+			loopback = iface.Name
+		}
+	}
+	os.Setenv("DIARY_TEST_ADDR", "10.1.2.3")
+	defer os.Unsetenv("DIARY_TEST_ADDR")
+	failing := networking.Static("not an address")
+
+	var tests = []struct {
+		Name     string
+		Options  networking.Options
+		Resolver networking.Resolver // used instead of the options when set
+		Expected string
+		Fails    bool
+	}{
+		{"Addr", networking.Options{Addr: "10.0.0.7"}, nil, "10.0.0.7:12345", false},
+		{"MappedPort", networking.Options{Addr: "10.0.0.7:80"}, nil, "10.0.0.7:80", false},
+		{"BadAddr", networking.Options{Addr: "diary.local"}, nil, "", true},
+		{"MappedPortBadAddr", networking.Options{Addr: "foo:1"}, nil, "", true},
+		{"MappedPortBadPort", networking.Options{Addr: "10.0.0.7:http"}, nil, "", true},
+		{"MappedPortIPv6", networking.Options{Addr: "[::1]:80"}, nil, "[::1]:80", false},
+		{"Interface", networking.Options{Interface: loopback}, nil, "127.0.0.1:12345", false},
+		{"NoInterface", networking.Options{Interface: "nosuchiface0"}, nil, "", true},
+		{"CIDR", networking.Options{CIDR: "127.0.0.0/8"}, nil, "127.0.0.1:12345", false},
+		{"NoCIDR", networking.Options{CIDR: "203.0.113.0/24"}, nil, "", true},
+		{"Env", networking.Options{}, networking.Chain(failing, networking.Env("DIARY_UNSET_ADDR", "DIARY_TEST_ADDR")), "10.1.2.3:12345", false},
+		{"AllFail", networking.Options{}, networking.Chain(failing, networking.Env("DIARY_UNSET_ADDR")), "", true},
+	}
+	for _, test := range tests {
+		var addr string
+		var err error
+		if test.Resolver != nil {
+			var ip string
+			if ip, err = test.Resolver.Resolve(); err == nil {
+				addr = net.JoinHostPort(ip, "12345")
+			}
+		} else {
+			addr, err = networking.Advertise(test.Options, "12345")
+		}
+		if (err != nil) != test.Fails || addr != test.Expected {
+			t.Errorf("Bad Exit: %s: expected %q, failing: %v, got %q, err: %v", test.Name, test.Expected, test.Fails, addr, err)
+		}
+	}
+}
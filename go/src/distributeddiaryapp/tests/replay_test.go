@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"consensuslib/paxosnode"
+	"consensuslib/paxosnode/trace"
+	"distributeddiaryapp/tests/util"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"paxostracker"
+	"testing"
+	"time"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	serverAddr := "127.0.0.1:12348"
+	localAddr := "127.0.0.1:0"
+	dir, err := ioutil.TempDir("", "replaytest")
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestRecordAndReplay\" produced err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	tracePath := filepath.Join(dir, "node.trace")
+
+	util.SetupServer(serverAddr)
+	client0, err := util.SetupClient(serverAddr, localAddr)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestRecordAndReplay\" produced err: %v", err)
+	}
+	err = client0.Write("before recording")
+	if err != nil {
+		t.Errorf("Bad Exit: \"TestRecordAndReplay\" produced err: %v", err)
+	}
+	client1, err := util.SetupClient(serverAddr, localAddr)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestRecordAndReplay\" produced err: %v", err)
+	}
+	err = client1.RecordTrace(tracePath)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestRecordAndReplay\" produced err: %v", err)
+	}
+	for _, value := range []string{"from zero", "from one", "zero again"} {
+		client := client0
+		if value == "from one" {
+			client = client1
+		}
+		err = client.Write(value)
+		if err != nil {
+			t.Errorf("Bad Exit: \"TestRecordAndReplay\" produced err: %v", err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	recorded, err := client1.Read()
+	if err != nil {
+		t.Errorf("Bad Exit: \"TestRecordAndReplay\" produced err: %v", err)
+	}
+
+	entries, err := trace.Load(tracePath)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestRecordAndReplay\" produced err: %v", err)
+	}
+	kinds := make(map[trace.Kind]int)
+	for _, e := range entries {
+		kinds[e.Kind]++
+	}
+	// client1's write, and the calls it made to client0, are in the trace to be made again
+	if kinds[trace.WRITE] != 1 || kinds[trace.CALL] == 0 {
+		t.Errorf("Bad Exit: \"TestRecordAndReplay\" recorded entries %v, expected a write and calls", kinds)
+	}
+	config := paxosnode.DefaultConfig()
+	config.ID = client1.ID()
+	config.DataDir = dir
+	pn, err := paxosnode.NewPaxosNodeWithConfig(client1.Addr(), paxostracker.NewPaxosTracker(client1.Addr()), config)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestRecordAndReplay\" produced err: %v", err)
+	}
+	divergences, err := pn.Replay(entries)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestRecordAndReplay\" produced err: %v", err)
+	}
+	for _, d := range divergences {
+		t.Errorf("Bad Exit: replay diverged: %s", d)
+	}
+	proposed := false
+	for _, value := range pn.Proposer.GetProposals() {
+		proposed = proposed || value == "from one"
+	}
+	if !proposed {
+		t.Errorf("Bad Exit: \"TestRecordAndReplay\" replayed proposer did not propose the recorded write")
+	}
+	replayed := ""
+	for _, m := range pn.Learner.Log {
+		replayed += m.Value + "\n"
+	}
+	if replayed != recorded {
+		t.Errorf("Bad Exit: Replayed log '%s' does not match recorded log '%s'", replayed, recorded)
+	}
+}
@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"consensuslib"
+	"distributeddiaryapp/tests/util"
+	"testing"
+	"time"
+)
+
+func TestServerFailover(t *testing.T) {
+	group := []string{"127.0.0.1:12354", "127.0.0.1:12355"}
+	primary, err := consensuslib.NewReplicatedServer(group[0], group[1:])
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestServerFailover\" produced err: %v", err)
+	}
+	go primary.Serve()
+	backup, err := consensuslib.NewReplicatedServer(group[1], group[:1])
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestServerFailover\" produced err: %v", err)
+	}
+	go backup.Serve()
+	if !primary.IsPrimary() || backup.IsPrimary() {
+		t.Fatalf("Bad Exit: expected %s to be the primary", group[0])
+	}
+
+	clients := make([]*consensuslib.Client, 3)
+	for i := range clients[:2] {
+		clients[i], err = consensuslib.NewClientWithConfig("127.0.0.1:0", "", util.ClientConfig())
+		if err != nil {
+			t.Fatalf("Bad Exit: \"TestServerFailover\" produced err: %v", err)
+		}
+		// the backup is listed first, and refuses the registration
+		if err = clients[i].Connect(group[1], group[0]); err != nil {
+			t.Fatalf("Bad Exit: \"TestServerFailover\" produced err: %v", err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	inspector, err := consensuslib.NewInspector(group[1])
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestServerFailover\" produced err: %v", err)
+	}
+	if members, _ := inspector.Members(); len(members) != 2 {
+		t.Errorf("Bad Exit: expected the backup to have 2 members, got %v", members)
+	}
+
+	primary.Close()
+	time.Sleep(time.Second)
+	if !backup.IsPrimary() {
+		t.Fatalf("Bad Exit: expected %s to take over as primary", group[1])
+	}
+	clients[2], err = consensuslib.NewClientWithConfig("127.0.0.1:0", "", util.ClientConfig())
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestServerFailover\" produced err: %v", err)
+	}
+	if err = clients[2].Connect(group...); err != nil {
+		t.Fatalf("Bad Exit: \"TestServerFailover\" produced err: %v", err)
+	}
+
+	// outlive the heartbeat timeout, so clients that did not fail over are dropped
+	time.Sleep(3 * time.Second)
+	if members, _ := inspector.Members(); len(members) != 3 {
+		t.Errorf("Bad Exit: expected 3 members after failing over, got %v", members)
+	}
+	if err = clients[2].Write("after failover"); err != nil {
+		t.Errorf("Bad Exit: \"TestServerFailover\" produced err: %v", err)
+	}
+	if value, _ := clients[0].Read(); value != "after failover\n" {
+		t.Errorf("Bad Exit: expected \"after failover\" to be read, got %q", value)
+	}
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"consensuslib"
+	"consensuslib/message"
+	"consensuslib/paxosnode"
+	"consensuslib/safety"
+	"distributeddiaryapp/tests/util"
+	"fmt"
+	"paxostracker"
+	"testing"
+	"time"
+)
+
+func TestSafetyCheck(t *testing.T) {
+	a := message.NewMessage(1, "aaaa", message.ACCEPT, "a", "n0", 0, 3)
+	b := message.NewMessage(2, "bbbb", message.ACCEPT, "b", "n1", 1, 3)
+	c := message.NewMessage(2, "cccc", message.ACCEPT, "c", "n2", 1, 3)
+	proposals := map[string]string{"aaaa": "a", "bbbb": "b", "cccc": "c"}
+	var tests = []struct {
+		Name       string
+		Logs       map[string][]message.Message
+		Properties []safety.Property
+	}{
+		{
+			Name: "agreeing prefixes",
+			Logs: map[string][]message.Message{
+				"n0": {a, b},
+				"n1": {a},
+				"n2": {},
+			},
+			Properties: nil,
+		},
+		{
+			Name: "conflicting slot",
+			Logs: map[string][]message.Message{
+				"n0": {a, b},
+				"n1": {a, c},
+			},
+			Properties: []safety.Property{safety.AGREEMENT},
+		},
+		{
+			Name: "never proposed",
+			Logs: map[string][]message.Message{
+				"n0": {a, message.NewMessage(3, "dddd", message.ACCEPT, "d", "n3", 2, 3)},
+			},
+			Properties: []safety.Property{safety.VALIDITY},
+		},
+	}
+	for _, test := range tests {
+		snapshot := safety.NewSnapshot()
+		snapshot.Logs = test.Logs
+		snapshot.Proposals["n0"] = proposals
+		violations := safety.Check(snapshot)
+		if len(violations) != len(test.Properties) {
+			t.Errorf("Bad Exit: \"TestSafetyCheck(%s)\" produced violations %v, expected %v", test.Name, violations, test.Properties)
+			continue
+		}
+		for i, v := range violations {
+			if v.Property != test.Properties[i] {
+				t.Errorf("Bad Exit: \"TestSafetyCheck(%s)\" produced violation %v, expected %v", test.Name, v, test.Properties[i])
+			}
+		}
+	}
+}
+
+func TestProposalsRestored(t *testing.T) {
+	addr := "127.0.0.1:12378"
+	config := paxosnode.DefaultConfig()
+	config.DataDir = util.NewDataDir()
+	pn, err := paxosnode.NewPaxosNodeWithConfig(addr, paxostracker.NewPaxosTracker(addr), config)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestProposalsRestored\" produced err: %v", err)
+	}
+	pn.Proposer.CreatePrepareRequest(0, "aaaa", 3)
+	pn.Proposer.CreateAcceptRequest("a", "aaaa", 0, 3)
+
+	// a node restarted on the same data directory must still vouch for the values it proposed
+	restarted, err := paxosnode.NewPaxosNodeWithConfig(addr, paxostracker.NewPaxosTracker(addr), config)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestProposalsRestored\" produced err: %v", err)
+	}
+	proposals := restarted.Proposer.GetProposals()
+	if len(proposals) != 1 || proposals["aaaa"] != "a" {
+		t.Errorf("Bad Exit: \"TestProposalsRestored\" produced proposals %v, expected %v", proposals, map[string]string{"aaaa": "a"})
+	}
+}
+
+func TestThreeClientsSafe(t *testing.T) {
+	serverAddr := "127.0.0.1:12347"
+	localAddr := "127.0.0.1:0"
+	util.SetupServer(serverAddr)
+	clients := make([]*consensuslib.Client, 0, 3)
+	for i := 0; i < 3; i++ {
+		client, err := util.SetupClient(serverAddr, localAddr)
+		if err != nil {
+			t.Fatalf("Bad Exit: \"TestThreeClientsSafe\" produced err: %v", err)
+		}
+		clients = append(clients, client)
+	}
+	for i, client := range clients {
+		err := client.Write(fmt.Sprintf("entry %d", i))
+		if err != nil {
+			t.Errorf("Bad Exit: \"TestThreeClientsSafe\" produced err: %v", err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	violations, err := util.CheckSafety(clients)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestThreeClientsSafe\" produced err: %v", err)
+	}
+	for _, v := range violations {
+		t.Errorf("Bad Exit: %v", v)
+	}
+}
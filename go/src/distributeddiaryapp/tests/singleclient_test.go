@@ -7,7 +7,7 @@ import (
 )
 
 func TestSingleClientReadWrite(t *testing.T) {
-	serverAddr := "127.0.0.1:12345"
+	serverAddr := "127.0.0.1:12372"
 	localAddr := "127.0.0.1:0"
 	var tests = []struct {
 		Data string
@@ -20,22 +20,24 @@ func TestSingleClientReadWrite(t *testing.T) {
 		},
 	}
 	util.SetupServer(serverAddr)
+	// every client joins the same network, so each reads the values written before it joined too
+	log := ""
 	for _, test := range tests {
 		client, err := util.SetupClient(serverAddr, localAddr)
 		if err != nil {
-			t.Errorf("Bad Exit: \"TestSingleClientReadWrite(%v)\" produced err: %v", test, err)
+			t.Fatalf("Bad Exit: \"TestSingleClientReadWrite(%v)\" produced err: %v", test, err)
 		}
 		err = client.Write(test.Data)
 		if err != nil {
 			t.Errorf("Bad Exit: \"TestSingleClientReadWrite(%v)\" produced err: %v", test, err)
 		}
-		value, err := client.Read()
+		log += test.Data + "\n"
+		value, err := util.WaitForLog(client, log, time.Second)
 		if err != nil {
 			t.Errorf("Bad Exit: \"TestSingleClientReadWrite(%v)\" produced err: %v", test, err)
 		}
-		if value != test.Data {
-			t.Errorf("Bad Exit: Read Data '%s' does not match written data '%s'", value, test.Data)
+		if value != log {
+			t.Errorf("Bad Exit: Read Data '%s' does not match written data '%s'", value, log)
 		}
-		time.Sleep(5 * time.Millisecond)
 	}
 }
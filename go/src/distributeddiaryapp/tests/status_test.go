@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"consensuslib"
+	"distributeddiaryapp/tests/util"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNodeStatus(t *testing.T) {
+	serverAddr := "127.0.0.1:12352"
+	err := util.SetupServer(serverAddr)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestNodeStatus\" produced err: %v", err)
+	}
+	a, err := util.SetupClient(serverAddr, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestNodeStatus\" produced err: %v", err)
+	}
+	b, err := util.SetupClient(serverAddr, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestNodeStatus\" produced err: %v", err)
+	}
+	httpAddr, err := a.ServeHTTP("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestNodeStatus\" produced err: %v", err)
+	}
+
+	a.Write("first")
+	a.Write("second")
+	time.Sleep(100 * time.Millisecond)
+
+	local, err := a.Status("")
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestNodeStatus\" produced err: %v", err)
+	}
+	remote, err := b.Status(a.Addr())
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestNodeStatus\" produced err: %v", err)
+	}
+	var served consensuslib.Status
+	resp, err := http.Get("http://" + httpAddr + "/status")
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestNodeStatus\" produced err: %v", err)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&served)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestNodeStatus\" produced err: %v", err)
+	}
+
+	var tests = []struct {
+		Source string
+		Status consensuslib.Status
+	}{
+		{"local", local},
+		{"RPC", remote},
+		{"HTTP", served},
+	}
+	for _, test := range tests {
+		if test.Status.Addr != a.Addr() {
+			t.Errorf("Bad Exit: %s status has address %s, expected %s", test.Source, test.Status.Addr, a.Addr())
+		}
+		if test.Status.LogLength != 2 || test.Status.RoundNum != 2 {
+			t.Errorf("Bad Exit: %s status has log length %d at round %d, expected 2 at round 2", test.Source, test.Status.LogLength, test.Status.RoundNum)
+		}
+		if len(test.Status.Neighbours) != 1 || test.Status.Neighbours[0] != b.Addr() {
+			t.Errorf("Bad Exit: %s status has neighbours %v, expected [%s]", test.Source, test.Status.Neighbours, b.Addr())
+		}
+		if test.Status.LastAccepted.Value != "second" {
+			t.Errorf("Bad Exit: %s status last accepted %q, expected \"second\"", test.Source, test.Status.LastAccepted.Value)
+		}
+	}
+
+	// the status and metrics stop being served once the node leaves
+	if err = a.Leave(); err != nil {
+		t.Fatalf("Bad Exit: \"TestNodeStatus\" produced err: %v", err)
+	}
+	if resp, err = http.Get("http://" + httpAddr + "/status"); err == nil {
+		resp.Body.Close()
+		t.Errorf("Bad Exit: expected the status not to be served after leaving")
+	}
+}
@@ -27,296 +27,61 @@ func ThreeTests() []TestThreeData {
 }
 
 func TestThreeReadOneWrite(t *testing.T) {
-	serverAddr := "127.0.0.1:12345"
-	localAddr := "127.0.0.1:0"
+	serverAddr := "127.0.0.1:12375"
 	util.SetupServer(serverAddr)
+	// every client joins the same network, so each reads the values written before it joined too
+	log := ""
 	for _, test := range ThreeTests() {
-		client0, err := util.SetupClient(serverAddr, localAddr)
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestThreeReadOneWrite(%v)\" produced err: %v", test, err)
-		}
-		client1, err := util.SetupClient(serverAddr, localAddr)
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestThreeReadOneWrite(%v)\" produced err: %v", test, err)
-		}
-		client2, err := util.SetupClient(serverAddr, localAddr)
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestThreeReadOneWrite(%v)\" produced err: %v", test, err)
-		}
+		clients := setupClients(t, "TestThreeReadOneWrite", serverAddr, 3)
 
 		// C0 Writes
-		err = client0.Write(test.DataC0)
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestThreeReadOneWrite(%v)\" produced err: %v", test, err)
-		}
-		// C0 Reads
-		value, err := client0.Read()
+		err := clients[0].Write(test.DataC0)
 		if err != nil {
 			t.Errorf("Bad Exit: \"TestThreeReadOneWrite(%v)\" produced err: %v", test, err)
 		}
+		log += test.DataC0 + "\n"
 
-		// Can C0 see it's own value?
-		if value != test.DataC0 {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 0 does not match written data '%s'", value, test.DataC0)
-		}
-
-		// C1 Reads
-		value, err = client1.Read()
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestThreeReadOneWrite(%v)\" produced err: %v", test, err)
-		}
-
-		// Can C1 see C0's value?
-		if value != test.DataC0 {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 1 does not match written data '%s'", value, test.DataC0)
-		}
-
-		// C2 Reads
-		value, err = client2.Read()
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestThreeReadOneWrite(%v)\" produced err: %v", test, err)
-		}
-
-		// Can C2 see C0's value?
-		if value != test.DataC0 {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 2 does not match written data '%s'", value, test.DataC0)
-		}
+		// Can every client see C0's value?
+		checkLog(t, "TestThreeReadOneWrite", clients, log)
 	}
 }
 
 func TestThreeReadTwoWrite(t *testing.T) {
-	serverAddr := "127.0.0.1:12345"
-	localAddr := "127.0.0.1:0"
+	serverAddr := "127.0.0.1:12376"
 	util.SetupServer(serverAddr)
+	log := ""
 	for _, test := range ThreeTests() {
-		client0, err := util.SetupClient(serverAddr, localAddr)
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-		client1, err := util.SetupClient(serverAddr, localAddr)
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-		client2, err := util.SetupClient(serverAddr, localAddr)
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-
-		// C0 Writes
-		err = client0.Write(test.DataC0)
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-		// C0 Reads
-		value, err := client0.Read()
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-
-		// Can C0 see it's own value?
-		if value != test.DataC0 {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 0 does not match written data '%s'", value, test.DataC0)
-		}
-
-		// C1 Reads
-		value, err = client1.Read()
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-
-		// Can C1 see C0's value?
-		if value != test.DataC0 {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 1 does not match written data '%s'", value, test.DataC0)
-		}
-
-		// C2 Reads
-		value, err = client2.Read()
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-
-		// Can C2 see C0's value?
-		if value != test.DataC2 {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 2 does not match written data '%s'", value, test.DataC0)
-		}
-
-		// C1 Writes
-		err = client1.Write(test.DataC1)
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-
-		// C1 Reads
-		value, err = client1.Read()
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-
-		// Can C1 see the combined log?
-		combinedData := test.DataC0 + " " + test.DataC1
-		if value != combinedData {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 1 does not match written data '%s'", value, combinedData)
-		}
-
-		// C0 Reads
-		value, err = client0.Read()
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-
-		// Can C0 see the combined log?
-		if value != combinedData {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 0 does not match written data '%s'", value, combinedData)
-		}
+		clients := setupClients(t, "TestThreeReadTwoWrite", serverAddr, 3)
 
-		// C2 Reads
-		value, err = client2.Read()
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
+		for i, data := range []string{test.DataC0, test.DataC1} {
+			err := clients[i].Write(data)
+			if err != nil {
+				t.Errorf("Bad Exit: \"TestThreeReadTwoWrite(%v)\" produced err: %v", test, err)
+			}
+			log += data + "\n"
 
-		// Can C2 see the combined log?
-		if value != combinedData {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 2 does not match written data '%s'", value, combinedData)
+			// Can every client see the combined log?
+			checkLog(t, "TestThreeReadTwoWrite", clients, log)
 		}
 	}
 }
 
 func TestThreeReadThreeWrite(t *testing.T) {
-	serverAddr := "127.0.0.1:12345"
-	localAddr := "127.0.0.1:0"
+	serverAddr := "127.0.0.1:12377"
 	util.SetupServer(serverAddr)
+	log := ""
 	for _, test := range ThreeTests() {
-		client0, err := util.SetupClient(serverAddr, localAddr)
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-		client1, err := util.SetupClient(serverAddr, localAddr)
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-		client2, err := util.SetupClient(serverAddr, localAddr)
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-
-		// C0 Writes
-		err = client0.Write(test.DataC0)
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-		// C0 Reads
-		value, err := client0.Read()
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
+		clients := setupClients(t, "TestThreeReadThreeWrite", serverAddr, 3)
 
-		// Can C0 see it's own value?
-		if value != test.DataC0 {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 0 does not match written data '%s'", value, test.DataC0)
-		}
-
-		// C1 Reads
-		value, err = client1.Read()
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-
-		// Can C1 see C0's value?
-		if value != test.DataC0 {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 1 does not match written data '%s'", value, test.DataC0)
-		}
-
-		// C2 Reads
-		value, err = client2.Read()
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-
-		// Can C2 see C0's value?
-		if value != test.DataC2 {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 2 does not match written data '%s'", value, test.DataC0)
-		}
-
-		// C1 Writes
-		err = client1.Write(test.DataC1)
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-
-		// C1 Reads
-		value, err = client1.Read()
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-
-		// Can C1 see the combined log?
-		combinedData := test.DataC0 + " " + test.DataC1
-		if value != combinedData {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 1 does not match written data '%s'", value, combinedData)
-		}
-
-		// C0 Reads
-		value, err = client0.Read()
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-
-		// Can C0 see the combined log?
-		if value != combinedData {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 0 does not match written data '%s'", value, combinedData)
-		}
-
-		// C2 Reads
-		value, err = client2.Read()
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-
-		// Can C2 see the combined log?
-		if value != combinedData {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 2 does not match written data '%s'", value, combinedData)
-		}
-
-		// C2 Writes
-		err = client2.Write(test.DataC2)
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-
-		// C1 Reads
-		value, err = client1.Read()
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-
-		combinedData = test.DataC0 + " " + test.DataC1 + " " + test.DataC2
-
-		// Can C1 see the combined log?
-		if value != combinedData {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 1 does not match written data '%s'", value, combinedData)
-		}
-
-		// C0 Reads
-		value, err = client0.Read()
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-
-		// Can C0 see the combined log?
-		if value != combinedData {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 0 does not match written data '%s'", value, combinedData)
-		}
-
-		// C2 Reads
-		value, err = client2.Read()
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
+		for i, data := range []string{test.DataC0, test.DataC1, test.DataC2} {
+			err := clients[i].Write(data)
+			if err != nil {
+				t.Errorf("Bad Exit: \"TestThreeReadThreeWrite(%v)\" produced err: %v", test, err)
+			}
+			log += data + "\n"
 
-		// Can C2 see the combined log?
-		if value != combinedData {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 2 does not match written data '%s'", value, combinedData)
+			// Can every client see the combined log?
+			checkLog(t, "TestThreeReadThreeWrite", clients, log)
 		}
 	}
 }
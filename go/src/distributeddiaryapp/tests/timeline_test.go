@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"paxostracker"
+	"paxostracker/timeline"
+	"strings"
+	"testing"
+)
+
+// trackedWrite of value, proposed by the first tracker and accepted by the second
+func trackedWrite(value string) (proposer, acceptor *paxostracker.PaxosTracker) {
+	proposer = paxostracker.NewPaxosTracker("127.0.0.1:12383")
+	proposer.SetID("proposer")
+	acceptor = paxostracker.NewPaxosTracker("127.0.0.1:12384")
+	acceptor.SetID("acceptor")
+	proposer.Prepare("proposer", value, 0)
+	acceptor.Promise("proposer", 1, 0)
+	proposer.Propose(1, value, 0)
+	acceptor.Accept("proposer", 1, 0)
+	proposer.Learn(1, value, 0)
+	proposer.Idle(value)
+	acceptor.Idle(value)
+	return proposer, acceptor
+}
+
+// transitions of a round, as state@time
+func transitions(round paxostracker.PaxosRound) string {
+	described := make([]string, 0, len(round.Transitions))
+	for _, transition := range round.Transitions {
+		described = append(described, fmt.Sprintf("%s@%d", transition.State, transition.Time.UnixNano()))
+	}
+	return strings.Join(described, " ")
+}
+
+func TestExportJSON(t *testing.T) {
+	proposer, acceptor := trackedWrite("a")
+	for _, tracker := range []*paxostracker.PaxosTracker{proposer, acceptor} {
+		var buf bytes.Buffer
+		if err := tracker.WriteJSON(&buf); err != nil {
+			t.Fatalf("Bad Exit: \"TestExportJSON\" produced err: %v", err)
+		}
+		// the export is written as is, so paxostimeline can read it back
+		export, err := paxostracker.ParseExport(buf.Bytes())
+		if err != nil {
+			t.Fatalf("Bad Exit: \"TestExportJSON\" produced err: %v", err)
+		}
+		want := tracker.Export()
+		if export.Node != want.Node || export.ID != want.ID || export.AcceptorState != want.AcceptorState ||
+			export.CurrentState != want.CurrentState || len(export.Rounds) != len(want.Rounds) {
+			t.Errorf("Bad Exit: \"TestExportJSON\" read back %+v, expected %+v", export, want)
+			continue
+		}
+		for i, round := range export.Rounds {
+			if got, expected := transitions(round), transitions(want.Rounds[i]); got != expected {
+				t.Errorf("Bad Exit: \"TestExportJSON\" read back transitions %v, expected %v", got, expected)
+			}
+		}
+	}
+}
+
+func TestRenderTimeline(t *testing.T) {
+	proposer, acceptor := trackedWrite("a")
+	var buf bytes.Buffer
+	err := timeline.Render(&buf, []paxostracker.Export{acceptor.Export(), proposer.Export()})
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestRenderTimeline\" produced err: %v", err)
+	}
+	page := buf.String()
+	var tests = []struct {
+		Text  string
+		Count int
+	}{
+		// a lane per node, with a row per role
+		{`<div class="node">127.0.0.1:12383</div>`, 1},
+		{`<div class="node">127.0.0.1:12384</div>`, 1},
+		{`<div class="role">Proposer</div>`, 2},
+		{`<div class="role">Acceptor</div>`, 2},
+		// a segment per state each round passed through
+		{`class="seg Preparing"`, 1},
+		{`class="seg Proposing"`, 1},
+		{`class="seg Learning"`, 1},
+		{`class="seg Promised"`, 1},
+		{`class="seg Accepted"`, 1},
+		{`class="seg Idle"`, 4},
+		// the proposer is named by its address, as its export is one of those rendered
+		{`(127.0.0.1:12383, value &#39;a&#39;)`, 9},
+	}
+	for _, test := range tests {
+		if count := strings.Count(page, test.Text); count != test.Count {
+			t.Errorf("Bad Exit: \"TestRenderTimeline\" rendered %q %d times, expected %d", test.Text, count, test.Count)
+		}
+	}
+	// lanes are in the order of the nodes' addresses
+	if strings.Index(page, "127.0.0.1:12383</div>") > strings.Index(page, "127.0.0.1:12384</div>") {
+		t.Errorf("Bad Exit: \"TestRenderTimeline\" did not sort the lanes by node")
+	}
+}
@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"consensuslib"
+	"consensuslib/transport"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"distributeddiaryapp/tests/util"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA signs certificates for the TLS tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string
+}
+
+// newTestCA kept in dir as name.pem
+func newTestCA(dir string, name string) (*testCA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	file := filepath.Join(dir, name+".pem")
+	return &testCA{cert, key, file}, writePEM(file, "CERTIFICATE", der)
+}
+
+// issue a certificate naming ip, returning the transport config using it
+func (ca *testCA) issue(dir string, name string, ip string) (*transport.Config, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP(ip)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, err
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	config := &transport.Config{
+		CAFile:   ca.file,
+		CertFile: filepath.Join(dir, name+".pem"),
+		KeyFile:  filepath.Join(dir, name+".key"),
+	}
+	if err = writePEM(config.CertFile, "CERTIFICATE", der); err != nil {
+		return nil, err
+	}
+	return config, writePEM(config.KeyFile, "EC PRIVATE KEY", keyDer)
+}
+
+func writePEM(file string, blockType string, der []byte) error {
+	return ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+}
+
+func TestMutualTLS(t *testing.T) {
+	serverAddr := "127.0.0.1:12366"
+	dir, err := ioutil.TempDir("", "diary-tls")
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestMutualTLS\" produced err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	ca, err := newTestCA(dir, "ca")
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestMutualTLS\" produced err: %v", err)
+	}
+	otherCA, err := newTestCA(dir, "otherca")
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestMutualTLS\" produced err: %v", err)
+	}
+	certs := make(map[string]*transport.Config)
+	for _, c := range []struct {
+		Name string
+		CA   *testCA
+		IP   string
+	}{
+		{"server", ca, "127.0.0.1"},
+		{"node", ca, "127.0.0.1"},
+		{"stranger", otherCA, "127.0.0.1"},
+		{"impostor", ca, "10.0.0.9"},
+	} {
+		if certs[c.Name], err = c.CA.issue(dir, c.Name, c.IP); err != nil {
+			t.Fatalf("Bad Exit: \"TestMutualTLS\" produced err: %v", err)
+		}
+	}
+
+	serverConfig := consensuslib.DefaultServerConfig()
+	serverConfig.TLS = certs["server"]
+	server, err := consensuslib.NewServerWithConfig(serverAddr, nil, serverConfig)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestMutualTLS\" produced err: %v", err)
+	}
+	go server.Serve()
+
+	var tests = []struct {
+		Name  string
+		TLS   *transport.Config
+		Joins bool
+	}{
+		{"Node", certs["node"], true},
+		{"OtherNode", certs["node"], true},
+		{"PlainTCP", nil, false},
+		{"OtherCA", certs["stranger"], false},
+		{"WrongAddress", certs["impostor"], false},
+	}
+	var joined []*consensuslib.Client
+	for _, test := range tests {
+		config := util.ClientConfig()
+		config.HeartbeatRate = 10 * time.Millisecond
+		config.TLS = test.TLS
+		client, err := consensuslib.NewClientWithConfig("127.0.0.1:0", "", config)
+		if err != nil {
+			t.Fatalf("Bad Exit: %s: produced err: %v", test.Name, err)
+		}
+		err = client.Connect(serverAddr)
+		if (err == nil) != test.Joins {
+			t.Errorf("Bad Exit: %s: expected joining to be %v, got err: %v", test.Name, test.Joins, err)
+		}
+		if err == nil {
+			joined = append(joined, client)
+		}
+	}
+	if len(joined) != 2 {
+		t.Fatalf("Bad Exit: expected 2 nodes to join, got %d", len(joined))
+	}
+
+	// the nodes reach each other over TLS too
+	if err = joined[0].Write("secret"); err != nil {
+		t.Fatalf("Bad Exit: \"TestMutualTLS\" produced err: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if value, err := joined[1].Read(); err != nil || value != "secret\n" {
+		t.Errorf("Bad Exit: expected to read %q, got %q, err: %v", "secret\n", value, err)
+	}
+	inspector, err := consensuslib.NewInspectorWithTLS(certs["node"], serverAddr)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestMutualTLS\" produced err: %v", err)
+	}
+	if members, err := inspector.Members(); err != nil || len(members) != 2 {
+		t.Errorf("Bad Exit: expected 2 members, got %v, err: %v", members, err)
+	}
+	if _, err = consensuslib.NewInspector(serverAddr); err == nil {
+		t.Errorf("Bad Exit: expected inspecting without TLS to fail")
+	}
+
+	// a node with a valid certificate can only act for itself
+	node, err := transport.New(certs["node"], nil)
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestMutualTLS\" produced err: %v", err)
+	}
+	var left bool
+	err = node.As("127.0.0.1:1").Call(serverAddr, time.Second, "Server.Leave", joined[0].Addr(), &left)
+	if err == nil || left {
+		t.Errorf("Bad Exit: expected leaving for another node to be refused")
+	}
+	if members, err := inspector.Members(); err != nil || len(members) != 2 {
+		t.Errorf("Bad Exit: expected 2 members, got %v, err: %v", members, err)
+	}
+	err = node.As("10.0.0.9:1").Call(serverAddr, time.Second, "Server.CheckAlive", "placeholder", &left)
+	if err == nil {
+		t.Errorf("Bad Exit: expected declaring an address the certificate does not name to be refused")
+	}
+}
@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"bufio"
+	"consensuslib"
+	"distributeddiaryapp/tests/util"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// exportedSpan is the part of an OpenTelemetry JSON export request the test checks
+type exportedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Kind         int    `json:"kind"`
+}
+
+func readSpans(t *testing.T, path string) (spans []exportedSpan) {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Bad Exit: unable to open spans: %s", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var request struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []exportedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			t.Fatalf("Bad Exit: %s is not an export request: %s", scanner.Text(), err)
+		}
+		for _, rs := range request.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}
+	return spans
+}
+
+func TestTraceWriteAcrossNodes(t *testing.T) {
+	serverAddr := "127.0.0.1:12351"
+	util.SetupServer(serverAddr)
+	dir, err := ioutil.TempDir("", "spans")
+	if err != nil {
+		t.Fatalf("Bad Exit: unable to create span dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	clients := make([]*consensuslib.Client, 0)
+	for _, name := range []string{"a.json", "b.json"} {
+		config := util.ClientConfig()
+		config.SpanFile = filepath.Join(dir, name)
+		client, err := consensuslib.NewClientWithConfig("127.0.0.1:0", "", config)
+		if err == nil {
+			err = client.Connect(serverAddr)
+		}
+		if err != nil {
+			t.Fatalf("Bad Exit: \"TestTraceWriteAcrossNodes\" produced err: %v", err)
+		}
+		clients = append(clients, client)
+	}
+	clients[0].Write("traced")
+	time.Sleep(100 * time.Millisecond)
+	// leaving closes the span files
+	for _, client := range clients {
+		if err = client.Leave(); err != nil {
+			t.Errorf("Bad Exit: \"TestTraceWriteAcrossNodes\" produced err: %v", err)
+		}
+	}
+
+	spans := append(readSpans(t, filepath.Join(dir, "a.json")), readSpans(t, filepath.Join(dir, "b.json"))...)
+	byID := make(map[string]exportedSpan)
+	count := make(map[string]int)
+	var root exportedSpan
+	for _, span := range spans {
+		byID[span.SpanID] = span
+		count[span.Name]++
+		if span.Name == "paxos.write" {
+			root = span
+		}
+	}
+	var tests = []struct {
+		Name  string
+		Count int
+	}{
+		{"paxos.write", 1},
+		{"paxos.prepare", 1},
+		{"paxos.accept", 1},
+		{"paxos.learn", 2},
+		{"PaxosNodeRPCWrapper.ProcessPrepareRequest", 2},
+		{"PaxosNodeRPCWrapper.ProcessAcceptRequest", 2},
+		{"PaxosNodeRPCWrapper.NotifyAboutAccepted", 4},
+	}
+	for _, test := range tests {
+		if count[test.Name] != test.Count {
+			t.Errorf("Bad Exit: %d %s spans, want %d", count[test.Name], test.Name, test.Count)
+		}
+	}
+	for _, span := range spans {
+		if span.TraceID != root.TraceID {
+			t.Errorf("Bad Exit: %s is in trace %s, not the write's %s", span.Name, span.TraceID, root.TraceID)
+		}
+		if span.SpanID == root.SpanID {
+			continue
+		}
+		parent, ok := byID[span.ParentSpanID]
+		if !ok {
+			t.Errorf("Bad Exit: %s has no parent among the exported spans", span.Name)
+		}
+		if span.Kind == 2 && (parent.Kind != 3 || parent.Name != span.Name) {
+			t.Errorf("Bad Exit: server span %s is not a child of its client span, but of %s", span.Name, parent.Name)
+		}
+	}
+}
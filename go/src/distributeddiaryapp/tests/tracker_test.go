@@ -0,0 +1,198 @@
+package tests
+
+import (
+	"consensuslib/message"
+	"consensuslib/paxosnode/learner"
+	"fmt"
+	"paxostracker"
+	"paxostracker/state"
+	"testing"
+	"time"
+)
+
+// passiveRounds describes the acceptor rounds of an export, as round:value:last state
+func passiveRounds(export paxostracker.Export) []string {
+	rounds := make([]string, 0)
+	for _, round := range export.Rounds {
+		if round.Role != paxostracker.ACCEPTOR {
+			continue
+		}
+		last := round.Transitions[len(round.Transitions)-1].State
+		rounds = append(rounds, fmt.Sprintf("%d:%s:%s", round.RoundNum, round.Value, last))
+	}
+	return rounds
+}
+
+func TestTrackerPassiveRounds(t *testing.T) {
+	var tests = []struct {
+		Name   string
+		Events func(tracker *paxostracker.PaxosTracker) error
+		State  state.PaxosState
+		Rounds []string
+	}{
+		{
+			Name: "happy path",
+			Events: func(tracker *paxostracker.PaxosTracker) error {
+				tracker.Promise("n1", 1, 0)
+				tracker.Accept("n1", 1, 0)
+				return tracker.Idle("a")
+			},
+			State:  state.Idle,
+			Rounds: []string{"0:a:Idle"},
+		},
+		{
+			Name: "promise after accepting",
+			Events: func(tracker *paxostracker.PaxosTracker) error {
+				tracker.Promise("n1", 1, 0)
+				tracker.Accept("n1", 1, 0)
+				return tracker.Promise("n2", 2, 0)
+			},
+			State:  state.Promised,
+			Rounds: []string{"0::Promised"},
+		},
+		{
+			Name: "accept a higher proposal",
+			Events: func(tracker *paxostracker.PaxosTracker) error {
+				tracker.Accept("n1", 1, 0)
+				return tracker.Accept("n2", 2, 0)
+			},
+			State:  state.Accepted,
+			Rounds: []string{"0::Accepted"},
+		},
+		{
+			Name: "promise in a later round",
+			Events: func(tracker *paxostracker.PaxosTracker) error {
+				tracker.Promise("n1", 1, 0)
+				tracker.Accept("n1", 1, 0)
+				return tracker.Promise("n2", 2, 1)
+			},
+			State:  state.Promised,
+			Rounds: []string{"0::Idle", "1::Promised"},
+		},
+		{
+			Name: "caught up on the round",
+			Events: func(tracker *paxostracker.PaxosTracker) error {
+				tracker.Promise("n1", 1, 0)
+				tracker.Accept("n1", 1, 0)
+				return tracker.CaughtUp(0, "a")
+			},
+			State:  state.Idle,
+			Rounds: []string{"0:a:Idle"},
+		},
+		{
+			Name: "caught up past the round",
+			Events: func(tracker *paxostracker.PaxosTracker) error {
+				tracker.Promise("n1", 1, 2)
+				return tracker.CaughtUp(3, "d")
+			},
+			State:  state.Idle,
+			Rounds: []string{"2::Idle"},
+		},
+		{
+			Name: "caught up on an earlier round",
+			Events: func(tracker *paxostracker.PaxosTracker) error {
+				tracker.Promise("n1", 1, 2)
+				return tracker.CaughtUp(1, "b")
+			},
+			State:  state.Promised,
+			Rounds: []string{"2::Promised"},
+		},
+	}
+	for _, test := range tests {
+		tracker := paxostracker.NewPaxosTracker("127.0.0.1:12380")
+		if err := test.Events(tracker); err != nil {
+			t.Errorf("Bad Exit: \"TestTrackerPassiveRounds(%s)\" produced err: %v", test.Name, err)
+			continue
+		}
+		export := tracker.Export()
+		if export.AcceptorState != test.State {
+			t.Errorf("Bad Exit: \"TestTrackerPassiveRounds(%s)\" left the acceptor %v, expected %v", test.Name, export.AcceptorState, test.State)
+		}
+		if rounds := passiveRounds(export); fmt.Sprint(rounds) != fmt.Sprint(test.Rounds) {
+			t.Errorf("Bad Exit: \"TestTrackerPassiveRounds(%s)\" produced rounds %v, expected %v", test.Name, rounds, test.Rounds)
+		}
+	}
+}
+
+func TestLearnerEndsPassiveRound(t *testing.T) {
+	a := message.NewMessage(1, "aaaa", message.ACCEPT, "a", "n1", 0, 3)
+	b := message.NewMessage(2, "bbbb", message.ACCEPT, "b", "n1", 1, 3)
+	tracker := paxostracker.NewPaxosTracker("127.0.0.1:12380")
+	l := learner.NewLearner("n0", tracker)
+
+	// catching up on a round the acceptor took part in ends it
+	tracker.Accept("n1", 1, 0)
+	l.InitializeLog([]message.Message{a})
+	// as does learning a value that was already caught up on
+	tracker.Accept("n1", 2, 1)
+	l.InitializeLog([]message.Message{a, b})
+	tracker.Accept("n1", 2, 1)
+	l.LearnValue(&b)
+
+	want := []string{"0:a:Idle", "1:b:Idle", "1:b:Idle"}
+	export := tracker.Export()
+	if rounds := passiveRounds(export); fmt.Sprint(rounds) != fmt.Sprint(want) || export.AcceptorState != state.Idle {
+		t.Errorf("Bad Exit: \"TestLearnerEndsPassiveRound\" produced rounds %v in state %v, expected %v", rounds, export.AcceptorState, want)
+	}
+}
+
+func TestTrackersIndependent(t *testing.T) {
+	first := paxostracker.NewPaxosTracker("127.0.0.1:12381")
+	second := paxostracker.NewPaxosTracker("127.0.0.1:12382")
+	first.AddBreakpoint(paxostracker.Breakpoint{Stage: paxostracker.PREPARE})
+	done := make(chan struct{})
+	go func() {
+		first.Prepare("n0", "a", 0)
+		close(done)
+	}()
+	waitPaused(t, first, paxostracker.PREPARE)
+
+	// a breakpoint on one node's tracker does not stop another node in the same process
+	second.Prepare("n1", "b", 0)
+	second.Propose(1, "b", 0)
+	second.Learn(1, "b", 0)
+	second.Idle("b")
+	if second.Paused() != "" || len(second.Breakpoints()) != 0 {
+		t.Errorf("Bad Exit: \"TestTrackersIndependent\" paused the second tracker at %q", second.Paused())
+	}
+	if err := first.Continue(); err != nil {
+		t.Fatalf("Bad Exit: \"TestTrackersIndependent\" produced err: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Bad Exit: \"TestTrackersIndependent\" never resumed the round")
+	}
+
+	for _, test := range []struct {
+		Tracker *paxostracker.PaxosTracker
+		Want    []string
+	}{
+		{first, []string{"n0:a:Preparing"}},
+		{second, []string{"n1:b:Idle"}},
+	} {
+		rounds := make([]string, 0)
+		for _, round := range test.Tracker.Export().Rounds {
+			last := round.Transitions[len(round.Transitions)-1].State
+			rounds = append(rounds, fmt.Sprintf("%s:%s:%s", round.Proposer, round.Value, last))
+		}
+		if fmt.Sprint(rounds) != fmt.Sprint(test.Want) {
+			t.Errorf("Bad Exit: \"TestTrackersIndependent\" tracked rounds %v, expected %v", rounds, test.Want)
+		}
+	}
+}
+
+func TestTrackerMaxRounds(t *testing.T) {
+	tracker := paxostracker.NewPaxosTracker("127.0.0.1:12380")
+	tracker.SetMaxRounds(2)
+	for round, value := range []string{"a", "b", "c"} {
+		tracker.Promise("n1", 1, round)
+		tracker.Accept("n1", 1, round)
+		tracker.Idle(value)
+	}
+	// only the latest rounds are kept
+	want := []string{"1:b:Idle", "2:c:Idle"}
+	if rounds := passiveRounds(tracker.Export()); fmt.Sprint(rounds) != fmt.Sprint(want) {
+		t.Errorf("Bad Exit: \"TestTrackerMaxRounds\" kept rounds %v, expected %v", rounds, want)
+	}
+}
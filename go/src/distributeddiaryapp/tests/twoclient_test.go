@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"consensuslib"
 	"distributeddiaryapp/tests/util"
 	"testing"
 	"time"
@@ -24,119 +25,75 @@ func TwoTests() []TestTwoData {
 	}
 }
 
-func TestTwoReadOneWrite(t *testing.T) {
-	serverAddr := "127.0.0.1:12345"
-	localPort := "0"
-	util.SetupServer(serverAddr)
-	for _, test := range TwoTests() {
-		client0, err := util.SetupClient(serverAddr, localPort)
+// checkLog fails the test unless every client reads the log want, in time
+func checkLog(t *testing.T, name string, clients []*consensuslib.Client, want string) {
+	for i, client := range clients {
+		value, err := util.WaitForLog(client, want, time.Second)
 		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadOneWrite(%v)\" produced err: %v", test, err)
+			t.Errorf("Bad Exit: \"%s\" produced err: %v", name, err)
 		}
-		client1, err := util.SetupClient(serverAddr, localPort)
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadOneWrite(%v)\" produced err: %v", test, err)
+		if value != want {
+			t.Errorf("Bad Exit: Read Data '%s' for Client %d does not match written data '%s'", value, i, want)
 		}
+	}
+}
 
-		// C0 Writes
-		err = client0.Write(test.DataC0)
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadOneWrite(%v)\" produced err: %v", test, err)
-		}
-		// C0 Reads
-		value, err := client0.Read()
+// setupClients joins n clients to the server's network
+func setupClients(t *testing.T, name string, serverAddr string, n int) []*consensuslib.Client {
+	clients := make([]*consensuslib.Client, n)
+	for i := range clients {
+		client, err := util.SetupClient(serverAddr, "127.0.0.1:0")
 		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadOneWrite(%v)\" produced err: %v", test, err)
+			t.Fatalf("Bad Exit: \"%s\" produced err: %v", name, err)
 		}
+		clients[i] = client
+	}
+	return clients
+}
 
-		// Can C0 see it's own value?
-		if value != test.DataC0 {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 0 does not match written data '%s'", value, test.DataC0)
-		}
+func TestTwoReadOneWrite(t *testing.T) {
+	serverAddr := "127.0.0.1:12373"
+	util.SetupServer(serverAddr)
+	// every client joins the same network, so each reads the values written before it joined too
+	log := ""
+	for _, test := range TwoTests() {
+		clients := setupClients(t, "TestTwoReadOneWrite", serverAddr, 2)
 
-		// C1 Reads
-		value, err = client1.Read()
+		// C0 Writes
+		err := clients[0].Write(test.DataC0)
 		if err != nil {
 			t.Errorf("Bad Exit: \"TestTwoReadOneWrite(%v)\" produced err: %v", test, err)
 		}
+		log += test.DataC0 + "\n"
 
-		// Can C1 see C0's value?
-		if value != test.DataC0 {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 1 does not match written data '%s'", value, test.DataC0)
-		}
-		time.Sleep(5 * time.Millisecond)
+		// Can C0 see its own value, and C1 see C0's?
+		checkLog(t, "TestTwoReadOneWrite", clients, log)
 	}
 }
 
 func TestTwoReadTwoWrite(t *testing.T) {
-	serverAddr := "127.0.0.1:12345"
-	localPort := "127.0.0.1:0"
+	serverAddr := "127.0.0.1:12374"
 	util.SetupServer(serverAddr)
+	log := ""
 	for _, test := range TwoTests() {
-		client0, err := util.SetupClient(serverAddr, localPort)
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-		client1, err := util.SetupClient(serverAddr, localPort)
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
+		clients := setupClients(t, "TestTwoReadTwoWrite", serverAddr, 2)
 
 		// C0 Writes
-		err = client0.Write(test.DataC0)
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-		// C0 Reads
-		value, err := client0.Read()
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-
-		// Can C0 see it's own value?
-		if value != test.DataC0 {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 0 does not match written data '%s'", value, test.DataC0)
-		}
-
-		// C1 Reads
-		value, err = client1.Read()
+		err := clients[0].Write(test.DataC0)
 		if err != nil {
 			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
 		}
-
-		// Can C1 see C0's value?
-		if value != test.DataC0 {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 1 does not match written data '%s'", value, test.DataC0)
-		}
+		log += test.DataC0 + "\n"
+		checkLog(t, "TestTwoReadTwoWrite", clients, log)
 
 		// C1 Writes
-		err = client1.Write(test.DataC1)
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-
-		// C1 Reads
-		value, err = client1.Read()
-		if err != nil {
-			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
-		}
-
-		// Can C1 see the combined log?
-		combinedData := test.DataC0 + " " + test.DataC1
-		if value != combinedData {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 1 does not match written data '%s'", value, combinedData)
-		}
-
-		// C0 Reads
-		value, err = client0.Read()
+		err = clients[1].Write(test.DataC1)
 		if err != nil {
 			t.Errorf("Bad Exit: \"TestTwoReadTwoWrite(%v)\" produced err: %v", test, err)
 		}
+		log += test.DataC1 + "\n"
 
-		// Can C0 see the combined log?
-		if value != combinedData {
-			t.Errorf("Bad Exit: Read Data '%s' for Client 0 does not match written data '%s'", value, combinedData)
-		}
-		time.Sleep(5 * time.Millisecond)
+		// Can both see the combined log?
+		checkLog(t, "TestTwoReadTwoWrite", clients, log)
 	}
 }
@@ -2,7 +2,14 @@ package util
 
 import (
 	"consensuslib"
-	"strconv"
+	"consensuslib/safety"
+	"filelogger/logger"
+	"filelogger/singletonlogger"
+	"filelogger/state"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -10,9 +17,48 @@ const (
 	HEARTBEAT_INTERVAL = 1 * time.Millisecond
 )
 
-func SetupClient(serverAddr string, localPort string) (client *consensuslib.Client, err error) {
-	intPort, err := strconv.Atoi(localPort)
-	client, err = consensuslib.NewClient(intPort, HEARTBEAT_INTERVAL)
+// LogDir the tests log to
+var LogDir = filepath.Join(os.TempDir(), "distributeddiary-tests")
+
+// DataDir the tests' nodes keep their IDs and backups in, each in a directory of its own
+var DataDir = filepath.Join(os.TempDir(), "distributeddiary-tests-data")
+
+func init() {
+	// no node resumes from the state of an earlier run
+	os.RemoveAll(DataDir)
+	// keep the test output readable, and the logs out of the source tree
+	singletonlogger.NewSingletonLoggerWithConfig("tests", state.QUIET, logger.Config{
+		Dir:      LogDir,
+		MaxSize:  10 << 20,
+		Compress: true,
+		MaxFiles: 5,
+	})
+}
+
+// NewDataDir no other node keeps its state in
+func NewDataDir() string {
+	os.MkdirAll(DataDir, os.ModePerm)
+	dir, err := ioutil.TempDir(DataDir, "node")
+	if err != nil {
+		panic(err)
+	}
+	return dir
+}
+
+// ClientConfig of a node that heartbeats every HEARTBEAT_INTERVAL and starts from an empty data directory
+func ClientConfig() consensuslib.ClientConfig {
+	config := consensuslib.DefaultClientConfig()
+	config.HeartbeatRate = HEARTBEAT_INTERVAL
+	config.Node.DataDir = NewDataDir()
+	return config
+}
+
+func SetupClient(serverAddr string, localAddr string) (client *consensuslib.Client, err error) {
+	if !strings.Contains(localAddr, ":") {
+		// a bare port listens on the loopback address
+		localAddr = "127.0.0.1:" + localAddr
+	}
+	client, err = consensuslib.NewClientWithConfig(localAddr, "", ClientConfig())
 	if err != nil {
 		return nil, err
 	}
@@ -31,3 +77,34 @@ func SetupServer(serverAddr string) (err error) {
 	go server.Serve()
 	return nil
 }
+
+// CheckSafety gathers the learner log of every client's paxos node and returns any safety violations
+func CheckSafety(clients []*consensuslib.Client) (violations []safety.Violation, err error) {
+	addrs := make([]string, 0, len(clients))
+	for _, client := range clients {
+		addrs = append(addrs, client.Addr())
+	}
+	snapshot, err := safety.GatherFrom(nil, addrs)
+	if err != nil {
+		return nil, err
+	}
+	return safety.Check(snapshot), nil
+}
+
+// WaitForLog waits up to timeout for the client to have learned the log want, as Read returns it, since the
+// values a client did not propose itself reach it after the write returns. It returns the log read last.
+func WaitForLog(client *consensuslib.Client, want string, timeout time.Duration) (log string, err error) {
+	return WaitUntil(client, func(log string) bool { return log == want }, timeout)
+}
+
+// WaitUntil waits up to timeout for the log the client reads to satisfy done, and returns the log read last
+func WaitUntil(client *consensuslib.Client, done func(log string) bool, timeout time.Duration) (log string, err error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		log, err = client.Read()
+		if err != nil || done(log) || time.Now().After(deadline) {
+			return log, err
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"filelogger/logger"
+	"filelogger/state"
+	"filelogger/vclock"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestVectorClockMerge(t *testing.T) {
+	var tests = []struct {
+		Name     string
+		Local    vclock.VClock
+		Received vclock.VClock
+		Want     string
+	}{
+		{
+			Name:     "first message",
+			Local:    vclock.VClock{"n0": 1},
+			Received: vclock.VClock{"n1": 2},
+			Want:     `{"n0":2, "n1":2}`,
+		},
+		{
+			Name:     "received is behind",
+			Local:    vclock.VClock{"n0": 4, "n1": 3},
+			Received: vclock.VClock{"n1": 1},
+			Want:     `{"n0":5, "n1":3}`,
+		},
+		{
+			Name:     "no clock piggybacked",
+			Local:    vclock.VClock{"n0": 1},
+			Received: nil,
+			Want:     `{"n0":2}`,
+		},
+	}
+	for _, test := range tests {
+		sent := test.Received.Copy()
+		test.Local.Merge(test.Received)
+		test.Local.Tick("n0")
+		if test.Local.String() != test.Want {
+			t.Errorf("Bad Exit: %q: got clock %s, want %s", test.Name, test.Local, test.Want)
+		}
+		if sent.String() != test.Received.String() {
+			t.Errorf("Bad Exit: %q: merging changed the received clock to %s", test.Name, test.Received)
+		}
+	}
+}
+
+func TestVectorClockPerNode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vclock")
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestVectorClockPerNode\" produced err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	l, err := logger.NewFileLoggerWithConfig("vclocklogs", state.QUIET, logger.Config{Dir: dir})
+	if err != nil {
+		t.Fatalf("Bad Exit: \"TestVectorClockPerNode\" produced err: %v", err)
+	}
+	defer l.Exit()
+	// two nodes in one process, such as in a test, keep a clock each
+	for _, id := range []string{"n0", "n1"} {
+		if err = l.EnableVectorClock(id); err != nil {
+			t.Fatalf("Bad Exit: \"TestVectorClockPerNode\" produced err: %v", err)
+		}
+	}
+	if err = l.EnableVectorClock("n0"); err == nil {
+		t.Errorf("Bad Exit: \"TestVectorClockPerNode\" enabled the clock of n0 twice")
+	}
+	sent := l.PrepareSend("n0", "send")
+	if want := `{"n0":2}`; sent.String() != want {
+		t.Errorf("Bad Exit: \"TestVectorClockPerNode\" sent clock %s, want %s", sent, want)
+	}
+	l.UnpackReceive("n1", "receive", sent)
+	received := l.PrepareSend("n1", "reply")
+	if want := `{"n0":2, "n1":3}`; received.String() != want {
+		t.Errorf("Bad Exit: \"TestVectorClockPerNode\" replied with clock %s, want %s", received, want)
+	}
+	if clock := l.PrepareSend("n2", "send"); clock != nil {
+		t.Errorf("Bad Exit: \"TestVectorClockPerNode\" sent clock %s from a node without one", clock)
+	}
+}
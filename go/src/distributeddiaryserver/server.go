@@ -12,19 +12,38 @@ package main
 
 import (
 	"consensuslib"
+	"consensuslib/admission"
+	"consensuslib/phiaccrual"
+	"consensuslib/transport"
+	"distributeddiaryapp/networking"
+	"filelogger/logger"
 	"filelogger/singletonlogger"
 	"filelogger/state"
 	"fmt"
+	"net"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
-	localFlag = "--local"
-	debugFlag = "--debug"
-	usage     = `==================================================
+	localFlag          = "--local"
+	debugFlag          = "--debug"
+	logDirFlag         = "--logdir"
+	metricsFlag        = "--metrics"
+	replicasFlag       = "--replicas"
+	timeoutFlag        = "--timeout"
+	phiFlag            = "--phi"
+	advertiseAddrFlag  = "--advertise-addr"
+	advertiseIfaceFlag = "--advertise-iface"
+	advertiseCIDRFlag  = "--advertise-cidr"
+	tlsCAFlag          = "--tls-ca"
+	tlsCertFlag        = "--tls-cert"
+	tlsKeyFlag         = "--tls-key"
+	clusterKeyFlag     = "--cluster-key"
+	usage              = `==================================================
 The Chamber of Secrets: A Distributed Diary Server
 ==================================================
 Usage: go run server.go PORT [options]
@@ -33,40 +52,68 @@ Valid options:
 
 --local : run on local machine at 127.0.0.1 with the specified port
 --debug : run with debuggging turned on for verbose logging
+--logdir=DIR : write logs to DIR instead of logs/. Logs are rotated daily or every 10MB, and kept for a week
+--metrics=ADDR : serve Prometheus metrics about the registered nodes at http://ADDR/metrics
+  With --tls-*, they are served over mutual TLS instead. --cluster-key does not cover them, so without TLS
+  give ADDR on a private interface
+--replicas=ADDR,ADDR : replicate the registered nodes with the servers at ADDR,ADDR, which are each given this
+  server's address in turn. The first server by address that is alive is the primary, and the rest take over if it dies
+--timeout=DURATION : drop nodes that go DURATION without a heartbeat, such as 500ms or 5s. Defaults to 2s
+--phi : drop nodes once a phi accrual detector suspects them instead, waiting longer for nodes whose heartbeats
+  are slow or irregular, so a slow network does not get nodes dropped
+--advertise-addr=IP : with --replicas, the address the replicas know this server by
+--advertise-iface=NAME : with --replicas, the replicas know this server by the address of the network interface NAME
+--advertise-cidr=CIDR : with --replicas, the replicas know this server by its address in the network CIDR
+  Without any of these, the address is read from $DIARY_ADVERTISE_ADDR or $POD_IP, or else is the address of the
+  interface the default route goes through
+--tls-ca=FILE --tls-cert=FILE --tls-key=FILE : require mutual TLS from the nodes and the replicas, with the PEM
+  certificate and key of this server signed by the CA in FILE. The certificate must name the address the nodes
+  reach this server at
+--cluster-key=FILE : only serve nodes, inspectors and replicas that sign their connections with the key shared
+  by the cluster in FILE
 `
 )
 
-var validArgs = regexp.MustCompile("[0-9]{1,5}( " + localFlag + ")*( " + debugFlag + ")*")
+var validArgs = regexp.MustCompile("[0-9]{1,5}( " + localFlag + ")*( " + debugFlag + ")*( " + logDirFlag + "=\\S+)*( " + metricsFlag + "=\\S+)*( " + replicasFlag + "=\\S+)*( " + timeoutFlag + "=\\S+)*( " + phiFlag + ")*( " + advertiseAddrFlag + "=\\S+)*( " + advertiseIfaceFlag + "=\\S+)*( " + advertiseCIDRFlag + "=\\S+)*( " + tlsCAFlag + "=\\S+)*( " + tlsCertFlag + "=\\S+)*( " + tlsKeyFlag + "=\\S+)*( " + clusterKeyFlag + "=\\S+)*")
 
 func main() {
-	addr, logstate, err := parseArgs(os.Args[1:])
+	addr, logstate, logdir, metricsAddr, replicas, config, err := parseArgs(os.Args[1:])
 	checkError(err)
-	err = singletonlogger.NewSingletonLogger("server", logstate)
+	err = singletonlogger.NewSingletonLoggerWithConfig("server", logstate, logger.ServiceConfig(logdir))
 	checkError(err)
 	singletonlogger.Debug("Logger created")
 	singletonlogger.Debug("Chosen Addr: " + addr)
 	singletonlogger.Debug("Creating consensuslib server for " + addr)
-	server, err := consensuslib.NewServer(addr)
+	server, err := consensuslib.NewServerWithConfig(addr, replicas, config)
 	checkError(err)
+	if metricsAddr != "" {
+		metricsAddr, err = server.ServeMetrics(metricsAddr)
+		checkError(err)
+		singletonlogger.Info("Serving metrics at http://" + metricsAddr + "/metrics")
+	}
 	singletonlogger.Info("Serving at " + addr)
 	err = server.Serve()
 	checkError(err)
 }
 
-func parseArgs(args []string) (addr string, logstate state.State, err error) {
+func parseArgs(args []string) (addr string, logstate state.State, logdir string, metricsAddr string, replicas []string, config consensuslib.ServerConfig, err error) {
 	if !validArgs.MatchString(strings.Join(args, " ")) {
 		fmt.Println(usage)
 		os.Exit(1)
 	}
 	port := 0
 	isLocal := false
+	var advertise networking.Options
+	var tls transport.Config
+	logdir = logger.DefaultConfig().Dir
+	config = consensuslib.DefaultServerConfig()
 	for i, arg := range args {
 		// positional args
 		switch i {
 		case 0:
 			port, err = strconv.Atoi(args[i])
 			if err != nil {
-				return addr, logstate, fmt.Errorf("error while converting port: %s", err)
+				return addr, logstate, logdir, metricsAddr, replicas, config, fmt.Errorf("error while converting port: %s", err)
 			}
 		default:
 			// option flags
@@ -75,16 +122,73 @@ func parseArgs(args []string) (addr string, logstate state.State, err error) {
 				isLocal = true
 			case debugFlag:
 				logstate = state.DEBUGGING
+			case phiFlag:
+				detector := phiaccrual.DefaultConfig()
+				config.Detector = &detector
+			default:
+				if strings.HasPrefix(arg, logDirFlag+"=") {
+					logdir = strings.TrimPrefix(arg, logDirFlag+"=")
+				}
+				if strings.HasPrefix(arg, metricsFlag+"=") {
+					metricsAddr = strings.TrimPrefix(arg, metricsFlag+"=")
+				}
+				if strings.HasPrefix(arg, replicasFlag+"=") {
+					replicas = strings.Split(strings.TrimPrefix(arg, replicasFlag+"="), ",")
+				}
+				if strings.HasPrefix(arg, advertiseAddrFlag+"=") {
+					advertise.Addr = strings.TrimPrefix(arg, advertiseAddrFlag+"=")
+				}
+				if strings.HasPrefix(arg, advertiseIfaceFlag+"=") {
+					advertise.Interface = strings.TrimPrefix(arg, advertiseIfaceFlag+"=")
+				}
+				if strings.HasPrefix(arg, advertiseCIDRFlag+"=") {
+					advertise.CIDR = strings.TrimPrefix(arg, advertiseCIDRFlag+"=")
+				}
+				if strings.HasPrefix(arg, tlsCAFlag+"=") {
+					tls.CAFile = strings.TrimPrefix(arg, tlsCAFlag+"=")
+				}
+				if strings.HasPrefix(arg, tlsCertFlag+"=") {
+					tls.CertFile = strings.TrimPrefix(arg, tlsCertFlag+"=")
+				}
+				if strings.HasPrefix(arg, tlsKeyFlag+"=") {
+					tls.KeyFile = strings.TrimPrefix(arg, tlsKeyFlag+"=")
+				}
+				if strings.HasPrefix(arg, clusterKeyFlag+"=") {
+					config.ClusterKey, err = admission.LoadKey(strings.TrimPrefix(arg, clusterKeyFlag+"="))
+					if err != nil {
+						return addr, logstate, logdir, metricsAddr, replicas, config, err
+					}
+				}
+				if strings.HasPrefix(arg, timeoutFlag+"=") {
+					config.HeartbeatTimeout, err = time.ParseDuration(strings.TrimPrefix(arg, timeoutFlag+"="))
+					if err != nil {
+						return addr, logstate, logdir, metricsAddr, replicas, config, fmt.Errorf("error while parsing the timeout: %s", err)
+					}
+				}
 			}
 		}
 	}
+	if tls != (transport.Config{}) {
+		config.TLS = &tls
+	}
+	if _, _, err := net.SplitHostPort(advertise.Addr); err == nil {
+		// the server listens on the address itself, so a mapped port cannot be advertised
+		return addr, logstate, logdir, metricsAddr, replicas, config, fmt.Errorf("%s takes an IP without a port", advertiseAddrFlag)
+	}
 	addrEnd := fmt.Sprintf(":%d", port)
 	if isLocal {
 		addr = "127.0.0.1" + addrEnd
+	} else if len(replicas) != 0 {
+		// the replicas know this server by its outbound address
+		outboundIP, err := advertise.Resolver().Resolve()
+		if err != nil {
+			return addr, logstate, logdir, metricsAddr, replicas, config, fmt.Errorf("error while fetching ip: %s", err)
+		}
+		addr = net.JoinHostPort(outboundIP, strconv.Itoa(port))
 	} else {
 		addr = addrEnd
 	}
-	return addr, logstate, nil
+	return addr, logstate, logdir, metricsAddr, replicas, config, nil
 }
 
 func checkError(err error) {
@@ -0,0 +1,11 @@
+package format
+
+// Format of the lines a logger writes to disk
+type Format int
+
+const (
+	// TEXT - Human readable lines, with fields as key=value pairs
+	TEXT Format = 0
+	// JSON - One JSON object per line, with fields as top level keys
+	JSON Format = 1
+)
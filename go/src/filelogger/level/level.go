@@ -1,5 +1,10 @@
 package level
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Level of a log statement
 type Level string
 
@@ -16,3 +21,31 @@ const (
 	// FATAL - something has gone wrong, and the application cannot continue
 	FATAL Level = "Fatal  "
 )
+
+// order of the levels, from least to most severe
+var order = []Level{DEBUG, INFO, WARNING, ERROR, FATAL}
+
+// Rank of a level in severity, where DEBUG is 0. Unknown levels rank as DEBUG.
+func Rank(l Level) int {
+	for i, o := range order {
+		if o == l {
+			return i
+		}
+	}
+	return 0
+}
+
+// Name of a level, without padding and in lower case, as accepted by Parse
+func Name(l Level) string {
+	return strings.ToLower(strings.TrimSpace(string(l)))
+}
+
+// Parse a level name such as "debug" or "Warning"
+func Parse(name string) (l Level, err error) {
+	for _, o := range order {
+		if strings.EqualFold(Name(o), strings.TrimSpace(name)) {
+			return o, nil
+		}
+	}
+	return l, fmt.Errorf("unknown log level '%s'", name)
+}
@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"filelogger/level"
+	"fmt"
+	"os"
+)
+
+// Fields are key/value pairs attached to a log line, such as the node, round or ballot it is about
+type Fields map[string]interface{}
+
+// Entry logs lines tagged with a component, the subsystem they come from, and a set of fields
+type Entry struct {
+	source    func() *Logger
+	component string
+	fields    Fields
+}
+
+// NewEntry creates an entry for component, which logs to whichever logger source returns at the time of logging.
+// This lets packages declare their entries before the logger they use is created.
+func NewEntry(source func() *Logger, component string) *Entry {
+	return &Entry{source: source, component: component}
+}
+
+// Component creates an entry tagging every line with the component
+func (l *Logger) Component(component string) *Entry {
+	return NewEntry(func() *Logger { return l }, component)
+}
+
+// With creates an entry logging every line with fields
+func (l *Logger) With(fields Fields) *Entry {
+	return l.Component("").With(fields)
+}
+
+// With creates a child entry logging every line with fields as well as this entry's fields
+func (e *Entry) With(fields Fields) *Entry {
+	merged := make(Fields, len(e.fields)+len(fields))
+	for k, v := range e.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Entry{source: e.source, component: e.component, fields: merged}
+}
+
+// Log takes a level and some data to be logged with this entry's component and fields
+func (e *Entry) Log(givenLevel level.Level, data string) {
+	l := e.source()
+	if l == nil {
+		// stdout is the application's output, so the line goes to stderr
+		fmt.Fprintf(os.Stderr, "LOGGING ERROR: Logger uninitialised! %s\n", data)
+		return
+	}
+	l.write(givenLevel, e.component, e.fields, data)
+}
+
+// Debug Level log
+func (e *Entry) Debug(data string) {
+	e.Log(level.DEBUG, data)
+}
+
+// Info Level log
+func (e *Entry) Info(data string) {
+	e.Log(level.INFO, data)
+}
+
+// Warning Level log
+func (e *Entry) Warning(data string) {
+	e.Log(level.WARNING, data)
+}
+
+// Error Level log
+func (e *Entry) Error(data string) {
+	e.Log(level.ERROR, data)
+}
+
+// Fatal Level log
+func (e *Entry) Fatal(data string) {
+	e.Log(level.FATAL, data)
+}
+
+// Debugf formats according to a format specifier, then logs at Debug Level
+func (e *Entry) Debugf(format string, args ...interface{}) {
+	e.Log(level.DEBUG, fmt.Sprintf(format, args...))
+}
+
+// Infof formats according to a format specifier, then logs at Info Level
+func (e *Entry) Infof(format string, args ...interface{}) {
+	e.Log(level.INFO, fmt.Sprintf(format, args...))
+}
+
+// Warningf formats according to a format specifier, then logs at Warning Level
+func (e *Entry) Warningf(format string, args ...interface{}) {
+	e.Log(level.WARNING, fmt.Sprintf(format, args...))
+}
+
+// Errorf formats according to a format specifier, then logs at Error Level
+func (e *Entry) Errorf(format string, args ...interface{}) {
+	e.Log(level.ERROR, fmt.Sprintf(format, args...))
+}
+
+// Fatalf formats according to a format specifier, then logs at Fatal Level
+func (e *Entry) Fatalf(format string, args ...interface{}) {
+	e.Log(level.FATAL, fmt.Sprintf(format, args...))
+}
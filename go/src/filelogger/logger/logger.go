@@ -1,48 +1,65 @@
 package logger
 
 import (
+	"encoding/json"
+	"filelogger/format"
 	"filelogger/level"
 	"filelogger/state"
+	"filelogger/vclock"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
-/*
-	It would be brilliant to support a format call like in fmt.Printf
-	Currently most calls look like: logger.Info(fmt.Sprintf("this is an example %v", valuedthing))
-*/
-
 // Logger is a logger which can log to disk
 type Logger struct {
-	name  string
-	log   *log.Logger
-	file  *os.File
-	state state.State
+	name   string
+	log    *log.Logger
+	file   *rotatingFile
+	dir    string
+	state  state.State
+	format format.Format
+
+	// minimum level per component, overriding the state for that component. "" is every other component.
+	levelLock sync.RWMutex
+	levels    map[string]level.Level
+
+	// vector clock tracking of each process in this one, such as every paxos node a test runs,
+	// off until EnableVectorClock
+	clockLock sync.Mutex
+	clocks    map[string]vclock.VClock // process id to its clock
+	clockFile *os.File
 }
 
 var globalLoggers = make(map[string]*Logger)
 
-// NewFileLogger creates a new logger that may log to disk
+// NewFileLogger creates a new logger that may log to disk in logs/, without rotation
 func NewFileLogger(loggerName string, state state.State) (logger *Logger, err error) {
+	return NewFileLoggerWithConfig(loggerName, state, DefaultConfig())
+}
+
+// NewFileLoggerWithConfig creates a new logger that may log to disk in config.Dir,
+// rotating and deleting its files per config
+func NewFileLoggerWithConfig(loggerName string, state state.State, config Config) (logger *Logger, err error) {
 	if globalLoggers[loggerName] != nil {
 		return globalLoggers[loggerName], nil
 	}
-	// make logs folder if not existing already
-	err = os.MkdirAll("logs", 0700)
+	// open file for writing, making the folder if not existing already
+	f, err := openRotatingFile(loggerName, config)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create log folder: %s", err)
-	}
-	// open file for writing
-	f, err := os.Create("logs/" + loggerName + timeNow() + ".log")
-	if err != nil {
-		return nil, fmt.Errorf("unable to create log file: %s", err)
+		return nil, err
 	}
 	logger = &Logger{
 		name:  loggerName,
 		log:   log.New(os.Stderr, fmt.Sprintf("[%s] ", loggerName), log.Ltime|log.Lmicroseconds),
 		file:  f,
+		dir:   f.config.Dir,
 		state: state,
 	}
 	globalLoggers[loggerName] = logger
@@ -56,39 +73,169 @@ func GetLogger(loggerName string) (logger *Logger) {
 	}
 	logger, err := NewFileLogger(loggerName, state.NORMAL)
 	if err != nil {
-		fmt.Printf("logger error: unable to create new logger: %s", err)
+		fmt.Fprintf(os.Stderr, "logger error: unable to create new logger: %s\n", err)
 		return nil
 	}
 	return logger
 }
 
+// SetFormat of the lines written to disk from now on
+func (l *Logger) SetFormat(f format.Format) {
+	l.format = f
+}
+
+// SetLevel only logs lines from component at lvl or above, and prints them to the console unless QUIET.
+// The component "" sets the level of every component without its own.
+func (l *Logger) SetLevel(component string, lvl level.Level) {
+	l.levelLock.Lock()
+	defer l.levelLock.Unlock()
+	if l.levels == nil {
+		l.levels = make(map[string]level.Level)
+	}
+	l.levels[component] = lvl
+}
+
+// ResetLevel of component, so it logs per the logger state again
+func (l *Logger) ResetLevel(component string) {
+	l.levelLock.Lock()
+	defer l.levelLock.Unlock()
+	delete(l.levels, component)
+}
+
+// Levels set per component
+func (l *Logger) Levels() map[string]level.Level {
+	l.levelLock.RLock()
+	defer l.levelLock.RUnlock()
+	levels := make(map[string]level.Level, len(l.levels))
+	for component, lvl := range l.levels {
+		levels[component] = lvl
+	}
+	return levels
+}
+
+// levelFor component, and whether one was set
+func (l *Logger) levelFor(component string) (lvl level.Level, ok bool) {
+	l.levelLock.RLock()
+	defer l.levelLock.RUnlock()
+	if lvl, ok = l.levels[component]; ok {
+		return lvl, ok
+	}
+	lvl, ok = l.levels[""]
+	return lvl, ok
+}
+
 // Exit the logger
 func (l *Logger) Exit() {
 	l.file.Close()
+	if l.clockFile != nil {
+		l.clockFile.Close()
+	}
+}
+
+// EnableVectorClock starts tracking a vector clock for the process id, and logging every clocked event
+// in the GoVector format ShiViz reads to <dir>/<name><time>.shiviz.log. Every process tracked by the
+// logger shares the file, which is never rotated, as ShiViz needs the whole execution.
+func (l *Logger) EnableVectorClock(id string) (err error) {
+	l.clockLock.Lock()
+	defer l.clockLock.Unlock()
+	if l.clocks[id] != nil {
+		return fmt.Errorf("vector clock already enabled for %s", id)
+	}
+	if l.clockFile == nil {
+		l.clockFile, err = os.Create(filepath.Join(l.dir, l.name+timeNow()+".shiviz.log"))
+		if err != nil {
+			return fmt.Errorf("unable to create vector clock log file: %s", err)
+		}
+		l.clocks = make(map[string]vclock.VClock)
+	}
+	l.clocks[id] = vclock.New()
+	l.clockEvent(id, "Initialization Complete")
+	return nil
+}
+
+// LogLocalEvent ticks the vector clock of process id for an event that is neither a send nor a receive
+func (l *Logger) LogLocalEvent(id string, event string) {
+	l.clockLock.Lock()
+	defer l.clockLock.Unlock()
+	if l.clocks[id] == nil {
+		return
+	}
+	l.clockEvent(id, event)
+}
+
+// PrepareSend ticks the vector clock of process id for a send, and returns a copy to piggyback on the message.
+// Returns nil when its vector clock is not enabled.
+func (l *Logger) PrepareSend(id string, event string) vclock.VClock {
+	l.clockLock.Lock()
+	defer l.clockLock.Unlock()
+	if l.clocks[id] == nil {
+		return nil
+	}
+	l.clockEvent(id, event)
+	return l.clocks[id].Copy()
+}
+
+// UnpackReceive merges the clock piggybacked on a message process id received, and ticks for the receive
+func (l *Logger) UnpackReceive(id string, event string, clock vclock.VClock) {
+	l.clockLock.Lock()
+	defer l.clockLock.Unlock()
+	if l.clocks[id] == nil {
+		return
+	}
+	l.clocks[id].Merge(clock)
+	l.clockEvent(id, event)
+}
+
+// clockEvent ticks the clock of process id and writes the event as a GoVector entry: the process id and clock,
+// then the event. Must be called holding clockLock.
+func (l *Logger) clockEvent(id string, event string) {
+	clock := l.clocks[id]
+	clock.Tick(id)
+	entry := fmt.Sprintf("%s %s\n%s\n", id, clock, strings.Replace(event, "\n", " ", -1))
+	_, err := l.clockFile.WriteString(entry)
+	if err != nil {
+		l.write(level.ERROR, "vclock", Fields{"process": id}, fmt.Sprintf("unable to write event %q: %s", event, err))
+	}
+	l.write(level.DEBUG, "vclock", Fields{"process": id, "clock": clock.String()}, event)
 }
 
 // Log takes a level and some data to be logged per the logger state
 func (l *Logger) Log(givenLevel level.Level, data string) {
+	l.write(givenLevel, "", nil, data)
+}
+
+// write logs data from component with fields per the logger state and format
+func (l *Logger) write(givenLevel level.Level, component string, fields Fields, data string) {
 	if l.file == nil || l.log == nil {
-		fmt.Println("ERROR: Log is incorrectly initialized")
+		fmt.Fprintf(os.Stderr, "logger error: log is incorrectly initialized, dropping %s\n", data)
 		return
 	}
 
-	logString := fmt.Sprintf("| %s | %s", givenLevel, data)
+	threshold, leveled := l.levelFor(component)
+	if leveled && level.Rank(givenLevel) < level.Rank(threshold) {
+		return
+	}
+
+	logString := fmt.Sprintf("| %s | %s", givenLevel, textLine(component, fields, data))
 	switch l.state {
 	case state.NOWRITE:
 		// Do not write anything
 	default:
-		lineHeader := fmt.Sprintf("[ %s | %s ]", l.name, timeNow())
-		_, err := l.file.WriteString(lineHeader + logString + "\n")
+		var line string
+		if l.format == format.JSON {
+			line = l.jsonLine(givenLevel, component, fields, data)
+		} else {
+			line = fmt.Sprintf("[ %s | %s ]", l.name, timeNow()) + logString
+		}
+		_, err := l.file.WriteString(line + "\n")
 		if err != nil {
-			fmt.Printf("write failed: %s\n", err)
+			fmt.Fprintf(os.Stderr, "logger error: write failed: %s\n", err)
 		}
 	}
 
 	switch givenLevel {
 	case level.DEBUG:
-		if l.state == state.DEBUGGING {
+		if l.state == state.DEBUGGING || (leveled && l.state != state.QUIET) {
 			l.log.Print(logString)
 		}
 	case level.INFO:
@@ -102,6 +249,51 @@ func (l *Logger) Log(givenLevel level.Level, data string) {
 	}
 }
 
+// textLine renders data as `[component] data key=value ...`, with fields sorted by key
+func textLine(component string, fields Fields, data string) string {
+	line := data
+	if component != "" {
+		line = "[" + component + "] " + line
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		value := fmt.Sprintf("%v", fields[k])
+		if strings.ContainsAny(value, " \t\n\"=") {
+			value = strconv.Quote(value)
+		}
+		line += " " + k + "=" + value
+	}
+	return line
+}
+
+// jsonLine renders data as a JSON object. Fields are top level keys, unless they clash with
+// the time, logger, level, component or msg keys every line has.
+func (l *Logger) jsonLine(givenLevel level.Level, component string, fields Fields, data string) string {
+	line := make(map[string]interface{}, len(fields)+5)
+	for k, v := range fields {
+		if _, err := json.Marshal(v); err != nil {
+			v = fmt.Sprintf("%v", v)
+		}
+		line[k] = v
+	}
+	line["time"] = time.Now().Format(time.RFC3339Nano)
+	line["logger"] = l.name
+	line["level"] = level.Name(givenLevel)
+	if component != "" {
+		line["component"] = component
+	}
+	line["msg"] = data
+	encoded, err := json.Marshal(line)
+	if err != nil {
+		return fmt.Sprintf("{\"msg\":%q,\"error\":%q}", data, err)
+	}
+	return string(encoded)
+}
+
 // Debug Level log
 func (l *Logger) Debug(data string) {
 	l.Log(level.DEBUG, data)
@@ -127,6 +319,31 @@ func (l *Logger) Fatal(data string) {
 	l.Log(level.FATAL, data)
 }
 
+// Debugf formats according to a format specifier, then logs at Debug Level
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.Log(level.DEBUG, fmt.Sprintf(format, args...))
+}
+
+// Infof formats according to a format specifier, then logs at Info Level
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.Log(level.INFO, fmt.Sprintf(format, args...))
+}
+
+// Warningf formats according to a format specifier, then logs at Warning Level
+func (l *Logger) Warningf(format string, args ...interface{}) {
+	l.Log(level.WARNING, fmt.Sprintf(format, args...))
+}
+
+// Errorf formats according to a format specifier, then logs at Error Level
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.Log(level.ERROR, fmt.Sprintf(format, args...))
+}
+
+// Fatalf formats according to a format specifier, then logs at Fatal Level
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+	l.Log(level.FATAL, fmt.Sprintf(format, args...))
+}
+
 func timeNow() string {
 	return time.Now().Format("2006-01-02_15:04:05")
 }
@@ -0,0 +1,203 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config of where a logger writes to disk, and when it rotates and deletes its files
+type Config struct {
+	Dir      string        // directory for the log files, created if missing. Defaults to logs
+	MaxSize  int64         // rotate once a file reaches this many bytes, 0 never rotates on size
+	MaxAge   time.Duration // rotate once a file has been written to for this long, 0 never rotates on time
+	Compress bool          // gzip rotated files
+	MaxFiles int           // keep at most this many rotated files, 0 keeps them all
+	Retain   time.Duration // delete rotated files older than this, 0 keeps them all
+}
+
+// DefaultConfig writes to logs/ under the current directory, and never rotates or deletes anything
+func DefaultConfig() Config {
+	return Config{Dir: "logs"}
+}
+
+// ServiceConfig is for long running nodes: it writes to dir, rotating daily or every 10MB,
+// and keeps a week of compressed files
+func ServiceConfig(dir string) Config {
+	return Config{
+		Dir:      dir,
+		MaxSize:  10 << 20,
+		MaxAge:   24 * time.Hour,
+		Compress: true,
+		Retain:   7 * 24 * time.Hour,
+	}
+}
+
+// rotatingFile is a log file which moves on to a new file per its config,
+// and compresses and deletes old ones
+type rotatingFile struct {
+	sync.Mutex
+	config Config
+	name   string
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+func openRotatingFile(name string, config Config) (r *rotatingFile, err error) {
+	if config.Dir == "" {
+		config.Dir = DefaultConfig().Dir
+	}
+	err = os.MkdirAll(config.Dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create log folder: %s", err)
+	}
+	r = &rotatingFile{config: config, name: name}
+	err = r.open()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open a new file named <dir>/<name><time>.log, numbered if rotating more than once a second
+func (r *rotatingFile) open() (err error) {
+	stamp := timeNow()
+	path := filepath.Join(r.config.Dir, r.name+stamp+".log")
+	for i := 1; exists(path) || exists(path+".gz"); i++ {
+		path = filepath.Join(r.config.Dir, r.name+stamp+"."+strconv.Itoa(i)+".log")
+	}
+	r.file, err = os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create log file: %s", err)
+	}
+	r.size = 0
+	r.opened = time.Now()
+	return nil
+}
+
+// WriteString to the current file, rotating first if it is due
+func (r *rotatingFile) WriteString(s string) (n int, err error) {
+	r.Lock()
+	defer r.Unlock()
+	if r.file == nil {
+		return 0, fmt.Errorf("log file closed")
+	}
+	if r.due(len(s)) {
+		err = r.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err = r.file.WriteString(s)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) due(next int) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.config.MaxSize > 0 && r.size+int64(next) > r.config.MaxSize {
+		return true
+	}
+	return r.config.MaxAge > 0 && time.Since(r.opened) >= r.config.MaxAge
+}
+
+// rotate closes the current file, opens the next and cleans up the old ones
+func (r *rotatingFile) rotate() (err error) {
+	old := r.file.Name()
+	r.file.Close()
+	err = r.open()
+	if err != nil {
+		return err
+	}
+	if r.config.Compress {
+		if err := compress(old); err != nil {
+			// the rotated file is kept uncompressed. Logging it would rotate again, and stdout is the application's.
+			fmt.Fprintf(os.Stderr, "logger error: unable to compress %s: %s\n", old, err)
+		}
+	}
+	r.retain()
+	return nil
+}
+
+// retain deletes the rotated files beyond MaxFiles or older than Retain
+func (r *rotatingFile) retain() {
+	if r.config.MaxFiles <= 0 && r.config.Retain <= 0 {
+		return
+	}
+	matches, _ := filepath.Glob(filepath.Join(r.config.Dir, r.name+"[0-9][0-9][0-9][0-9]-*.log*"))
+	type rotated struct {
+		path    string
+		modTime time.Time
+	}
+	var old []rotated
+	for _, path := range matches {
+		if path == r.file.Name() || !(strings.HasSuffix(path, ".log") || strings.HasSuffix(path, ".log.gz")) {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		old = append(old, rotated{path, info.ModTime()})
+	}
+	// newest first
+	sort.Slice(old, func(i, j int) bool { return old[i].modTime.After(old[j].modTime) })
+	for i, f := range old {
+		if (r.config.MaxFiles > 0 && i >= r.config.MaxFiles) || (r.config.Retain > 0 && time.Since(f.modTime) > r.config.Retain) {
+			os.Remove(f.path)
+		}
+	}
+}
+
+// Close the current file
+func (r *rotatingFile) Close() error {
+	r.Lock()
+	defer r.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// compress path into path.gz, and remove path
+func compress(path string) (err error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+	zipped := gzip.NewWriter(out)
+	_, err = io.Copy(zipped, in)
+	if err == nil {
+		err = zipped.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -1,20 +1,28 @@
 package singletonlogger
 
 import (
+	"filelogger/format"
 	"filelogger/level"
 	"filelogger/logger"
 	"filelogger/state"
+	"filelogger/vclock"
 	"fmt"
+	"os"
 )
 
 var singletonLogger *logger.Logger
 
 // NewSingletonLogger creates a new global single instance of a logger.
 func NewSingletonLogger(loggerName string, state state.State) (err error) {
+	return NewSingletonLoggerWithConfig(loggerName, state, logger.DefaultConfig())
+}
+
+// NewSingletonLoggerWithConfig creates a new global single instance of a logger, writing to disk per config.
+func NewSingletonLoggerWithConfig(loggerName string, state state.State, config logger.Config) (err error) {
 	if singletonLogger != nil {
 		return fmt.Errorf("logger already exists")
 	}
-	singletonLogger, err = logger.NewFileLogger(loggerName, state)
+	singletonLogger, err = logger.NewFileLoggerWithConfig(loggerName, state, config)
 	if err != nil {
 		return fmt.Errorf("unable to create a singletonlogger: %s", err)
 	}
@@ -24,8 +32,8 @@ func NewSingletonLogger(loggerName string, state state.State) (err error) {
 // Debug Level log
 func Debug(data string) {
 	if singletonLogger == nil {
-		fmt.Println("LOGGING ERROR: Singleton logger uninitialised!")
-		fmt.Println(data)
+		fmt.Fprintln(os.Stderr, "LOGGING ERROR: Singleton logger uninitialised!")
+		fmt.Fprintln(os.Stderr, data)
 		return
 	}
 	singletonLogger.Log(level.DEBUG, data)
@@ -34,8 +42,8 @@ func Debug(data string) {
 // Info Level log
 func Info(data string) {
 	if singletonLogger == nil {
-		fmt.Println("LOGGING ERROR: Singleton logger uninitialised!")
-		fmt.Println(data)
+		fmt.Fprintln(os.Stderr, "LOGGING ERROR: Singleton logger uninitialised!")
+		fmt.Fprintln(os.Stderr, data)
 		return
 	}
 	singletonLogger.Log(level.INFO, data)
@@ -44,8 +52,8 @@ func Info(data string) {
 // Warning Level log
 func Warning(data string) {
 	if singletonLogger == nil {
-		fmt.Println("LOGGING ERROR: Singleton logger uninitialised!")
-		fmt.Println(data)
+		fmt.Fprintln(os.Stderr, "LOGGING ERROR: Singleton logger uninitialised!")
+		fmt.Fprintln(os.Stderr, data)
 		return
 	}
 	singletonLogger.Log(level.WARNING, data)
@@ -54,8 +62,8 @@ func Warning(data string) {
 // Error Level log
 func Error(data string) {
 	if singletonLogger == nil {
-		fmt.Println("LOGGING ERROR: Singleton logger uninitialised!")
-		fmt.Println(data)
+		fmt.Fprintln(os.Stderr, "LOGGING ERROR: Singleton logger uninitialised!")
+		fmt.Fprintln(os.Stderr, data)
 		return
 	}
 	singletonLogger.Log(level.ERROR, data)
@@ -64,9 +72,114 @@ func Error(data string) {
 // Fatal Level log
 func Fatal(data string) {
 	if singletonLogger == nil {
-		fmt.Println("LOGGING ERROR: Singleton logger uninitialised!")
-		fmt.Println(data)
+		fmt.Fprintln(os.Stderr, "LOGGING ERROR: Singleton logger uninitialised!")
+		fmt.Fprintln(os.Stderr, data)
 		return
 	}
 	singletonLogger.Log(level.FATAL, data)
 }
+
+// Fields are key/value pairs attached to a log line
+type Fields = logger.Fields
+
+// Component creates an entry tagging every line with the component, such as "paxosnode" or "acceptor".
+// It can be created before the singleton logger, and logs to it once it exists.
+func Component(component string) *logger.Entry {
+	return logger.NewEntry(func() *logger.Logger { return singletonLogger }, component)
+}
+
+// SetFormat of the lines the singleton logger writes to disk
+func SetFormat(f format.Format) (err error) {
+	if singletonLogger == nil {
+		return fmt.Errorf("singleton logger uninitialised")
+	}
+	singletonLogger.SetFormat(f)
+	return nil
+}
+
+// SetLevel of component, or of every component without its own for "". The level "reset" clears it.
+func SetLevel(component string, levelName string) (err error) {
+	if singletonLogger == nil {
+		return fmt.Errorf("singleton logger uninitialised")
+	}
+	if levelName == "reset" {
+		singletonLogger.ResetLevel(component)
+		return nil
+	}
+	lvl, err := level.Parse(levelName)
+	if err != nil {
+		return err
+	}
+	singletonLogger.SetLevel(component, lvl)
+	return nil
+}
+
+// Levels set per component, by level name
+func Levels() (levels map[string]string) {
+	levels = make(map[string]string)
+	if singletonLogger == nil {
+		return levels
+	}
+	for component, lvl := range singletonLogger.Levels() {
+		levels[component] = level.Name(lvl)
+	}
+	return levels
+}
+
+// Debugf formats according to a format specifier, then logs at Debug Level
+func Debugf(format string, args ...interface{}) {
+	Debug(fmt.Sprintf(format, args...))
+}
+
+// Infof formats according to a format specifier, then logs at Info Level
+func Infof(format string, args ...interface{}) {
+	Info(fmt.Sprintf(format, args...))
+}
+
+// Warningf formats according to a format specifier, then logs at Warning Level
+func Warningf(format string, args ...interface{}) {
+	Warning(fmt.Sprintf(format, args...))
+}
+
+// Errorf formats according to a format specifier, then logs at Error Level
+func Errorf(format string, args ...interface{}) {
+	Error(fmt.Sprintf(format, args...))
+}
+
+// Fatalf formats according to a format specifier, then logs at Fatal Level
+func Fatalf(format string, args ...interface{}) {
+	Fatal(fmt.Sprintf(format, args...))
+}
+
+// EnableVectorClock starts tracking a vector clock for the process identified by id, such as a paxos node
+func EnableVectorClock(id string) (err error) {
+	if singletonLogger == nil {
+		return fmt.Errorf("singleton logger uninitialised")
+	}
+	return singletonLogger.EnableVectorClock(id)
+}
+
+// LogLocalEvent ticks the vector clock of process id for a local event
+func LogLocalEvent(id string, event string) {
+	if singletonLogger == nil {
+		return
+	}
+	singletonLogger.LogLocalEvent(id, event)
+}
+
+// PrepareSend ticks the vector clock of process id for a send and returns the clock to piggyback,
+// or nil if not tracking it
+func PrepareSend(id string, event string) vclock.VClock {
+	if singletonLogger == nil {
+		return nil
+	}
+	return singletonLogger.PrepareSend(id, event)
+}
+
+// UnpackReceive merges a vector clock process id received and ticks for the receive
+func UnpackReceive(id string, event string, clock vclock.VClock) {
+	if singletonLogger == nil {
+		return
+	}
+	singletonLogger.UnpackReceive(id, event, clock)
+}
@@ -0,0 +1,54 @@
+package vclock
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VClock is a vector clock, mapping each process id to the number of events seen from it
+type VClock map[string]uint64
+
+// New creates an empty vector clock
+func New() VClock {
+	return make(VClock)
+}
+
+// Tick records a new event on process id
+func (vc VClock) Tick(id string) {
+	vc[id]++
+}
+
+// Merge takes the element-wise maximum of this clock and other
+func (vc VClock) Merge(other VClock) {
+	for id, ticks := range other {
+		if ticks > vc[id] {
+			vc[id] = ticks
+		}
+	}
+}
+
+// Copy the clock, so it can be sent while this one keeps ticking
+func (vc VClock) Copy() VClock {
+	c := make(VClock, len(vc))
+	for id, ticks := range vc {
+		c[id] = ticks
+	}
+	return c
+}
+
+// String renders the clock as JSON with sorted keys, as GoVector and ShiViz expect
+func (vc VClock) String() string {
+	ids := make([]string, 0, len(vc))
+	for id := range vc {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	entries := make([]string, 0, len(ids))
+	for _, id := range ids {
+		key, _ := json.Marshal(id)
+		entries = append(entries, string(key)+":"+strconv.FormatUint(vc[id], 10))
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
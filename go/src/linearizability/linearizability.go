@@ -0,0 +1,167 @@
+package linearizability
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+Linearizability checks a recorded history of concurrent client operations against a sequential model.
+The search is the Wing & Gong algorithm with the state caching of Lowe, the same approach Knossos and Porcupine take:
+operations are linearized one at a time in an order consistent with real time, backtracking whenever the model
+rejects an operation, and never revisiting a (linearized set, model state) pair twice.
+*/
+
+// Pending is the return time of an operation that never completed. It may take effect at any point after its call.
+const Pending int64 = 1<<63 - 1
+
+// Operation is a single completed (or pending) call made by a client
+type Operation struct {
+	ClientID int
+	Kind     OpKind
+	Input    string // value written, empty for reads
+	Output   string // value read, empty for writes
+	Call     int64  // invocation time in nanoseconds
+	Return   int64  // completion time in nanoseconds, or Pending
+}
+
+// Result of checking a history
+type Result struct {
+	Linearizable bool
+	// Longest is the longest linearization found, which for a failed check ends just before the violation
+	Longest []Operation
+}
+
+// entry is a call or return event in the doubly linked history
+type entry struct {
+	id     int
+	isCall bool
+	time   int64
+	match  *entry // the return entry of a call
+	prev   *entry
+	next   *entry
+}
+
+type frame struct {
+	entry *entry
+	state State
+}
+
+// Check whether the operations are linearizable with respect to the model
+func Check(model Model, ops []Operation) Result {
+	head := makeEntries(ops)
+	linearized := newBitset(len(ops))
+	cache := make(map[string]bool)
+	stack := make([]frame, 0, len(ops))
+	state := model.Init()
+	longest := make([]Operation, 0)
+
+	e := head.next
+	for head.next != nil {
+		if e.isCall {
+			ok, next := model.Step(state, ops[e.id])
+			if ok {
+				linearized.set(e.id)
+				key := linearized.key() + "|" + model.Key(next)
+				if !cache[key] {
+					cache[key] = true
+					stack = append(stack, frame{e, state})
+					state = next
+					lift(e)
+					if len(stack) > len(longest) {
+						longest = longest[:0]
+						for _, f := range stack {
+							longest = append(longest, ops[f.entry.id])
+						}
+					}
+					e = head.next
+					continue
+				}
+				linearized.clear(e.id)
+			}
+			e = e.next
+		} else {
+			// a return was reached before its call could be linearized, so undo the last choice
+			if len(stack) == 0 {
+				return Result{Linearizable: false, Longest: longest}
+			}
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			state = top.state
+			linearized.clear(top.entry.id)
+			unlift(top.entry)
+			e = top.entry.next
+		}
+	}
+	return Result{Linearizable: true, Longest: longest}
+}
+
+// makeEntries builds the time ordered event list. Calls sort before returns at the same instant,
+// since such operations overlap.
+func makeEntries(ops []Operation) *entry {
+	entries := make([]*entry, 0, 2*len(ops))
+	for i, op := range ops {
+		ret := &entry{id: i, time: op.Return}
+		call := &entry{id: i, isCall: true, time: op.Call, match: ret}
+		entries = append(entries, call, ret)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].time != entries[j].time {
+			return entries[i].time < entries[j].time
+		}
+		return entries[i].isCall && !entries[j].isCall
+	})
+	head := &entry{id: -1}
+	prev := head
+	for _, e := range entries {
+		e.prev = prev
+		prev.next = e
+		prev = e
+	}
+	return head
+}
+
+// lift removes a call and its return from the list
+func lift(e *entry) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	m := e.match
+	m.prev.next = m.next
+	if m.next != nil {
+		m.next.prev = m.prev
+	}
+}
+
+// unlift puts a lifted call and its return back
+func unlift(e *entry) {
+	m := e.match
+	m.prev.next = m
+	if m.next != nil {
+		m.next.prev = m
+	}
+	e.prev.next = e
+	e.next.prev = e
+}
+
+type bitset []uint64
+
+func newBitset(n int) bitset {
+	return make(bitset, n/64+1)
+}
+
+func (b bitset) set(i int) {
+	b[i/64] |= 1 << uint(i%64)
+}
+
+func (b bitset) clear(i int) {
+	b[i/64] &^= 1 << uint(i%64)
+}
+
+func (b bitset) key() string {
+	words := make([]string, len(b))
+	for i, w := range b {
+		words[i] = strconv.FormatUint(w, 16)
+	}
+	return strings.Join(words, ",")
+}
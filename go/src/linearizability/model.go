@@ -0,0 +1,87 @@
+package linearizability
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OpKind is the type of an operation
+type OpKind int
+
+const (
+	// WRITE appends a value to the log
+	WRITE OpKind = iota
+	// READ returns the whole log
+	READ
+)
+
+// State of a sequential model. Models must treat states as immutable.
+type State interface{}
+
+// Model is the sequential specification a history is checked against
+type Model interface {
+	// Init returns the initial state
+	Init() State
+	// Step applies op to state, and reports whether op's output is legal from that state
+	Step(state State, op Operation) (ok bool, next State)
+	// Key returns a string that is equal for equal states
+	Key(state State) string
+}
+
+// LogModel is the shared diary log: writes append a value, and reads return every value in order,
+// each followed by a newline as consensuslib.Client.Read formats them.
+type LogModel struct{}
+
+// Init is the empty log
+func (LogModel) Init() State {
+	return []string{}
+}
+
+// Step applies a read or write to the log
+func (LogModel) Step(state State, op Operation) (bool, State) {
+	log := state.([]string)
+	switch op.Kind {
+	case WRITE:
+		next := make([]string, len(log), len(log)+1)
+		copy(next, log)
+		return true, append(next, op.Input)
+	case READ:
+		return op.Output == render(log), log
+	default:
+		return false, log
+	}
+}
+
+// Key of a log
+func (LogModel) Key(state State) string {
+	return render(state.([]string))
+}
+
+func render(log []string) string {
+	value := ""
+	for _, v := range log {
+		value += v + "\n"
+	}
+	return value
+}
+
+// String of an OpKind
+func (k OpKind) String() string {
+	switch k {
+	case WRITE:
+		return "write"
+	case READ:
+		return "read"
+	default:
+		return "unknown"
+	}
+}
+
+// Describe an operation on a single line
+func (op Operation) String() string {
+	ret := "pending"
+	if op.Return != Pending {
+		ret = strings.TrimSpace(strings.Replace(op.Output, "\n", " ", -1))
+	}
+	return fmt.Sprintf("client %d: %v(%s) -> %s", op.ClientID, op.Kind, op.Input, ret)
+}
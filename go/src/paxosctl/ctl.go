@@ -0,0 +1,184 @@
+// Entrypoint for the Paxos cluster inspector
+// This file can be run with 'go run paxosctl/ctl.go'
+// Or do `go install` then `paxosctl` to run the binary
+
+// It talks to the distributed diary server and to each node, to look into a running network
+// without going through a node's interactive prompt.
+
+// Go Run Example: `go run paxosctl/ctl.go 127.0.0.1:12345 members`
+// Go Run Example: `go run paxosctl/ctl.go 127.0.0.1:12345 diff`
+
+package main
+
+import (
+	"consensuslib"
+	"consensuslib/admission"
+	"consensuslib/safety"
+	"consensuslib/transport"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const usage = `==================================================
+The Chamber of Secrets: Paxos Cluster Inspector
+==================================================
+Usage: go run ctl.go [--tls-ca=FILE --tls-cert=FILE --tls-key=FILE] [--cluster-key=FILE] SERVERIP:PORT[,SERVERIP:PORT ...] COMMAND [NODE ...]
+
+With the --tls options, talk to a network that requires mutual TLS, with the PEM certificate and key
+signed by the CA in FILE. The certificate must name the address of the machine paxosctl runs on.
+With --cluster-key, sign every connection with the key shared by the cluster in FILE.
+
+Valid commands:
+
+members : list the nodes registered with the server, and the time since their last heartbeat
+log [NODE ...] : dump the learner log of the given nodes, or of every member
+diff [NODE ...] : show every index the logs of the given nodes, or of every member, differ at
+status [NODE ...] : show the round, message ID, acceptor state and neighbours of the given nodes, or of every member
+catchup NODE : make NODE catch up with the longest log of its neighbours
+remove NODE : remove the dead NODE from the server, and from the neighbours of every other member
+`
+
+func main() {
+	t, args := parseTransport(os.Args[1:])
+	if len(args) < 2 {
+		fmt.Print(usage)
+		os.Exit(1)
+	}
+	inspector, err := consensuslib.NewInspectorWithTransport(t, strings.Split(args[0], ",")...)
+	checkError(err)
+	command, nodes := args[1], args[2:]
+
+	switch command {
+	case "members":
+		members, err := inspector.Members()
+		checkError(err)
+		for _, m := range members {
+			fmt.Printf("%-21s last heartbeat %v ago\n", m.Address, m.HeartbeatAge.Round(time.Millisecond))
+		}
+		fmt.Printf("%d members\n", len(members))
+	case "log":
+		snapshot := gather(inspector, nodes)
+		for _, addr := range sortedNodes(snapshot) {
+			fmt.Printf("%s (%d values):\n", addr, len(snapshot.Logs[addr]))
+			for i, m := range snapshot.Logs[addr] {
+				fmt.Printf("  %3d id: %d, hash: %s, round: %d, value: '%s'\n", i, m.ID, m.MsgHash, m.RoundNum, m.Value)
+			}
+		}
+	case "diff":
+		differences := safety.Diff(gather(inspector, nodes))
+		for _, d := range differences {
+			fmt.Println(d.String())
+		}
+		if len(differences) == 0 {
+			fmt.Println("Logs are identical")
+		}
+	case "status":
+		addrs, err := inspector.Addrs(nodes)
+		checkError(err)
+		var unreachable []string
+		for _, addr := range addrs {
+			status, err := inspector.Status(addr)
+			if err != nil {
+				// the other nodes' status is still worth showing, most of all when one is down
+				fmt.Printf("%s: %s\n", addr, err)
+				unreachable = append(unreachable, addr)
+				continue
+			}
+			out, err := json.MarshalIndent(status, "", "  ")
+			checkError(err)
+			fmt.Println(string(out))
+		}
+		if len(unreachable) != 0 {
+			fmt.Printf("Unable to reach %v\n", unreachable)
+			os.Exit(1)
+		}
+	case "catchup":
+		checkNode(nodes)
+		learned, err := inspector.CatchUp(nodes[0])
+		checkError(err)
+		fmt.Printf("%s learned %d values\n", nodes[0], learned)
+	case "remove":
+		checkNode(nodes)
+		unreachable, err := inspector.Remove(nodes[0])
+		checkError(err)
+		fmt.Printf("Removed %s\n", nodes[0])
+		if len(unreachable) != 0 {
+			fmt.Printf("Unable to tell %v to drop it\n", unreachable)
+		}
+	default:
+		fmt.Print(usage)
+		os.Exit(1)
+	}
+}
+
+// parseTransport takes the leading --tls and --cluster-key options out of args, returning the transport they
+// configure, which is nil if there are none
+func parseTransport(args []string) (t *transport.Transport, rest []string) {
+	var tls transport.Config
+	var key []byte
+	for len(args) != 0 && strings.HasPrefix(args[0], "--") {
+		flag := strings.SplitN(args[0], "=", 2)
+		if len(flag) != 2 {
+			break
+		}
+		switch flag[0] {
+		case "--tls-ca":
+			tls.CAFile = flag[1]
+		case "--tls-cert":
+			tls.CertFile = flag[1]
+		case "--tls-key":
+			tls.KeyFile = flag[1]
+		case "--cluster-key":
+			var err error
+			key, err = admission.LoadKey(flag[1])
+			checkError(err)
+		default:
+			fmt.Print(usage)
+			os.Exit(1)
+		}
+		args = args[1:]
+	}
+	config := &tls
+	if tls == (transport.Config{}) {
+		config = nil
+	}
+	t, err := transport.New(config, admission.New(key))
+	checkError(err)
+	return t, args
+}
+
+// gather a snapshot of the given nodes, or of every member
+func gather(inspector *consensuslib.Inspector, nodes []string) *safety.Snapshot {
+	addrs, err := inspector.Addrs(nodes)
+	checkError(err)
+	snapshot, err := inspector.Snapshot(addrs)
+	checkError(err)
+	return snapshot
+}
+
+func sortedNodes(snapshot *safety.Snapshot) []string {
+	nodes := make([]string, 0, len(snapshot.Logs))
+	for addr := range snapshot.Logs {
+		nodes = append(nodes, addr)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+func checkNode(nodes []string) {
+	if len(nodes) != 1 {
+		fmt.Print(usage)
+		os.Exit(1)
+	}
+}
+
+func checkError(err error) {
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
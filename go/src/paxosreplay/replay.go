@@ -0,0 +1,103 @@
+// Entrypoint for the Paxos Replay tool
+// This file can be run with 'go run paxosreplay/replay.go'
+// Or do `go install` then `paxosreplay` to run the binary
+
+// It re-drives a fresh paxos node from a trace recorded by running the app with --record,
+// reports every reply that differs from the recording, and prints the node's final state.
+
+// Go Run Example: `go run paxosreplay/replay.go traces/127.0.0.1_8080_2018-04-09_12-00-00.trace`
+// Go Run Example: `go run paxosreplay/replay.go traces/127.0.0.1_8080_2018-04-09_12-00-00.trace --debug` -- To log every replayed step
+
+package main
+
+import (
+	"consensuslib/paxosnode"
+	"consensuslib/paxosnode/trace"
+	"encoding/json"
+	"filelogger/singletonlogger"
+	"filelogger/state"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"paxostracker"
+)
+
+const (
+	debugFlag = "--debug"
+	usage     = `==================================================
+The Chamber of Secrets: Paxos Replay
+==================================================
+Usage: go run replay.go TRACEFILE [options]
+
+Valid options:
+
+--debug : run with debugging turned on for verbose logging
+`
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Print(usage)
+		os.Exit(1)
+	}
+	logstate := state.QUIET
+	if len(os.Args) > 2 && os.Args[2] == debugFlag {
+		logstate = state.DEBUGGING
+	}
+	err := singletonlogger.NewSingletonLogger("replay", logstate)
+	checkError(err)
+
+	entries, err := trace.Load(os.Args[1])
+	checkError(err)
+	if len(entries) == 0 || entries[0].Kind != trace.INIT {
+		checkError(fmt.Errorf("trace does not start with the node's initial state"))
+	}
+	var initial paxosnode.ReplayState
+	err = json.Unmarshal(entries[0].Arg, &initial)
+	checkError(err)
+
+	// the replayed acceptor and learner must neither start from nor overwrite the recorded node's backups
+	dataDir, err := ioutil.TempDir("", "paxosreplay")
+	checkError(err)
+	defer os.RemoveAll(dataDir)
+
+	tracker := paxostracker.NewPaxosTracker(initial.Addr)
+	// the replayed proposer must propose under the recorded node's ID
+	config := paxosnode.DefaultConfig()
+	config.ID = initial.ID
+	config.DataDir = dataDir
+	pn, err := paxosnode.NewPaxosNodeWithConfig(initial.Addr, tracker, config)
+	checkError(err)
+
+	divergences, err := pn.Replay(entries)
+	checkError(err)
+
+	fmt.Printf("Replayed %d entries recorded by %s\n", len(entries), initial.Addr)
+	if len(divergences) == 0 {
+		fmt.Println("No divergences: the replay reproduced every recorded reply")
+	} else {
+		fmt.Printf("%d divergences:\n", len(divergences))
+		for _, d := range divergences {
+			fmt.Println("  " + d)
+		}
+	}
+	fmt.Printf("\nRound: %d\n", pn.RoundNum)
+	lastPromised, lastAccepted := pn.Acceptor.State()
+	fmt.Printf("Last promised: %+v\n", lastPromised)
+	fmt.Printf("Last accepted: %+v\n", lastAccepted)
+	fmt.Println("Log:")
+	for i, m := range pn.Learner.Log {
+		fmt.Printf("  %d: %s\n", i, m.Value)
+	}
+	fmt.Println(tracker.AsTable())
+	if len(divergences) != 0 {
+		os.Exit(1)
+	}
+}
+
+func checkError(err error) {
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,53 @@
+// Entrypoint for the Paxos Timeline generator
+// This file can be run with 'go run paxostimeline/timeline.go'
+// Or do `go install` then `paxostimeline` to run the binary
+
+// It merges the JSON exports written by the app's `rounds --json` command on each node
+// into one self-contained HTML page, with a swimlane per node.
+
+// Go Run Example: `go run paxostimeline/timeline.go timeline.html node1.json node2.json node3.json`
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"paxostracker"
+	"paxostracker/timeline"
+)
+
+const usage = `==================================================
+The Chamber of Secrets: Paxos Timeline
+==================================================
+Usage: go run timeline.go OUTPUT.html EXPORT.json [EXPORT.json ...]
+
+Each EXPORT.json is the output of 'rounds --json' on one node.
+`
+
+func main() {
+	if len(os.Args) < 3 {
+		fmt.Print(usage)
+		os.Exit(1)
+	}
+	exports := make([]paxostracker.Export, 0, len(os.Args)-2)
+	for _, path := range os.Args[2:] {
+		buf, err := ioutil.ReadFile(path)
+		checkError(err)
+		export, err := paxostracker.ParseExport(buf)
+		checkError(err)
+		exports = append(exports, export)
+	}
+	f, err := os.Create(os.Args[1])
+	checkError(err)
+	defer f.Close()
+	checkError(timeline.Render(f, exports))
+	fmt.Printf("Wrote timeline of %d nodes to %s\n", len(exports), os.Args[1])
+}
+
+func checkError(err error) {
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
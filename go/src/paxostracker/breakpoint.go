@@ -0,0 +1,175 @@
+package paxostracker
+
+import (
+	"fmt"
+	"paxostracker/errors"
+)
+
+// Stage of a round that a breakpoint can stop before
+type Stage string
+
+// Stages, in the order a round passes through them
+const (
+	PREPARE Stage = "prepare"
+	PROPOSE Stage = "propose"
+	LEARN   Stage = "learn"
+	IDLE    Stage = "idle"
+	CUSTOM  Stage = "custom"
+)
+
+// Breakpoint stops the next matching stage until continue. Empty conditions match anything.
+type Breakpoint struct {
+	Stage    Stage
+	Node     string // only break on the node with this address
+	MinRound int    // only break in rounds >= MinRound
+	Value    string // only break when the round's value is exactly this
+}
+
+// Matches reports whether the breakpoint applies to the given stage on node
+func (b Breakpoint) Matches(stage Stage, node string, roundNum int, value string) bool {
+	return b.Stage == stage &&
+		(b.Node == "" || b.Node == node) &&
+		roundNum >= b.MinRound &&
+		(b.Value == "" || b.Value == value)
+}
+
+// String describes the breakpoint
+func (b Breakpoint) String() string {
+	desc := string(b.Stage)
+	if b.Node != "" {
+		desc += " on node " + b.Node
+	}
+	if b.MinRound != 0 {
+		desc += fmt.Sprintf(" when round >= %d", b.MinRound)
+	}
+	if b.Value != "" {
+		desc += " when value is '" + b.Value + "'"
+	}
+	return desc
+}
+
+// AddBreakpoint arms a breakpoint. It is removed once it is hit.
+func (t *PaxosTracker) AddBreakpoint(b Breakpoint) error {
+	if t == nil {
+		trackerLog.Error("PaxosTracker Uninitialised")
+		return nil
+	}
+	trackerLog.Debugf("adding breakpoint before %v", b)
+	t.Lock()
+	defer t.Unlock()
+	t.breakpoints = append(t.breakpoints, b)
+	return nil
+}
+
+// Breakpoints returns the armed breakpoints
+func (t *PaxosTracker) Breakpoints() []Breakpoint {
+	if t == nil {
+		return nil
+	}
+	t.Lock()
+	defer t.Unlock()
+	breakpoints := make([]Breakpoint, len(t.breakpoints))
+	copy(breakpoints, t.breakpoints)
+	return breakpoints
+}
+
+// Paused returns the stage the node is blocked at, or the empty stage if it is running
+func (t *PaxosTracker) Paused() Stage {
+	if t == nil {
+		return ""
+	}
+	t.Lock()
+	defer t.Unlock()
+	return t.pausedAt
+}
+
+// Continue the execution of paxos. Returns NotAtBreakpoint if the node is not at a breakpoint.
+func (t *PaxosTracker) Continue() error {
+	if t == nil {
+		trackerLog.Error("PaxosTracker Uninitialised")
+		return errors.NotAtBreakpoint("")
+	}
+	t.Lock()
+	defer t.Unlock()
+	if t.pausedAt == "" {
+		return errors.NotAtBreakpoint("")
+	}
+	trackerLog.Debug("Filling continue channel for next round")
+	t.resume()
+	return nil
+}
+
+// Step continues to the next stage of the round the node is paused in, and breaks there
+func (t *PaxosTracker) Step() error {
+	if t == nil {
+		trackerLog.Error("PaxosTracker Uninitialised")
+		return errors.NotAtBreakpoint("")
+	}
+	t.Lock()
+	defer t.Unlock()
+	var next Stage
+	switch t.pausedAt {
+	case "":
+		return errors.NotAtBreakpoint("")
+	case PREPARE:
+		next = PROPOSE
+	case PROPOSE:
+		next = LEARN
+	case LEARN:
+		next = IDLE
+	default:
+		return errors.CannotStep(string(t.pausedAt))
+	}
+	t.breakpoints = append(t.breakpoints, Breakpoint{Stage: next, Node: t.node})
+	trackerLog.Debugf("stepping to %s", next)
+	t.resume()
+	return nil
+}
+
+// resume one paused round, holding the lock so a concurrent continue cannot resume the same round.
+// A paused round receives without taking the lock, so the send cannot deadlock.
+func (t *PaxosTracker) resume() {
+	t.paused--
+	if t.paused == 0 {
+		t.pausedAt = ""
+	}
+	t.continuePaxos <- struct{}{}
+}
+
+// pause blocks at stage if a breakpoint matches, until continue
+func (t *PaxosTracker) pause(stage Stage, roundNum int, value string) {
+	t.Lock()
+	hit := -1
+	for i, b := range t.breakpoints {
+		if b.Matches(stage, t.node, roundNum, value) {
+			hit = i
+			break
+		}
+	}
+	if hit == -1 {
+		t.Unlock()
+		return
+	}
+	trackerLog.Infof("hit breakpoint before %v", t.breakpoints[hit])
+	t.breakpoints = append(t.breakpoints[:hit], t.breakpoints[hit+1:]...)
+	t.pausedAt = stage
+	t.paused++
+	t.Unlock()
+
+	// blocks until continue channel is filled
+	<-t.continuePaxos
+	trackerLog.Debug("continuing...")
+}
+
+// roundContext is the round number and value of the round in progress, preferring the active side
+func (t *PaxosTracker) roundContext() (roundNum int, value string) {
+	t.Lock()
+	defer t.Unlock()
+	if t.currentRound != nil {
+		return t.currentRound.RoundNum, t.currentRound.Value
+	}
+	if t.passiveRound != nil {
+		return t.passiveRound.RoundNum, t.passiveRound.Value
+	}
+	return 0, ""
+}
@@ -11,3 +11,15 @@ type UnknownTransition string
 func (e UnknownTransition) Error() string {
 	return "unknown transition"
 }
+
+type NotAtBreakpoint string
+
+func (e NotAtBreakpoint) Error() string {
+	return "not at a breakpoint"
+}
+
+type CannotStep string
+
+func (e CannotStep) Error() string {
+	return "cannot step beyond " + string(e)
+}
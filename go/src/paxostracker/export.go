@@ -0,0 +1,83 @@
+package paxostracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"paxostracker/state"
+	"time"
+)
+
+// Export is a point in time copy of a tracker, which marshals to JSON
+type Export struct {
+	Node          string // address of the tracked node
+	ID            string // ID of the tracked node, which its rounds name their proposer by
+	ExportedAt    time.Time
+	CurrentState  state.PaxosState
+	AcceptorState state.PaxosState
+	Rounds        []PaxosRound // completed rounds, followed by any rounds still in progress
+}
+
+// Export the tracker's rounds, transitions and timestamps
+func (t *PaxosTracker) Export() Export {
+	if t == nil {
+		trackerLog.Error("PaxosTracker Uninitialised")
+		return Export{ExportedAt: time.Now(), CurrentState: state.Idle, AcceptorState: state.Idle, Rounds: []PaxosRound{}}
+	}
+	t.Lock()
+	defer t.Unlock()
+	export := Export{
+		Node:          t.node,
+		ID:            t.id,
+		ExportedAt:    time.Now(),
+		CurrentState:  t.currentState,
+		AcceptorState: t.passiveState,
+		Rounds:        make([]PaxosRound, 0, len(t.completedRounds)+2),
+	}
+	for _, round := range t.completedRounds {
+		export.Rounds = append(export.Rounds, round.copy())
+	}
+	if t.currentRound != nil {
+		export.Rounds = append(export.Rounds, t.currentRound.copy())
+	}
+	if t.passiveRound != nil {
+		export.Rounds = append(export.Rounds, t.passiveRound.copy())
+	}
+	return export
+}
+
+// AsJSON returns the tracker's export as indented JSON
+func (t *PaxosTracker) AsJSON() (string, error) {
+	buf, err := json.MarshalIndent(t.Export(), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal paxostracker export: %s", err)
+	}
+	return string(buf), nil
+}
+
+// WriteJSON writes the tracker's export to w as indented JSON, followed by a newline
+func (t *PaxosTracker) WriteJSON(w io.Writer) error {
+	rounds, err := t.AsJSON()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, rounds)
+	return err
+}
+
+// ParseExport reads an export produced by AsJSON or WriteJSON
+func ParseExport(buf []byte) (export Export, err error) {
+	err = json.Unmarshal(buf, &export)
+	if err != nil {
+		return export, fmt.Errorf("unable to unmarshal paxostracker export: %s", err)
+	}
+	return export, nil
+}
+
+// copy a round so the export does not share transitions with the tracker
+func (r PaxosRound) copy() PaxosRound {
+	transitions := make([]Transition, len(r.Transitions))
+	copy(transitions, r.Transitions)
+	r.Transitions = transitions
+	return r
+}
@@ -2,21 +2,52 @@ package paxostracker
 
 import (
 	"fmt"
+	"paxostracker/state"
+	"strings"
+	"time"
+)
+
+// Role a node played in a round
+type Role string
+
+const (
+	// PROPOSER rounds follow the active states, driven by this node's writes
+	PROPOSER Role = "Proposer"
+	// ACCEPTOR rounds follow the passive states, driven by requests to this node's acceptor
+	ACCEPTOR Role = "Acceptor"
 )
 
 // PaxosRound is a round of paxos
 type PaxosRound struct {
-	InitialAddr         string
+	Role                Role
+	RoundNum            int
+	Proposer            string // ID of the node that proposed the round's value
 	AcceptedPreparation uint64
 	AcceptedProposal    uint64
 	Value               string
 	ErrorReason         string
+	Transitions         []Transition // every state the round passed through, in order
+}
+
+// Transition into a state, and when it happened
+type Transition struct {
+	State state.PaxosState
+	Time  time.Time
 }
 
 // AsRow converts a round to a string row
 func (r *PaxosRound) AsRow() string {
 	if r.ErrorReason != "" {
-		return fmt.Sprintf("| %s |", r.ErrorReason)
+		return fmt.Sprintf("| %s | %d | %s |\n", r.Role, r.RoundNum, r.ErrorReason)
+	}
+	return fmt.Sprintf("| %s | %d | %s | %d | %d | %s | %s |\n", r.Role, r.RoundNum, r.Proposer, r.AcceptedPreparation, r.AcceptedProposal, r.Value, r.path())
+}
+
+// path renders the transitions as Idle > Preparing > ...
+func (r *PaxosRound) path() string {
+	states := make([]string, 0, len(r.Transitions))
+	for _, transition := range r.Transitions {
+		states = append(states, string(transition.State))
 	}
-	return fmt.Sprintf("| %s | %d | %d | %s |\n", r.InitialAddr, r.AcceptedPreparation, r.AcceptedProposal, r.Value)
+	return strings.Join(states, " > ")
 }
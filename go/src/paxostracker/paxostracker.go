@@ -6,194 +6,345 @@ import (
 	"os"
 	"paxostracker/errors"
 	"paxostracker/state"
+	"sync"
+	"time"
 )
 
+var trackerLog = singletonlogger.Component("paxostracker")
+
+// DefaultMaxRounds is how many completed rounds a tracker keeps by default
+const DefaultMaxRounds = 1000
+
 /*
-PaxosTracker is a global singleton instantiated per consensuslib client instance to track the state.
+PaxosTracker is instantiated per consensuslib client instance to track the state.
+Each client owns its tracker and hands it to its PaxosNode, so several nodes in one process keep separate round
+histories and breakpoints.
 Paxostracker uses a DFA representation of the paxos process, and is activated by the consensuslib as it changes state.
 The paxostracker can output the current state at any time.
-The paxostracker can add a wait before the next stage activation.
+The paxostracker can add a wait before the next stage activation, optionally only when a condition holds.
 Each transition function call will return either nil or error.
+A node is tracked on two sides at once: the active states as its proposer drives its own writes, and the passive
+states as its acceptor answers prepare and accept requests from any proposer. Only the active side has breakpoints.
+A nil *PaxosTracker is valid, and only reports that it is uninitialised.
 */
 
 // PaxosTracker struct
 type PaxosTracker struct {
-	currentState state.PaxosState
+	sync.Mutex
+	node            string // address of the tracked node
+	id              string // ID of the tracked node, which names it as the proposer of its rounds
+	currentState    state.PaxosState
+	passiveState    state.PaxosState
+	completedRounds []PaxosRound // the latest maxRounds completed rounds, oldest first
+	maxRounds       int
+	currentRound    *PaxosRound
+	passiveRound    *PaxosRound
+
+	// signal channels
+	prepareKill   chan struct{}
+	proposeKill   chan struct{}
+	learnKill     chan struct{}
+	idleKill      chan struct{}
+	customKill    chan struct{}
+	continuePaxos chan struct{}
+
+	// breakpoints
+	breakpoints []Breakpoint
+	pausedAt    Stage // stage the node is blocked at, empty when running
+	paused      int   // rounds blocked at a breakpoint, each waiting for a continue
 }
 
-// global vars
-var tracker *PaxosTracker
-var completedRounds []PaxosRound
-var currentRound *PaxosRound
-
-// signal channels
-var prepareBreak chan struct{}
-var proposeBreak chan struct{}
-var learnBreak chan struct{}
-var idleBreak chan struct{}
-var customBreak chan struct{}
-var prepareKill chan struct{}
-var proposeKill chan struct{}
-var learnKill chan struct{}
-var idleKill chan struct{}
-var customKill chan struct{}
-var continuePaxos chan struct{}
-
-// NewPaxosTracker creates a new tracker
-func NewPaxosTracker() (err error) {
+// NewPaxosTracker creates a new tracker for the node at nodeAddr
+func NewPaxosTracker(nodeAddr string) (tracker *PaxosTracker) {
 	tracker = &PaxosTracker{
-		currentState: state.Idle,
-	}
-	prepareBreak = make(chan struct{})
-	proposeBreak = make(chan struct{})
-	learnBreak = make(chan struct{})
-	idleBreak = make(chan struct{})
-	customBreak = make(chan struct{})
-	prepareKill = make(chan struct{})
-	proposeKill = make(chan struct{})
-	learnKill = make(chan struct{})
-	idleKill = make(chan struct{})
-	customKill = make(chan struct{})
-	continuePaxos = make(chan struct{})
-	return nil
+		node:          nodeAddr,
+		currentState:  state.Idle,
+		maxRounds:     DefaultMaxRounds,
+		passiveState:  state.Idle,
+		prepareKill:   make(chan struct{}),
+		proposeKill:   make(chan struct{}),
+		learnKill:     make(chan struct{}),
+		idleKill:      make(chan struct{}),
+		customKill:    make(chan struct{}),
+		continuePaxos: make(chan struct{}, 1),
+	}
+	return tracker
+}
+
+// SetMaxRounds keeps at most max completed rounds, dropping the oldest ones beyond it
+func (t *PaxosTracker) SetMaxRounds(max int) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+	t.maxRounds = max
+	t.trimRounds()
+}
+
+// SetID of the tracked node, once its PaxosNode has one
+func (t *PaxosTracker) SetID(id string) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+	t.id = id
 }
 
-// Prepare request
-func Prepare(callerAddr string) error {
-	if tracker == nil {
-		singletonlogger.Error("Error: PaxosTracker Uninitialised")
+// Prepare request from the node's own proposer, identified by proposerID
+func (t *PaxosTracker) Prepare(proposerID string, value string, roundNum int) error {
+	if t == nil {
+		trackerLog.Error("PaxosTracker Uninitialised")
 		return nil
 	}
 
+	t.pause(PREPARE, roundNum, value)
 	select {
-	case <-prepareBreak:
-		singletonlogger.Debug("[paxostracker] blocking before prepare")
-		// blocks until continue channel is filled
-		<-continuePaxos
-		singletonlogger.Debug("[paxostracker] continuing...")
-	case <-prepareKill:
-		singletonlogger.Debug("[paxostracker] killing roughly at prepare...")
+	case <-t.prepareKill:
+		trackerLog.Debug("killing roughly at prepare...")
 		os.Exit(1)
 	default:
 	}
-	switch tracker.currentState {
+	t.Lock()
+	defer t.Unlock()
+	switch t.currentState {
 	case state.Idle:
 	default:
 		return errors.BadTransition("")
 	}
-	currentRound = &PaxosRound{
-		InitialAddr: callerAddr,
+	t.currentRound = &PaxosRound{
+		Role:        PROPOSER,
+		RoundNum:    roundNum,
+		Proposer:    proposerID,
+		Value:       value,
+		Transitions: []Transition{{state.Idle, time.Now()}},
 	}
-	tracker.currentState = state.Preparing
+	t.activeTransition(state.Preparing)
 	return nil
 }
 
 // Propose request
-func Propose(acceptedPrep uint64) error {
-	if tracker == nil {
-		singletonlogger.Error("Error: PaxosTracker Uninitialised")
+func (t *PaxosTracker) Propose(acceptedPrep uint64, value string, roundNum int) error {
+	if t == nil {
+		trackerLog.Error("PaxosTracker Uninitialised")
 		return nil
 	}
 
+	t.pause(PROPOSE, roundNum, value)
 	select {
-	case <-proposeBreak:
-		singletonlogger.Debug("[paxostracker] blocking before propose")
-		// blocks until continue channel is filled
-		<-continuePaxos
-		singletonlogger.Debug("[paxostracker] continuing...")
-	case <-proposeKill:
-		singletonlogger.Debug("[paxostracker] killing roughly at propose...")
+	case <-t.proposeKill:
+		trackerLog.Debug("killing roughly at propose...")
 		os.Exit(1)
 	default:
 	}
 
-	switch tracker.currentState {
+	t.Lock()
+	defer t.Unlock()
+	switch t.currentState {
 	case state.Preparing:
 	default:
 		return errors.BadTransition("")
 	}
-	currentRound.AcceptedPreparation = acceptedPrep
-	tracker.currentState = state.Proposing
+	t.currentRound.AcceptedPreparation = acceptedPrep
+	t.activeTransition(state.Proposing)
 	return nil
 }
 
 // Learn value
-func Learn(acceptedProp uint64) error {
-	if tracker == nil {
-		singletonlogger.Error("Error: PaxosTracker Uninitialised")
+func (t *PaxosTracker) Learn(acceptedProp uint64, value string, roundNum int) error {
+	if t == nil {
+		trackerLog.Error("PaxosTracker Uninitialised")
 		return nil
 	}
 
+	t.pause(LEARN, roundNum, value)
 	select {
-	case <-learnBreak:
-		singletonlogger.Debug("[paxostracker] blocking before learn")
-		// blocks until continue channel is filled
-		<-continuePaxos
-		singletonlogger.Debug("[paxostracker] continuing...")
-	case <-learnKill:
-		singletonlogger.Debug("[paxostracker] killing roughly at learn...")
+	case <-t.learnKill:
+		trackerLog.Debug("killing roughly at learn...")
 		os.Exit(1)
 	default:
 	}
 
-	switch tracker.currentState {
+	t.Lock()
+	defer t.Unlock()
+	switch t.currentState {
 	case state.Proposing:
 	default:
 		return errors.BadTransition("")
 	}
-	currentRound.AcceptedProposal = acceptedProp
-	tracker.currentState = state.Learning
+	t.currentRound.AcceptedProposal = acceptedProp
+	t.activeTransition(state.Learning)
 	return nil
 }
 
 // Idle return
-func Idle(finalValue string) error {
-	if tracker == nil {
-		singletonlogger.Error("Error: PaxosTracker Uninitialised")
+func (t *PaxosTracker) Idle(finalValue string) error {
+	if t == nil {
+		trackerLog.Error("PaxosTracker Uninitialised")
 		return nil
 	}
 
+	roundNum, _ := t.roundContext()
+	t.pause(IDLE, roundNum, finalValue)
 	select {
-	case <-idleBreak:
-		singletonlogger.Debug("[paxostracker] blocking before idle")
-		// blocks until continue channel is filled
-		<-continuePaxos
-		singletonlogger.Debug("[paxostracker] continuing...")
-	case <-idleKill:
-		singletonlogger.Debug("[paxostracker] killing roughly at idle...")
+	case <-t.idleKill:
+		trackerLog.Debug("killing roughly at idle...")
 		os.Exit(1)
 	default:
 	}
 
-	// check for valid transitions
-	switch tracker.currentState {
-	case state.Learning:
-	case state.Accepted:
+	t.Lock()
+	defer t.Unlock()
+	// check for valid transitions, on either side
+	learning := t.currentState == state.Learning
+	passive := t.passiveState.OneOf([]state.PaxosState{state.Promised, state.Accepted})
+	if !learning && !passive {
+		return errors.BadTransition("")
+	}
+	if learning {
+		t.currentRound.Value = finalValue
+		t.activeTransition(state.Idle)
+		// save the completed round
+		t.complete(*t.currentRound)
+		// reset current round
+		t.currentRound = nil
+	}
+	if passive {
+		t.closePassive(finalValue)
+	}
+	return nil
+}
+
+// CaughtUp on the value of round roundNum from another node instead of learning it, which ends the passive
+// round of roundNum, or of any earlier round, as the acceptor will not hear of it again
+func (t *PaxosTracker) CaughtUp(roundNum int, value string) error {
+	if t == nil {
+		trackerLog.Error("PaxosTracker Uninitialised")
+		return nil
+	}
+	t.Lock()
+	defer t.Unlock()
+	if t.passiveRound != nil && t.passiveRound.RoundNum <= roundNum {
+		if t.passiveRound.RoundNum < roundNum {
+			value = ""
+		}
+		t.closePassive(value)
+	}
+	return nil
+}
+
+// Promise is the passive transition made when the acceptor promises a prepare request.
+// A later, higher prepare request may be promised again, even after an earlier one was accepted.
+// A promise for a later round ends the passive round of an earlier one, whose value was never learned here.
+func (t *PaxosTracker) Promise(proposerID string, promisedPrep uint64, roundNum int) error {
+	if t == nil {
+		trackerLog.Error("PaxosTracker Uninitialised")
+		return nil
+	}
+	t.Lock()
+	defer t.Unlock()
+	switch t.passiveState {
+	case state.Idle:
+	case state.Promised, state.Accepted:
+		if t.passiveRound.RoundNum != roundNum {
+			t.closePassive("")
+		}
 	default:
 		return errors.BadTransition("")
 	}
-	currentRound.Value = finalValue
-	tracker.currentState = state.Idle
-	// save the completed round
-	completedRounds = append(completedRounds, *currentRound)
-	// reset current round
-	currentRound = nil
+	t.openPassive(proposerID, roundNum)
+	t.passiveRound.Proposer = proposerID
+	t.passiveRound.AcceptedPreparation = promisedPrep
+	t.passiveTransition(state.Promised)
+	return nil
+}
+
+// Accept is the passive transition made when the acceptor accepts an accept request.
+// A higher accept request may be accepted again in the same round.
+func (t *PaxosTracker) Accept(proposerID string, acceptedProp uint64, roundNum int) error {
+	if t == nil {
+		trackerLog.Error("PaxosTracker Uninitialised")
+		return nil
+	}
+	t.Lock()
+	defer t.Unlock()
+	switch t.passiveState {
+	case state.Idle:
+		// an acceptor may accept a higher proposal it never promised
+	case state.Promised, state.Accepted:
+		if t.passiveRound.RoundNum != roundNum {
+			t.closePassive("")
+		}
+	default:
+		return errors.BadTransition("")
+	}
+	t.openPassive(proposerID, roundNum)
+	t.passiveRound.Proposer = proposerID
+	t.passiveRound.AcceptedProposal = acceptedProp
+	t.passiveTransition(state.Accepted)
 	return nil
 }
 
+// openPassive starts a passive round of roundNum unless one is in progress, and must be called holding the lock
+func (t *PaxosTracker) openPassive(proposerID string, roundNum int) {
+	if t.passiveRound != nil {
+		return
+	}
+	t.passiveRound = &PaxosRound{
+		Role:        ACCEPTOR,
+		RoundNum:    roundNum,
+		Proposer:    proposerID,
+		Transitions: []Transition{{state.Idle, time.Now()}},
+	}
+}
+
+// closePassive ends the passive round with the value learned in it, or none if the acceptor never learned it,
+// and must be called holding the lock
+func (t *PaxosTracker) closePassive(value string) {
+	t.passiveRound.Value = value
+	t.passiveTransition(state.Idle)
+	t.complete(*t.passiveRound)
+	t.passiveRound = nil
+}
+
+// complete saves a round that ended, and must be called holding the lock
+func (t *PaxosTracker) complete(round PaxosRound) {
+	t.completedRounds = append(t.completedRounds, round)
+	t.trimRounds()
+}
+
+// trimRounds drops the oldest completed rounds beyond maxRounds, and must be called holding the lock
+func (t *PaxosTracker) trimRounds() {
+	if extra := len(t.completedRounds) - t.maxRounds; extra > 0 {
+		// copied, so the dropped rounds are not kept alive by the backing array
+		t.completedRounds = append([]PaxosRound(nil), t.completedRounds[extra:]...)
+	}
+}
+
+// activeTransition moves the proposer side to the next state, and must be called holding the lock
+func (t *PaxosTracker) activeTransition(next state.PaxosState) {
+	t.currentState = next
+	t.currentRound.Transitions = append(t.currentRound.Transitions, Transition{next, time.Now()})
+}
+
+// passiveTransition moves the acceptor side to the next state, and must be called holding the lock
+func (t *PaxosTracker) passiveTransition(next state.PaxosState) {
+	t.passiveState = next
+	t.passiveRound.Transitions = append(t.passiveRound.Transitions, Transition{next, time.Now()})
+}
+
 // Custom pause point
-func Custom() error {
-	if tracker == nil {
-		singletonlogger.Error("Error: PaxosTracker Uninitialised")
+func (t *PaxosTracker) Custom() error {
+	if t == nil {
+		trackerLog.Error("PaxosTracker Uninitialised")
 		return nil
 	}
+	roundNum, value := t.roundContext()
+	t.pause(CUSTOM, roundNum, value)
 	select {
-	case <-customBreak:
-		singletonlogger.Debug("[paxostracker] blocking before custom")
-		<-continuePaxos
-		singletonlogger.Debug("[paxostracker] continuing...")
-	case <-customKill:
-		singletonlogger.Debug("[paxostracker] killing roughly at custom...")
+	case <-t.customKill:
+		trackerLog.Debug("killing roughly at custom...")
 		os.Exit(1)
 	default:
 	}
@@ -201,109 +352,100 @@ func Custom() error {
 }
 
 // Error transition
-func Error(reason string) error {
-	if tracker == nil {
-		singletonlogger.Error("Error: PaxosTracker Uninitialised")
+func (t *PaxosTracker) Error(reason string) error {
+	if t == nil {
+		trackerLog.Error("PaxosTracker Uninitialised")
 		return nil
 	}
+	t.Lock()
+	defer t.Unlock()
 	// valid for all transitions
-	currentRound.ErrorReason = reason
-	tracker.currentState = state.Idle
+	if t.currentRound == nil {
+		t.currentRound = &PaxosRound{Role: PROPOSER}
+	}
+	t.currentRound.ErrorReason = reason
+	t.currentRound.Transitions = append(t.currentRound.Transitions, Transition{state.Error, time.Now()})
+	t.activeTransition(state.Idle)
 	// save the completed round
-	completedRounds = append(completedRounds, *currentRound)
+	t.complete(*t.currentRound)
 	// reset current round
-	currentRound = nil
+	t.currentRound = nil
 	return nil
 }
 
 // BreakNextPrepare will block on the next prepare call till continue
-func BreakNextPrepare() error {
-	singletonlogger.Debug("[paxostracker] Filling preparebreak channel for next round")
-	prepareBreak <- struct{}{}
-	return nil
+func (t *PaxosTracker) BreakNextPrepare() error {
+	return t.AddBreakpoint(Breakpoint{Stage: PREPARE})
 }
 
 // BreakNextPropose will block on the next propose call till continue
-func BreakNextPropose() error {
-	singletonlogger.Debug("[paxostracker] Filling proposebreak channel for next round")
-	proposeBreak <- struct{}{}
-	return nil
+func (t *PaxosTracker) BreakNextPropose() error {
+	return t.AddBreakpoint(Breakpoint{Stage: PROPOSE})
 }
 
 // BreakNextLearn will block on the next learn call till continue
-func BreakNextLearn() error {
-	singletonlogger.Debug("[paxostracker] Filling learnbreak channel for next round")
-	learnBreak <- struct{}{}
-	return nil
+func (t *PaxosTracker) BreakNextLearn() error {
+	return t.AddBreakpoint(Breakpoint{Stage: LEARN})
 }
 
 // BreakNextIdle will block on the next idle call till continue
-func BreakNextIdle() error {
-	singletonlogger.Debug("[paxostracker] Filling idleBreak channel for next round")
-	idleBreak <- struct{}{}
-	return nil
+func (t *PaxosTracker) BreakNextIdle() error {
+	return t.AddBreakpoint(Breakpoint{Stage: IDLE})
 }
 
 // BreakNextCustom will block on the next custom call till continue
-func BreakNextCustom() error {
-	singletonlogger.Debug("[paxostracker] Filling customBreak channel for next round")
-	customBreak <- struct{}{}
-	return nil
-}
-
-// Continue the execution of paxos
-func Continue() error {
-	singletonlogger.Debug("[paxostracker] Filling continue channel for next round")
-	continuePaxos <- struct{}{}
-	return nil
+func (t *PaxosTracker) BreakNextCustom() error {
+	return t.AddBreakpoint(Breakpoint{Stage: CUSTOM})
 }
 
 // KillNextPrepare will block on the next prepare call till continue
-func KillNextPrepare() error {
-	singletonlogger.Debug("[paxostracker] Filling preparebreak channel for next round")
-	prepareKill <- struct{}{}
+func (t *PaxosTracker) KillNextPrepare() error {
+	trackerLog.Debug("Filling preparebreak channel for next round")
+	t.prepareKill <- struct{}{}
 	return nil
 }
 
 // KillNextPropose will block on the next propose call till continue
-func KillNextPropose() error {
-	singletonlogger.Debug("[paxostracker] Filling proposebreak channel for next round")
-	proposeKill <- struct{}{}
+func (t *PaxosTracker) KillNextPropose() error {
+	trackerLog.Debug("Filling proposebreak channel for next round")
+	t.proposeKill <- struct{}{}
 	return nil
 }
 
 // KillNextLearn will block on the next learn call till continue
-func KillNextLearn() error {
-	singletonlogger.Debug("[paxostracker] Filling learnbreak channel for next round")
-	learnKill <- struct{}{}
+func (t *PaxosTracker) KillNextLearn() error {
+	trackerLog.Debug("Filling learnbreak channel for next round")
+	t.learnKill <- struct{}{}
 	return nil
 }
 
 // KillNextIdle will block on the next idle call till continue
-func KillNextIdle() error {
-	singletonlogger.Debug("[paxostracker] Filling idleKill channel for next round")
-	idleKill <- struct{}{}
+func (t *PaxosTracker) KillNextIdle() error {
+	trackerLog.Debug("Filling idleKill channel for next round")
+	t.idleKill <- struct{}{}
 	return nil
 }
 
 // KillNextCustom will block on the next custom call till continue
-func KillNextCustom() error {
-	singletonlogger.Debug("[paxostracker] Filling customKill channel for next round")
-	customKill <- struct{}{}
+func (t *PaxosTracker) KillNextCustom() error {
+	trackerLog.Debug("Filling customKill channel for next round")
+	t.customKill <- struct{}{}
 	return nil
 }
 
 // AsTable returns the current state of the paxos process in human consumable table form.
-func AsTable() string {
-	rows := "| Initial Addr | AcceptedPrepare | AcceptedProposal | Value |\n"
-	for _, round := range completedRounds {
-		rows += round.AsRow()
-	}
-	var pstate state.PaxosState
-	if tracker == nil {
-		pstate = state.Idle
-	} else {
-		pstate = tracker.currentState
-	}
-	return fmt.Sprintf("\n======================\nCurrent State: %v\n======================\n%v", pstate, rows)
+func (t *PaxosTracker) AsTable() string {
+	rows := "| Role | Round | Proposer | AcceptedPrepare | AcceptedProposal | Value | Transitions |\n"
+	pstate, passive := state.Idle, state.Idle
+	node := ""
+	if t != nil {
+		t.Lock()
+		defer t.Unlock()
+		pstate, passive = t.currentState, t.passiveState
+		node = fmt.Sprintf("Node: %v (%v)\n", t.node, t.id)
+		for _, round := range t.completedRounds {
+			rows += round.AsRow()
+		}
+	}
+	return fmt.Sprintf("\n======================\n%vCurrent State: %v\nAcceptor State: %v\n======================\n%v", node, pstate, passive, rows)
 }
@@ -0,0 +1,164 @@
+package timeline
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"paxostracker"
+	"sort"
+	"time"
+)
+
+/*
+Timeline renders merged paxostracker exports from several nodes as a single, self-contained HTML page.
+Every node gets a swimlane, split into its proposer and acceptor roles, and every round is drawn as a run of
+coloured segments, one per state it passed through. Hovering a segment shows the round, state and time.
+*/
+
+type segment struct {
+	Left  float64 // percent of the timeline
+	Width float64 // percent of the timeline
+	State string
+	Title string
+}
+
+type row struct {
+	Role     paxostracker.Role
+	Segments []segment
+}
+
+type lane struct {
+	Node string
+	Rows []row
+}
+
+type page struct {
+	Start time.Time
+	End   time.Time
+	Lanes []lane
+}
+
+// minWidth keeps instantaneous states visible
+const minWidth = 0.3
+
+// Render the exports as an HTML timeline
+func Render(w io.Writer, exports []paxostracker.Export) error {
+	start, end := bounds(exports)
+	span := float64(end.Sub(start))
+	if span <= 0 {
+		span = 1
+	}
+	position := func(t time.Time) float64 {
+		return float64(t.Sub(start)) / span * 100
+	}
+
+	sorted := make([]paxostracker.Export, len(exports))
+	copy(sorted, exports)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Node < sorted[j].Node })
+
+	// rounds name their proposer by ID, which is shown as its address when one of the exports is from it
+	names := make(map[string]string, len(exports))
+	for _, export := range exports {
+		if export.ID != "" {
+			names[export.ID] = export.Node
+		}
+	}
+	name := func(id string) string {
+		if addr, ok := names[id]; ok {
+			return addr
+		}
+		return id
+	}
+
+	p := page{Start: start, End: end}
+	for _, export := range sorted {
+		rows := map[paxostracker.Role]*row{
+			paxostracker.PROPOSER: {Role: paxostracker.PROPOSER},
+			paxostracker.ACCEPTOR: {Role: paxostracker.ACCEPTOR},
+		}
+		for _, round := range export.Rounds {
+			r, ok := rows[round.Role]
+			if !ok {
+				continue
+			}
+			for i, transition := range round.Transitions {
+				until := export.ExportedAt
+				if i+1 < len(round.Transitions) {
+					until = round.Transitions[i+1].Time
+				} else if i > 0 {
+					// the round has finished, so its last state is only a marker
+					until = transition.Time
+				}
+				width := position(until) - position(transition.Time)
+				if width < minWidth {
+					width = minWidth
+				}
+				r.Segments = append(r.Segments, segment{
+					Left:  position(transition.Time),
+					Width: width,
+					State: string(transition.State),
+					Title: fmt.Sprintf("round %d: %s at %s (%s, value '%s')", round.RoundNum, transition.State,
+						transition.Time.Format("15:04:05.000000"), name(round.Proposer), round.Value),
+				})
+			}
+		}
+		p.Lanes = append(p.Lanes, lane{
+			Node: export.Node,
+			Rows: []row{*rows[paxostracker.PROPOSER], *rows[paxostracker.ACCEPTOR]},
+		})
+	}
+	return pageTemplate.Execute(w, p)
+}
+
+// bounds of every transition in the exports
+func bounds(exports []paxostracker.Export) (start, end time.Time) {
+	for _, export := range exports {
+		for _, round := range export.Rounds {
+			for _, transition := range round.Transitions {
+				if start.IsZero() || transition.Time.Before(start) {
+					start = transition.Time
+				}
+				if transition.Time.After(end) {
+					end = transition.Time
+				}
+			}
+		}
+	}
+	return start, end
+}
+
+var pageTemplate = template.Must(template.New("timeline").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Paxos Timeline</title>
+<style>
+body { font-family: sans-serif; margin: 20px; }
+.lane { border-top: 1px solid #999; padding: 6px 0; }
+.node { font-weight: bold; margin-bottom: 4px; }
+.row { display: flex; align-items: center; height: 22px; }
+.role { width: 80px; font-size: 12px; color: #555; }
+.track { position: relative; flex: 1; height: 16px; background: #f4f4f4; }
+.seg { position: absolute; top: 0; height: 16px; }
+.Idle { background: #bbb; }
+.Preparing { background: #f0ad4e; }
+.Proposing { background: #5bc0de; }
+.Learning { background: #5cb85c; }
+.Promised { background: #d9a3f0; }
+.Accepted { background: #8e44ad; }
+.Error { background: #d9534f; }
+.legend span { display: inline-block; padding: 2px 6px; margin-right: 4px; font-size: 12px; }
+</style>
+</head>
+<body>
+<h2>Paxos Timeline</h2>
+<p>{{.Start.Format "2006-01-02 15:04:05.000000"}} to {{.End.Format "2006-01-02 15:04:05.000000"}}</p>
+<p class="legend"><span class="Idle">Idle</span><span class="Preparing">Preparing</span><span class="Proposing">Proposing</span><span class="Learning">Learning</span><span class="Promised">Promised</span><span class="Accepted">Accepted</span><span class="Error">Error</span></p>
+{{range .Lanes}}<div class="lane">
+<div class="node">{{.Node}}</div>
+{{range .Rows}}<div class="row"><div class="role">{{.Role}}</div><div class="track">{{range .Segments}}<div class="seg {{.State}}" style="left: {{printf "%.3f" .Left}}%; width: {{printf "%.3f" .Width}}%" title="{{.Title}}"></div>{{end}}</div></div>
+{{end}}</div>
+{{end}}
+</body>
+</html>
+`))